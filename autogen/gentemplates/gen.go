@@ -311,6 +311,7 @@ var _templatesDockerTmpl = []byte(`{{$backendServers := .Servers}}
       {{if $loadBalancer.Stickiness }}
       [backends."backend-{{ $backendName }}".loadBalancer.stickiness]
         cookieName = "{{ $loadBalancer.Stickiness.CookieName }}"
+        sourceIP = {{ $loadBalancer.Stickiness.SourceIP }}
       {{end}}
   {{end}}
 
@@ -347,10 +348,31 @@ var _templatesDockerTmpl = []byte(`{{$backendServers := .Servers}}
     retryExpression = "{{ $buffering.RetryExpression }}"
   {{end}}
 
+  {{ $responseForwarding := getResponseForwarding $backend.SegmentLabels }}
+  {{if $responseForwarding }}
+  [backends."backend-{{ $backendName }}".responseForwarding]
+    flushInterval = "{{ $responseForwarding.FlushInterval }}"
+  {{end}}
+
+  {{ $tls := getBackendTLS $backend.SegmentLabels }}
+  {{if $tls }}
+  [backends."backend-{{ $backendName }}".tls]
+    insecureSkipVerify = {{ $tls.InsecureSkipVerify }}
+    serverName = "{{ $tls.ServerName }}"
+  {{end}}
+
+  {{ $retry := getRetry $backend.SegmentLabels }}
+  {{if $retry }}
+  [backends."backend-{{ $backendName }}".retry]
+    attempts = {{ $retry.Attempts }}
+  {{end}}
+
   {{range $serverName, $server := getServers $servers }}
   [backends."backend-{{ $backendName }}".servers."{{ $serverName }}"]
     url = "{{ $server.URL }}"
     weight = {{ $server.Weight }}
+    maintenance = {{ $server.Maintenance }}
+    standby = {{ $server.Standby }}
   {{end}}
 
 {{end}}
@@ -361,7 +383,7 @@ var _templatesDockerTmpl = []byte(`{{$backendServers := .Servers}}
 
   [frontends."frontend-{{ $frontendName }}"]
     backend = "backend-{{ getBackendName $container }}"
-    priority = {{ getPriority $container.SegmentLabels }}
+    priority = {{ getPriority $container }}
     passHostHeader = {{ getPassHostHeader $container.SegmentLabels }}
     passTLSCert = {{ getPassTLSCert $container.SegmentLabels }}
 
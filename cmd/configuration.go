@@ -46,6 +46,12 @@ func NewTraefikDefaultPointersConfiguration() *TraefikConfiguration {
 	defaultDocker.ExposedByDefault = true
 	defaultDocker.Endpoint = "unix:///var/run/docker.sock"
 	defaultDocker.SwarmMode = false
+	defaultDocker.APITimeout = parse.Duration(30 * time.Second)
+	defaultDocker.LabelPrefix = "traefik"
+	defaultDocker.DefaultProtocol = "http"
+	defaultDocker.HealthPolicy = "strict"
+	defaultDocker.EventsBufferSize = 100
+	defaultDocker.SelfExclude = true
 
 	// default File
 	var defaultFile file.Provider
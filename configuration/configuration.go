@@ -2,6 +2,7 @@ package configuration
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -176,6 +177,19 @@ func (gc *GlobalConfiguration) SetEffectiveConfiguration(configFile string) {
 		gc.File.TraefikFile = configFile
 	}
 
+	if gc.Docker != nil {
+		gc.Docker.EntryPoints = nil
+		gc.Docker.TLSEntryPoints = nil
+		for entryPointName, entryPoint := range gc.EntryPoints {
+			gc.Docker.EntryPoints = append(gc.Docker.EntryPoints, entryPointName)
+			if entryPoint.TLS != nil {
+				gc.Docker.TLSEntryPoints = append(gc.Docker.TLSEntryPoints, entryPointName)
+			}
+		}
+		sort.Strings(gc.Docker.EntryPoints)
+		sort.Strings(gc.Docker.TLSEntryPoints)
+	}
+
 	gc.initACMEProvider()
 	gc.initTracing()
 }
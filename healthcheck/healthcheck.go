@@ -43,6 +43,9 @@ type Options struct {
 	Transport http.RoundTripper
 	Interval  time.Duration
 	LB        BalancerHandler
+	Standby   []*url.URL // Servers only added to the load balancer while no other server is healthy
+	// StandbyWeights are the weights of the standby servers, by URL (1 when missing)
+	StandbyWeights map[string]int
 }
 
 func (opt Options) String() string {
@@ -52,9 +55,10 @@ func (opt Options) String() string {
 // BackendConfig HealthCheck configuration for a backend
 type BackendConfig struct {
 	Options
-	name           string
-	disabledURLs   []*url.URL
-	requestTimeout time.Duration
+	name              string
+	disabledURLs      []*url.URL
+	activeStandbyURLs []*url.URL
+	requestTimeout    time.Duration
 }
 
 func (b *BackendConfig) newRequest(serverURL *url.URL) (*http.Request, error) {
@@ -128,7 +132,7 @@ func (hc *HealthCheck) execute(ctx context.Context, backend *BackendConfig) {
 }
 
 func (hc *HealthCheck) checkBackend(backend *BackendConfig) {
-	enabledURLs := backend.LB.Servers()
+	enabledURLs := backend.primaryURLs()
 	var newDisabledURLs []*url.URL
 	for _, disableURL := range backend.disabledURLs {
 		serverUpMetricValue := float64(0)
@@ -160,6 +164,82 @@ func (hc *HealthCheck) checkBackend(backend *BackendConfig) {
 		labelValues := []string{"backend", backend.name, "url", enableURL.String()}
 		hc.metrics.BackendServerUpGauge().With(labelValues...).Set(serverUpMetricValue)
 	}
+
+	hc.checkStandby(backend)
+}
+
+// checkStandby adds the healthy standby servers to the load balancer while no other server is healthy,
+// and removes them as soon as one is back.
+func (hc *HealthCheck) checkStandby(backend *BackendConfig) {
+	if len(backend.Standby) == 0 {
+		return
+	}
+
+	primaryUp := len(backend.primaryURLs()) > 0
+
+	var activeURLs []*url.URL
+	for _, standbyURL := range backend.Standby {
+		active := containsURL(backend.activeStandbyURLs, standbyURL)
+
+		healthy := false
+		if !primaryUp {
+			if err := checkHealth(standbyURL, backend); err == nil {
+				healthy = true
+			} else {
+				log.Warnf("Health check failed for the standby server. Backend: %q URL: %q Reason: %s", backend.name, standbyURL.String(), err)
+			}
+		}
+
+		switch {
+		case healthy && !active:
+			log.Warnf("No healthy server left: Adding the standby server to the server list. Backend: %q URL: %q", backend.name, standbyURL.String())
+			if err := backend.LB.UpsertServer(standbyURL, roundrobin.Weight(backend.standbyWeight(standbyURL))); err != nil {
+				log.Error(err)
+			}
+		case !healthy && active:
+			log.Warnf("Removing the standby server from the server list. Backend: %q URL: %q", backend.name, standbyURL.String())
+			if err := backend.LB.RemoveServer(standbyURL); err != nil {
+				log.Error(err)
+			}
+		}
+
+		serverUpMetricValue := float64(0)
+		if healthy {
+			activeURLs = append(activeURLs, standbyURL)
+			serverUpMetricValue = 1
+		}
+		labelValues := []string{"backend", backend.name, "url", standbyURL.String()}
+		hc.metrics.BackendServerUpGauge().With(labelValues...).Set(serverUpMetricValue)
+	}
+	backend.activeStandbyURLs = activeURLs
+}
+
+// standbyWeight returns the weight configured for the standby server.
+func (b *BackendConfig) standbyWeight(standbyURL *url.URL) int {
+	if weight, ok := b.StandbyWeights[standbyURL.String()]; ok {
+		return weight
+	}
+	return 1
+}
+
+// primaryURLs returns the servers of the load balancer, except the standby ones.
+func (b *BackendConfig) primaryURLs() []*url.URL {
+	var urls []*url.URL
+	for _, serverURL := range b.LB.Servers() {
+		if !containsURL(b.activeStandbyURLs, serverURL) {
+			urls = append(urls, serverURL)
+		}
+	}
+	return urls
+}
+
+func containsURL(urls []*url.URL, u *url.URL) bool {
+	for _, candidate := range urls {
+		if candidate.String() == u.String() {
+			return true
+		}
+	}
+	return false
 }
 
 // GetHealthCheck returns the health check which is guaranteed to be a singleton.
@@ -149,6 +149,55 @@ func TestSetBackendsConfiguration(t *testing.T) {
 	}
 }
 
+func TestCheckBackendStandby(t *testing.T) {
+	primaryStatus := http.StatusServiceUnavailable
+	var statusMu sync.Mutex
+	primary := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		statusMu.Lock()
+		defer statusMu.Unlock()
+		rw.WriteHeader(primaryStatus)
+	}))
+	defer primary.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer standby.Close()
+
+	primaryURL := testhelpers.MustParseURL(primary.URL)
+	standbyURL := testhelpers.MustParseURL(standby.URL)
+
+	lb := &testLoadBalancer{RWMutex: &sync.RWMutex{}, servers: []*url.URL{primaryURL}}
+	backend := NewBackendConfig(Options{
+		Path:     "/path",
+		Interval: healthCheckInterval,
+		LB:       lb,
+		Standby:  []*url.URL{standbyURL},
+	}, "backendName")
+
+	check := HealthCheck{
+		Backends: make(map[string]*BackendConfig),
+		metrics:  testhelpers.NewCollectingHealthCheckMetrics(),
+	}
+
+	// The standby server replaces the failing primary server.
+	check.checkBackend(backend)
+	assert.Equal(t, []*url.URL{standbyURL}, lb.Servers())
+
+	// It stays while the primary server is failing.
+	check.checkBackend(backend)
+	assert.Equal(t, []*url.URL{standbyURL}, lb.Servers())
+
+	// It is removed as soon as the primary server is back.
+	statusMu.Lock()
+	primaryStatus = http.StatusOK
+	statusMu.Unlock()
+
+	check.checkBackend(backend)
+	assert.Equal(t, []*url.URL{primaryURL}, lb.Servers())
+	assert.Empty(t, backend.activeStandbyURLs)
+}
+
 func TestNewRequest(t *testing.T) {
 	testCases := []struct {
 		desc      string
@@ -296,6 +345,45 @@ func TestAddHeadersAndHost(t *testing.T) {
 	}
 }
 
+func TestCheckBackendStandbyWeight(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer standby.Close()
+
+	primaryURL := testhelpers.MustParseURL(primary.URL)
+	standbyURL := testhelpers.MustParseURL(standby.URL)
+
+	lb, err := roundrobin.New(http.NotFoundHandler())
+	require.NoError(t, err)
+	require.NoError(t, lb.UpsertServer(primaryURL, roundrobin.Weight(3)))
+
+	backend := NewBackendConfig(Options{
+		Path:           "/path",
+		Interval:       healthCheckInterval,
+		LB:             lb,
+		Standby:        []*url.URL{standbyURL},
+		StandbyWeights: map[string]int{standbyURL.String(): 3},
+	}, "backendName")
+
+	check := HealthCheck{
+		Backends: make(map[string]*BackendConfig),
+		metrics:  testhelpers.NewCollectingHealthCheckMetrics(),
+	}
+
+	check.checkBackend(backend)
+	require.Equal(t, []*url.URL{standbyURL}, lb.Servers())
+
+	weight, ok := lb.ServerWeight(standbyURL)
+	require.True(t, ok)
+	assert.Equal(t, 3, weight)
+}
+
 type testLoadBalancer struct {
 	// RWMutex needed due to parallel test execution: Both the system-under-test
 	// and the test assertions reference the counters.
@@ -47,6 +47,30 @@ func nodeIP(ip string) func(*docker.ContainerJSON) {
 	}
 }
 
+func platform(platform string) func(*docker.ContainerJSON) {
+	return func(c *docker.ContainerJSON) {
+		c.ContainerJSONBase.Platform = platform
+	}
+}
+
+func defaultIPv4(ip string) func(*docker.ContainerJSON) {
+	return func(c *docker.ContainerJSON) {
+		c.NetworkSettings.DefaultNetworkSettings.IPAddress = ip
+	}
+}
+
+func restartCount(count int) func(*docker.ContainerJSON) {
+	return func(c *docker.ContainerJSON) {
+		c.ContainerJSONBase.RestartCount = count
+	}
+}
+
+func image(image string) func(*docker.ContainerJSON) {
+	return func(c *docker.ContainerJSON) {
+		c.Config.Image = image
+	}
+}
+
 func labels(labels map[string]string) func(*docker.ContainerJSON) {
 	return func(c *docker.ContainerJSON) {
 		c.Config.Labels = labels
@@ -59,6 +83,15 @@ func ports(portMap nat.PortMap) func(*docker.ContainerJSON) {
 	}
 }
 
+func exposedPorts(ports ...nat.Port) func(*docker.ContainerJSON) {
+	return func(c *docker.ContainerJSON) {
+		c.Config.ExposedPorts = nat.PortSet{}
+		for _, port := range ports {
+			c.Config.ExposedPorts[port] = struct{}{}
+		}
+	}
+}
+
 func withNetwork(name string, ops ...func(*network.EndpointSettings)) func(*docker.ContainerJSON) {
 	return func(c *docker.ContainerJSON) {
 		if c.NetworkSettings.Networks == nil {
@@ -77,9 +110,28 @@ func ipv4(ip string) func(*network.EndpointSettings) {
 	}
 }
 
+func ipamIPv4(ip string) func(*network.EndpointSettings) {
+	return func(s *network.EndpointSettings) {
+		s.IPAMConfig = &network.EndpointIPAMConfig{IPv4Address: ip}
+	}
+}
+
+func ipv6(ip string) func(*network.EndpointSettings) {
+	return func(s *network.EndpointSettings) {
+		s.GlobalIPv6Address = ip
+	}
+}
+
+func aliases(aliases ...string) func(*network.EndpointSettings) {
+	return func(s *network.EndpointSettings) {
+		s.Aliases = aliases
+	}
+}
+
 func swarmTask(id string, ops ...func(*swarm.Task)) swarm.Task {
 	task := &swarm.Task{
-		ID: id,
+		ID:           id,
+		DesiredState: swarm.TaskStateRunning,
 	}
 
 	for _, op := range ops {
@@ -95,6 +147,12 @@ func taskSlot(slot int) func(*swarm.Task) {
 	}
 }
 
+func taskDesiredState(state swarm.TaskState) func(*swarm.Task) {
+	return func(task *swarm.Task) {
+		task.DesiredState = state
+	}
+}
+
 func taskNetworkAttachment(id string, name string, driver string, addresses []string) func(*swarm.Task) {
 	return func(task *swarm.Task) {
 		task.NetworksAttachments = append(task.NetworksAttachments, swarm.NetworkAttachment{
@@ -114,6 +172,26 @@ func taskNetworkAttachment(id string, name string, driver string, addresses []st
 	}
 }
 
+func taskImage(image string) func(*swarm.Task) {
+	return func(task *swarm.Task) {
+		task.Spec.ContainerSpec = &swarm.ContainerSpec{Image: image}
+	}
+}
+
+func taskLabels(labels map[string]string) func(*swarm.Task) {
+	return func(task *swarm.Task) {
+		task.Labels = labels
+	}
+}
+
+func taskReservations(nanoCPUs int64, memoryBytes int64) func(*swarm.Task) {
+	return func(task *swarm.Task) {
+		task.Spec.Resources = &swarm.ResourceRequirements{
+			Reservations: &swarm.Resources{NanoCPUs: nanoCPUs, MemoryBytes: memoryBytes},
+		}
+	}
+}
+
 func taskStatus(ops ...func(*swarm.TaskStatus)) func(*swarm.Task) {
 	return func(task *swarm.Task) {
 		status := &swarm.TaskStatus{}
@@ -147,6 +225,9 @@ func swarmService(ops ...func(*swarm.Service)) swarm.Service {
 			Annotations: swarm.Annotations{
 				Name: "defaultServiceName",
 			},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{},
+			},
 		},
 	}
 
@@ -169,6 +250,33 @@ func serviceLabels(labels map[string]string) func(service *swarm.Service) {
 	}
 }
 
+func serviceImage(image string) func(service *swarm.Service) {
+	return func(service *swarm.Service) {
+		service.Spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{Image: image}
+	}
+}
+
+func serviceEnv(env ...string) func(service *swarm.Service) {
+	return func(service *swarm.Service) {
+		if service.Spec.TaskTemplate.ContainerSpec == nil {
+			service.Spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{}
+		}
+		service.Spec.TaskTemplate.ContainerSpec.Env = env
+	}
+}
+
+func serviceUpdateState(state swarm.UpdateState) func(service *swarm.Service) {
+	return func(service *swarm.Service) {
+		service.UpdateStatus = &swarm.UpdateStatus{State: state}
+	}
+}
+
+func serviceUpdateOrder(order string) func(service *swarm.Service) {
+	return func(service *swarm.Service) {
+		service.Spec.UpdateConfig = &swarm.UpdateConfig{Order: order}
+	}
+}
+
 func withEndpoint(ops ...func(*swarm.Endpoint)) func(*swarm.Service) {
 	return func(service *swarm.Service) {
 		endpoint := &swarm.Endpoint{}
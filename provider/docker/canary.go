@@ -0,0 +1,99 @@
+package docker
+
+import (
+	"strconv"
+
+	"github.com/containous/traefik/log"
+)
+
+const (
+	labelCanaryService = "traefik.canary.service"
+	labelCanaryWeight  = "traefik.canary.weight"
+)
+
+// resolveCanaries marks the containers declared as the canary of another service, given by its name.
+// A canary joins the backend of its primary service, without its own frontend, and gets its weight (a percentage)
+// of the traffic of the backend. The canaries of a missing service, or with an invalid weight, stay standalone services.
+func resolveCanaries(containers []dockerData) []dockerData {
+	primaries := make(map[string]bool)
+	for _, container := range containers {
+		if _, ok := container.Labels[labelCanaryService]; !ok {
+			primaries[getServiceName(container)] = true
+		}
+	}
+
+	var result []dockerData
+	for _, container := range containers {
+		primary, ok := container.Labels[labelCanaryService]
+		if !ok {
+			result = append(result, container)
+			continue
+		}
+
+		// The canary labels must not be read as the weight of a "canary" segment.
+		labels := make(map[string]string, len(container.Labels))
+		for key, value := range container.Labels {
+			if key != labelCanaryService && key != labelCanaryWeight {
+				labels[key] = value
+			}
+		}
+
+		rawWeight := container.Labels[labelCanaryWeight]
+		weight, err := strconv.Atoi(rawWeight)
+		switch {
+		case err != nil || weight <= 0 || weight >= 100:
+			log.Warnf("Invalid value %q in label %s of the container %s, ignoring the canary: it must be a percentage between 1 and 99", rawWeight, labelCanaryWeight, container.Name)
+		case !primaries[primary]:
+			log.Warnf("The service %s of the canary container %s does not exist, ignoring the canary", primary, container.Name)
+		default:
+			container.CanaryOf = primary
+			container.CanaryWeight = weight
+		}
+
+		container.Labels = labels
+		result = append(result, container)
+	}
+	return result
+}
+
+// addCanaryServers adds the canary containers to the backend of their primary service.
+func addCanaryServers(servers map[string][]dockerData, serviceBackends map[string]string, canaries []dockerData) {
+	for _, canary := range canaries {
+		backendName, ok := serviceBackends[canary.CanaryOf]
+		if !ok {
+			log.Warnf("The service %s of the canary container %s has no default backend, ignoring the canary", canary.CanaryOf, canary.Name)
+			continue
+		}
+		servers[backendName] = append(servers[backendName], canary)
+	}
+}
+
+// getCanaryWeightRatios returns the ratios applied to the weights of the primary and canary servers of a backend,
+// for the canaries to get their share of the traffic whatever the number of servers on each side.
+func getCanaryWeightRatios(containers []dockerData) (int, int) {
+	var primaryCount, canaryCount, canaryWeight int
+	for _, container := range containers {
+		if container.CanaryWeight > 0 {
+			canaryCount++
+			canaryWeight = container.CanaryWeight
+		} else {
+			primaryCount++
+		}
+	}
+
+	if canaryCount == 0 || primaryCount == 0 {
+		return 1, 1
+	}
+
+	primaryRatio := (100 - canaryWeight) * canaryCount
+	canaryRatio := canaryWeight * primaryCount
+	divisor := gcd(primaryRatio, canaryRatio)
+	return primaryRatio / divisor, canaryRatio / divisor
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/containous/traefik/provider/label"
+	docker "github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwarmBuildConfigurationCanary(t *testing.T) {
+	networks := map[string]*docker.NetworkResource{
+		"1": {Name: "foo"},
+	}
+
+	testCases := []struct {
+		desc             string
+		canaryLabels     map[string]string
+		expectedBackends []string
+		expectedWeights  map[string]int
+	}{
+		{
+			desc: "canary of an existing service",
+			canaryLabels: map[string]string{
+				labelCanaryService: "web",
+				labelCanaryWeight:  "10",
+			},
+			expectedBackends: []string{"backend-web"},
+			expectedWeights: map[string]int{
+				"http://10.0.0.1:80": 9,
+				"http://10.0.0.2:80": 9,
+				"http://10.0.1.1:80": 2,
+			},
+		},
+		{
+			desc: "canary of a missing service",
+			canaryLabels: map[string]string{
+				labelCanaryService: "api",
+				labelCanaryWeight:  "10",
+			},
+			expectedBackends: []string{"backend-web", "backend-web-canary"},
+			expectedWeights: map[string]int{
+				"http://10.0.0.1:80": 1,
+				"http://10.0.0.2:80": 1,
+			},
+		},
+		{
+			desc: "canary with an invalid weight",
+			canaryLabels: map[string]string{
+				labelCanaryService: "web",
+				labelCanaryWeight:  "100",
+			},
+			expectedBackends: []string{"backend-web", "backend-web-canary"},
+			expectedWeights: map[string]int{
+				"http://10.0.0.1:80": 1,
+				"http://10.0.0.2:80": 1,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				Domain:           "docker.localhost",
+				ExposedByDefault: true,
+				SwarmMode:        true,
+			}
+
+			canaryLabels := map[string]string{label.TraefikPort: "80"}
+			for key, value := range test.canaryLabels {
+				canaryLabels[key] = value
+			}
+
+			web := provider.parseService(swarmService(serviceName("web"),
+				serviceLabels(map[string]string{label.TraefikPort: "80"}),
+				withEndpointSpec(modeDNSSR)), networks)
+			canary := provider.parseService(swarmService(serviceName("web-canary"),
+				serviceLabels(canaryLabels),
+				withEndpointSpec(modeDNSSR)), networks)
+
+			var dockerDataList []dockerData
+			for slot := 1; slot <= 2; slot++ {
+				task := swarmTask(fmt.Sprintf("web%d", slot),
+					taskSlot(slot),
+					taskNetworkAttachment("1", "foo", "overlay", []string{fmt.Sprintf("10.0.0.%d/24", slot)}))
+				dockerDataList = append(dockerDataList, parseTasks(task, web, networks, false))
+			}
+			task := swarmTask("canary1",
+				taskSlot(1),
+				taskNetworkAttachment("1", "foo", "overlay", []string{"10.0.1.1/24"}))
+			dockerDataList = append(dockerDataList, parseTasks(task, canary, networks, false))
+
+			config := provider.buildConfiguration(dockerDataList)
+			require.NotNil(t, config)
+
+			var backendNames []string
+			for backendName := range config.Backends {
+				backendNames = append(backendNames, backendName)
+			}
+			assert.ElementsMatch(t, test.expectedBackends, backendNames)
+			// A canary only gets its own frontend when it is ignored.
+			assert.Len(t, config.Frontends, len(test.expectedBackends))
+
+			weights := make(map[string]int)
+			for _, server := range config.Backends["backend-web"].Servers {
+				weights[server.URL] = server.Weight
+			}
+			assert.Equal(t, test.expectedWeights, weights)
+		})
+	}
+}
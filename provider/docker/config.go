@@ -1,21 +1,36 @@
 package docker
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/BurntSushi/ty/fun"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/provider"
 	"github.com/containous/traefik/provider/label"
 	"github.com/containous/traefik/types"
+	swarmtypes "github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
+	"github.com/sirupsen/logrus"
+	"github.com/vulcand/oxy/cbreaker"
+	"github.com/vulcand/oxy/utils"
 )
 
 const (
@@ -23,57 +38,118 @@ const (
 	labelBackendLoadBalancerSwarm = "traefik.backend.loadbalancer.swarm"
 	labelDockerComposeProject     = "com.docker.compose.project"
 	labelDockerComposeService     = "com.docker.compose.service"
+	labelBackendAddress           = "traefik.backend.address"
+	labelBackendServerURL         = "traefik.backend.server.url"
+	labelFrontendTLS              = "traefik.frontend.tls"
+	labelBackendMaintenance       = "traefik.backend.maintenance"
+	labelBackendFailover          = "traefik.backend.failover"
+	labelBackendFailoverPriority  = "traefik.backend.failover.priority"
+	labelBackendStickySourceIP    = "traefik.backend.loadbalancer.sticky.sourceip"
+	labelDefaultMiddlewares       = "traefik.frontend.defaultMiddlewares"
+	labelBackendTLSInsecure       = "traefik.backend.tls.insecureSkipVerify"
+	labelBackendTLSServerName     = "traefik.backend.tls.serverName"
+	labelMinReplicas              = "traefik.minReplicas"
+	labelBackendRetryAttempts     = "traefik.backend.retry.attempts"
+	labelBackendPortRange         = "traefik.backend.portRange"
+	labelBackendOrder             = "traefik.backend.order"
+	labelSuffixDisable            = "disable"
 )
 
+var disabledPortRegexp = regexp.MustCompile(`^traefik\.([0-9]+)\.disable$`)
+
+// headerNameRegexp matches the valid header names, made of the token characters of the RFC 7230.
+var headerNameRegexp = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// sizeRegexp matches the sizes of the buffering labels, in bytes or with a K, M or G suffix (e.g. 512K or 1M).
+var sizeRegexp = regexp.MustCompile(`^([0-9]+)\s*([kKmMgG])?[bB]?$`)
+
+var hostnameRegexp = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
 func (p *Provider) buildConfiguration(containersInspected []dockerData) *types.Configuration {
 	dockerFuncMap := template.FuncMap{
 		"getLabelValue":    label.GetStringValue,
 		"getSubDomain":     getSubDomain,
-		"isBackendLBSwarm": isBackendLBSwarm,
+		"isBackendLBSwarm": p.isBackendLBSwarm,
 		"getDomain":        label.GetFuncString(label.TraefikDomain, p.Domain),
 
 		// Backend functions
-		"getIPAddress":      p.getDeprecatedIPAddress, // TODO: Should we expose getIPPort instead?
-		"getServers":        p.getServers,
-		"getMaxConn":        label.GetMaxConn,
-		"getHealthCheck":    label.GetHealthCheck,
-		"getBuffering":      label.GetBuffering,
-		"getCircuitBreaker": label.GetCircuitBreaker,
-		"getLoadBalancer":   label.GetLoadBalancer,
+		"getIPAddress":          p.getDeprecatedIPAddress, // TODO: Should we expose getIPPort instead?
+		"getServers":            p.getServers,
+		"getMaxConn":            getMaxConn,
+		"getHealthCheck":        getHealthCheck,
+		"getBuffering":          getBuffering,
+		"getResponseForwarding": getResponseForwarding,
+		"getBackendTLS":         getBackendTLS,
+		"getRetry":              getRetry,
+		"getCircuitBreaker":     getCircuitBreaker,
+		"getLoadBalancer":       getLoadBalancer,
 
 		// Frontend functions
 		"getBackendName":    getBackendName,
-		"getPriority":       label.GetFuncInt(label.TraefikFrontendPriority, label.DefaultFrontendPriority),
-		"getPassHostHeader": label.GetFuncBool(label.TraefikFrontendPassHostHeader, label.DefaultPassHostHeader),
+		"getPriority":       getPriority,
+		"getPassHostHeader": p.getPassHostHeader,
 		"getPassTLSCert":    label.GetFuncBool(label.TraefikFrontendPassTLSCert, label.DefaultPassTLSCert),
-		"getEntryPoints":    label.GetFuncSliceString(label.TraefikFrontendEntryPoints),
+		"getEntryPoints":    p.getEntryPoints,
 		"getBasicAuth":      label.GetFuncSliceString(label.TraefikFrontendAuthBasic), // Deprecated
 		"getAuth":           label.GetAuth,
 		"getFrontendRule":   p.getFrontendRule,
-		"getRedirect":       label.GetRedirect,
+		"getRedirect":       p.getRedirect,
 		"getErrorPages":     label.GetErrorPages,
 		"getRateLimit":      label.GetRateLimit,
-		"getHeaders":        label.GetHeaders,
+		"getHeaders":        getHeaders,
 		"getWhiteList":      label.GetWhiteList,
 	}
 
+	containersInspected = p.addLabelsFromDirectory(containersInspected)
+	containersInspected = convertContainersTypedLabels(containersInspected)
+	containersInspected = p.rewriteServiceNames(containersInspected)
+
 	// filter containers
 	filteredContainers := fun.Filter(p.containerFilter, containersInspected).([]dockerData)
 
+	// The API lists the containers in any order: sorting them keeps the frontend names and the configuration stable.
+	sort.SliceStable(filteredContainers, func(i, j int) bool {
+		if filteredContainers[i].ServiceName != filteredContainers[j].ServiceName {
+			return filteredContainers[i].ServiceName < filteredContainers[j].ServiceName
+		}
+		return filteredContainers[i].Name < filteredContainers[j].Name
+	})
+
+	filteredContainers = resolveCanaries(filteredContainers)
+
 	frontends := map[string][]dockerData{}
 	servers := map[string][]dockerData{}
 
+	var canaries []dockerData
+	serviceBackends := make(map[string]string)
+
 	serviceNames := make(map[string]struct{})
+	serviceIndexes := make(map[string]int)
 
 	for idx, container := range filteredContainers {
+		if len(container.CanaryOf) > 0 {
+			// A canary has no frontend, its servers join the default backend of its primary service.
+			container.SegmentLabels = container.Labels
+			canaries = append(canaries, container)
+			continue
+		}
+
+		if p.SwarmMode {
+			// Scaling a service must not rename the frontends of the other services.
+			if _, exists := serviceIndexes[container.ServiceName]; !exists {
+				serviceIndexes[container.ServiceName] = len(serviceIndexes)
+			}
+			idx = serviceIndexes[container.ServiceName]
+		}
+
 		segmentProperties := label.ExtractTraefikLabels(container.Labels)
 		for segmentName, labels := range segmentProperties {
-			container.SegmentLabels = labels
+			container.SegmentLabels = p.addDefaultMiddlewares(labels)
 			container.SegmentName = segmentName
 
 			serviceNamesKey := getServiceNameKey(container, p.SwarmMode, segmentName)
 
-			if _, exists := serviceNames[serviceNamesKey]; !exists {
+			if _, exists := serviceNames[serviceNamesKey]; !exists && p.hasReachableEntryPoints(container) && p.hasValidFrontendRule(container) {
 				frontendName := p.getFrontendName(container, idx)
 				frontends[frontendName] = append(frontends[frontendName], container)
 				if len(serviceNamesKey) > 0 {
@@ -83,12 +159,24 @@ func (p *Provider) buildConfiguration(containersInspected []dockerData) *types.C
 
 			// Backends
 			backendName := getBackendName(container)
+			if _, exists := serviceBackends[getServiceName(container)]; !exists && len(segmentName) == 0 {
+				serviceBackends[getServiceName(container)] = backendName
+			}
 
 			// Servers
 			servers[backendName] = append(servers[backendName], container)
 		}
 	}
 
+	p.checkDuplicateFrontendRules(frontends)
+
+	// The backend conflicts are checked against the first container once sorted, which gives the backend labels.
+	if p.SortServersByOrder {
+		sortServersByOrder(servers)
+	}
+	checkBackendConflicts(servers)
+	addCanaryServers(servers, serviceBackends, canaries)
+
 	templateObjects := struct {
 		Containers []dockerData
 		Frontends  map[string][]dockerData
@@ -109,6 +197,78 @@ func (p *Provider) buildConfiguration(containersInspected []dockerData) *types.C
 	return configuration
 }
 
+// checkDuplicateFrontendRules warns about the frontends sharing the same rule,
+// and removes them in strict mode instead of letting one of them win arbitrarily.
+func (p *Provider) checkDuplicateFrontendRules(frontends map[string][]dockerData) {
+	frontendsByRule := make(map[string][]string)
+	for frontendName, containers := range frontends {
+		container := containers[0]
+		rule := p.getFrontendRule(container, container.SegmentLabels)
+		frontendsByRule[rule] = append(frontendsByRule[rule], frontendName)
+	}
+
+	for rule, frontendNames := range frontendsByRule {
+		if len(frontendNames) < 2 {
+			continue
+		}
+
+		sort.Strings(frontendNames)
+
+		var containerNames []string
+		for _, frontendName := range frontendNames {
+			containerNames = append(containerNames, frontends[frontendName][0].Name)
+		}
+
+		if p.StrictRules {
+			log.Warnf("Duplicate frontend rule %q in containers %s: ignoring frontends %s", rule, strings.Join(containerNames, ", "), strings.Join(frontendNames, ", "))
+			for _, frontendName := range frontendNames {
+				delete(frontends, frontendName)
+			}
+		} else {
+			log.Warnf("Duplicate frontend rule %q in containers %s: frontends %s are conflicting", rule, strings.Join(containerNames, ", "), strings.Join(frontendNames, ", "))
+		}
+	}
+}
+
+// backendLabelPrefixes are the prefixes of the labels configuring a backend itself, rather than its servers.
+var backendLabelPrefixes = []string{
+	label.TraefikBackendCircuitBreaker,
+	label.Prefix + "backend.healthcheck.",
+	label.TraefikBackendLoadBalancer,
+	label.Prefix + "backend.maxconn.",
+	label.TraefikBackendBuffering,
+	label.Prefix + "backend.responseForwarding.",
+	label.Prefix + "backend.retry.",
+	label.Prefix + "backend.tls.",
+}
+
+// checkBackendConflicts warns about the containers sharing a backend with different backend labels,
+// as only the labels of the first container are used.
+func checkBackendConflicts(servers map[string][]dockerData) {
+	for backendName, containers := range servers {
+		reference := getBackendLabels(containers[0].SegmentLabels)
+		for _, container := range containers[1:] {
+			if !reflect.DeepEqual(reference, getBackendLabels(container.SegmentLabels)) {
+				log.Warnf("Containers %s and %s of backend %s have different backend labels: using the ones of %s", containers[0].Name, container.Name, backendName, containers[0].Name)
+				break
+			}
+		}
+	}
+}
+
+func getBackendLabels(labels map[string]string) map[string]string {
+	backendLabels := make(map[string]string)
+	for key, value := range labels {
+		for _, prefix := range backendLabelPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				backendLabels[key] = value
+				break
+			}
+		}
+	}
+	return backendLabels
+}
+
 func getServiceNameKey(container dockerData, swarmMode bool, segmentName string) string {
 	if swarmMode {
 		return container.ServiceName + segmentName
@@ -117,12 +277,39 @@ func getServiceNameKey(container dockerData, swarmMode bool, segmentName string)
 	return getServiceName(container) + segmentName
 }
 
+// noPortReason starts the filter reasons of the containers without usable port.
+const noPortReason = "no port"
+
+var errPortLabelMissing = fmt.Errorf("port label is missing, please use %s as default value or define port label for all segments ('traefik.<segment_name>.port')", label.TraefikPort)
+
 func (p *Provider) containerFilter(container dockerData) bool {
-	if !label.IsEnabled(container.Labels, p.ExposedByDefault) {
-		log.Debugf("Filtering disabled container %s", container.Name)
+	if reason, warning := p.getFilterReason(container); len(reason) > 0 {
+		if warning {
+			log.Warnf("Filtering container %s: %s", container.Name, reason)
+		} else {
+			log.Debugf("Filtering container %s: %s", container.Name, reason)
+		}
 		return false
 	}
 
+	return true
+}
+
+// getFilterReason returns why the container is not exposed, or an empty string if it is,
+// and whether it is worth a warning: the container is likely misconfigured, or failing.
+func (p *Provider) getFilterReason(container dockerData) (string, bool) {
+	if pattern, ok := p.NeverExpose.Match(strings.TrimPrefix(container.Name, "/"), container.ServiceName, container.Image); ok {
+		return fmt.Sprintf("never exposed, matching %q", pattern), false
+	}
+
+	if !label.IsEnabled(container.Labels, p.ExposedByDefault) {
+		return "disabled container", false
+	}
+
+	if p.SelfExclude && p.isSelf(container) && !label.GetBoolValue(container.Labels, label.TraefikEnable, false) {
+		return fmt.Sprintf("container of Traefik itself, set the %s label to true to expose it", label.TraefikEnable), false
+	}
+
 	segmentProperties := label.ExtractTraefikLabels(container.Labels)
 
 	var errPort error
@@ -130,30 +317,67 @@ func (p *Provider) containerFilter(container dockerData) bool {
 		errPort = checkSegmentPort(labels, segmentName)
 
 		if len(p.getFrontendRule(container, labels)) == 0 {
-			log.Debugf("Filtering container with empty frontend rule %s %s", container.Name, segmentName)
-			return false
+			return fmt.Sprintf("empty frontend rule %s", segmentName), false
 		}
 	}
 
-	if len(container.NetworkSettings.Ports) == 0 && errPort != nil {
-		log.Debugf("Filtering container without port, %s: %v", container.Name, errPort)
-		return false
-	}
+	checkDisabledPorts(container)
 
-	constraintTags := label.SplitAndTrimString(container.Labels[label.TraefikTags], ",")
-	if ok, failingConstraint := p.MatchConstraints(constraintTags); !ok {
-		if failingConstraint != nil {
-			log.Debugf("Container %s pruned by %q constraint", container.Name, failingConstraint.String())
+	if len(getPort(container)) == 0 && errPort != nil && !hasServerURL(container.Labels) {
+		if container.NetworkSettings.NetworkMode.IsHost() {
+			// No port is published on the host network: the one the application listens on must be given.
+			return fmt.Sprintf("%s, the containers on the host network need the %s label", noPortReason, label.TraefikPort), true
 		}
-		return false
+		if errPort == errPortLabelMissing {
+			return fmt.Sprintf("%s, none is published nor exposed: set the %s label", noPortReason, label.TraefikPort), true
+		}
+		return fmt.Sprintf("%s, %v", noPortReason, errPort), true
+	}
+
+	if ok, reason := p.matchConstraints(container); !ok {
+		return reason, false
 	}
 
-	if container.Health != "" && container.Health != "healthy" {
-		log.Debugf("Filtering unhealthy or starting container %s", container.Name)
+	if p.MaxRestartCount > 0 && container.RestartCount > p.MaxRestartCount {
+		return fmt.Sprintf("restarted %d times (max %d), it may be in a crash loop", container.RestartCount, p.MaxRestartCount), true
+	}
+
+	if !p.isHealthRoutable(container) {
+		return "unhealthy or starting container", false
+	}
+
+	return "", false
+}
+
+// isHealthRoutable tells if the health status of the container allows to route to it, according to the health policy.
+func (p *Provider) isHealthRoutable(container dockerData) bool {
+	switch {
+	case container.Health == "" || container.Health == "healthy" || p.HealthPolicy == healthPolicyIgnore:
+		return true
+	case p.HealthPolicy == healthPolicyDegraded:
+		// A starting container may not serve yet, unlike an unhealthy one.
+		return container.Health == "unhealthy"
+	default:
 		return false
 	}
+}
 
-	return true
+// isDegraded tells if the container is routed despite being unhealthy, so with a reduced weight.
+func (p *Provider) isDegraded(container dockerData) bool {
+	return p.HealthPolicy == healthPolicyDegraded && container.Health == "unhealthy"
+}
+
+// getHealthRouting tells how a container with this health status is routed: not at all, normally or degraded.
+func (p *Provider) getHealthRouting(health string) string {
+	container := dockerData{Health: health}
+	switch {
+	case !p.isHealthRoutable(container):
+		return "excluded"
+	case p.isDegraded(container):
+		return "degraded"
+	default:
+		return "routed"
+	}
 }
 
 func checkSegmentPort(labels map[string]string, segmentName string) error {
@@ -163,7 +387,7 @@ func checkSegmentPort(labels map[string]string, segmentName string) error {
 			return fmt.Errorf("invalid port value %q for the segment %q: %v", port, segmentName, err)
 		}
 	} else {
-		return fmt.Errorf("port label is missing, please use %s as default value or define port label for all segments ('traefik.<segment_name>.port')", label.TraefikPort)
+		return errPortLabelMissing
 	}
 	return nil
 }
@@ -184,25 +408,231 @@ func (p *Provider) getFrontendRule(container dockerData, segmentLabels map[strin
 		return value
 	}
 
-	domain := label.GetStringValue(segmentLabels, label.TraefikDomain, p.Domain)
+	// The domain of a segment defaults to the one of its container.
+	domain := label.GetStringValue(segmentLabels, label.TraefikDomain, label.GetStringValue(container.Labels, label.TraefikDomain, p.Domain))
+
+	if p.defaultRuleTemplate != nil {
+		return p.getDefaultRule(container, domain)
+	}
 
 	if values, err := label.GetStringMultipleStrict(container.Labels, labelDockerComposeProject, labelDockerComposeService); err == nil {
-		return "Host:" + getSubDomain(values[labelDockerComposeService]+"."+values[labelDockerComposeProject]) + "." + domain
+		return "Host:" + p.getHosts(container, getSubDomain(values[labelDockerComposeService]+"."+values[labelDockerComposeProject]), domain)
 	}
 
 	if len(domain) > 0 {
-		return "Host:" + getSubDomain(container.ServiceName) + "." + domain
+		return "Host:" + p.getHosts(container, getSubDomain(container.ServiceName), domain)
 	}
 
 	return ""
 }
 
-func (p Provider) getIPAddress(container dockerData) string {
+// frontendRuleValidators check the arguments of the matchers of the frontend rules, by matcher name.
+// The matchers without a validator are passed through.
+var frontendRuleValidators = map[string]func(args []string) error{
+	"Headers":       checkHeadersArgs,
+	"HeadersRegexp": checkHeadersRegexpArgs,
+}
+
+// hasValidFrontendRule is false when the frontend rule label of the container has a malformed matcher:
+// the router built from it would not match any request.
+func (p *Provider) hasValidFrontendRule(container dockerData) bool {
+	rule := label.GetStringValue(container.SegmentLabels, label.TraefikFrontendRule, "")
+	if len(rule) == 0 {
+		return true
+	}
+
+	if err := checkFrontendRule(rule); err != nil {
+		log.Warnf("Ignoring the frontend of the container %s: invalid rule %q in label %s: %v", container.Name, rule, label.TraefikFrontendRule, err)
+		return false
+	}
+	return true
+}
+
+// checkFrontendRule checks the matchers of a frontend rule, e.g. Host:foo.bar;Headers:X-Foo,bar,
+// split as the rules parser does.
+func checkFrontendRule(rule string) error {
+	for _, matcher := range strings.Split(rule, ";") {
+		if len(strings.TrimSpace(matcher)) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(matcher, ":", 2)
+		validator, ok := frontendRuleValidators[strings.TrimSpace(parts[0])]
+		if !ok {
+			continue
+		}
+
+		if len(parts) != 2 {
+			return fmt.Errorf("missing arguments of the matcher %s", strings.TrimSpace(parts[0]))
+		}
+
+		var args []string
+		for _, arg := range strings.Split(parts[1], ",") {
+			if arg = strings.TrimSpace(arg); len(arg) > 0 {
+				args = append(args, arg)
+			}
+		}
+
+		if err := validator(args); err != nil {
+			return fmt.Errorf("matcher %s: %v", strings.TrimSpace(parts[0]), err)
+		}
+	}
+	return nil
+}
+
+// checkHeadersArgs checks the arguments of the Headers matcher, pairs of a header name and its value.
+func checkHeadersArgs(args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errors.New("the arguments must be pairs of a header name and a value")
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		if !headerNameRegexp.MatchString(args[i]) {
+			return fmt.Errorf("invalid header name %q", args[i])
+		}
+	}
+	return nil
+}
+
+// checkHeadersRegexpArgs checks the arguments of the HeadersRegexp matcher, pairs of a header name and a regular expression.
+func checkHeadersRegexpArgs(args []string) error {
+	if err := checkHeadersArgs(args); err != nil {
+		return err
+	}
+
+	for i := 1; i < len(args); i += 2 {
+		if _, err := regexp.Compile(args[i]); err != nil {
+			return fmt.Errorf("invalid regular expression %q: %v", args[i], err)
+		}
+	}
+	return nil
+}
+
+// getHosts returns the host of the default frontend rule, followed by the ones of the network aliases of the container
+// when the UseNetworkAliases option is set, e.g. web.docker.localhost,db.docker.localhost.
+func (p *Provider) getHosts(container dockerData, subDomain string, domain string) string {
+	hosts := []string{subDomain + "." + domain}
+	if !p.UseNetworkAliases {
+		return hosts[0]
+	}
+
+	seen := map[string]bool{hosts[0]: true}
+	for _, alias := range getNetworkAliases(container) {
+		host := getSubDomain(alias) + "." + domain
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	return strings.Join(hosts, ",")
+}
+
+// getNetworkAliases returns the sorted aliases of the container in all its networks,
+// without the short ID of the container which docker adds as an alias.
+func getNetworkAliases(container dockerData) []string {
+	var aliases []string
+	for _, network := range container.NetworkSettings.Networks {
+		for _, alias := range network.Aliases {
+			if len(alias) == 0 || (len(container.ID) > 0 && strings.HasPrefix(container.ID, alias)) {
+				continue
+			}
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// getSortedPorts returns the distinct port numbers of the container, whatever their protocol.
+func getSortedPorts(container dockerData) []int {
+	var ports []int
+	seen := make(map[int]struct{})
+	for port := range container.NetworkSettings.Ports {
+		if _, ok := seen[port.Int()]; ok {
+			continue
+		}
+		seen[port.Int()] = struct{}{}
+		ports = append(ports, port.Int())
+	}
+
+	sort.Ints(ports)
+	return ports
+}
+
+func (p *Provider) getDefaultRule(container dockerData, domain string) string {
+	templateObjects := struct {
+		Name        string
+		ServiceName string
+		Labels      map[string]string
+		Domain      string
+		Ports       []int
+	}{
+		Name:        strings.TrimPrefix(container.Name, "/"),
+		ServiceName: container.ServiceName,
+		Labels:      container.Labels,
+		Domain:      domain,
+		Ports:       getSortedPorts(container),
+	}
+
+	var buffer bytes.Buffer
+	if err := p.defaultRuleTemplate.Execute(&buffer, templateObjects); err != nil {
+		log.Errorf("Failed to execute the default rule template for container %s: %v", container.Name, err)
+		return ""
+	}
+
+	return buffer.String()
+}
+
+// findNetwork selects a network by its ID first, then by its name.
+// The value can also be given as name@ID, to keep the name readable while selecting by ID.
+func findNetwork(networks map[string]*networkData, value string, containerName string) *networkData {
+	name, id := value, value
+	if idx := strings.LastIndex(value, "@"); idx >= 0 {
+		name, id = value[:idx], value[idx+1:]
+	}
+
+	for _, network := range networks {
+		if len(network.ID) > 0 && network.ID == id {
+			return network
+		}
+	}
+
+	network, ok := networks[name]
+	if !ok {
+		return nil
+	}
+
+	for _, other := range networks {
+		if other.Name == network.Name && other.ID != network.ID {
+			log.Infof("Several networks are named %s for container %s, using the network %s: set its ID in the %s label to select another one", name, containerName, network.ID, labelDockerNetwork)
+			break
+		}
+	}
+
+	return network
+}
+
+func (p *Provider) getIPAddress(container dockerData) string {
+	if value := label.GetStringValue(container.Labels, labelBackendAddress, ""); value != "" {
+		// The port is added later: an IPv6 address can be given with or without brackets.
+		address := strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+		if isValidAddress(address) {
+			return address
+		}
+
+		log.Warnf("Invalid address %q in label %s for container %q, it must be an IP or a hostname: using the discovered address.", value, labelBackendAddress, container.Name)
+	}
+
 	if value := label.GetStringValue(container.Labels, labelDockerNetwork, p.Network); value != "" {
 		networkSettings := container.NetworkSettings
 		if networkSettings.Networks != nil {
-			network := networkSettings.Networks[value]
+			network := findNetwork(networkSettings.Networks, value, container.Name)
 			if network != nil {
+				reason := "default network"
+				if label.Has(container.Labels, labelDockerNetwork) {
+					reason = "label " + labelDockerNetwork
+				}
+				logNetworkChoice(container, network.Name, reason)
 				return network.Addr
 			}
 
@@ -211,38 +641,128 @@ func (p Provider) getIPAddress(container dockerData) string {
 	}
 
 	if container.NetworkSettings.NetworkMode.IsHost() {
-		if container.Node != nil {
-			if container.Node.IPAddress != "" {
-				return container.Node.IPAddress
-			}
-		}
-		return "127.0.0.1"
+		ip, reason := p.getHostModeIP(container)
+		logNetworkChoice(container, "host", reason)
+		return ip
 	}
 
 	if container.NetworkSettings.NetworkMode.IsContainer() {
-		dockerClient, err := p.createClient()
+		dockerClient, err := p.getContainerClient(container)
 		if err != nil {
 			log.Warnf("Unable to get IP address for container %s, error: %s", container.Name, err)
 			return ""
 		}
 
 		connectedContainer := container.NetworkSettings.NetworkMode.ConnectedContainer()
-		containerInspected, err := dockerClient.ContainerInspect(context.Background(), connectedContainer)
+		ctx, cancel := p.apiContext(context.Background())
+		containerInspected, err := dockerClient.ContainerInspect(ctx, connectedContainer)
+		cancel()
 		if err != nil {
 			log.Warnf("Unable to get IP address for container %s : Failed to inspect container ID %s, error: %s", container.Name, connectedContainer, err)
 			return ""
 		}
-		return p.getIPAddress(parseContainer(containerInspected))
+		connectedData := parseContainer(containerInspected)
+		connectedData.Labels = p.normalizeLabels(connectedData.Labels)
+		connectedData.Host = container.Host
+		log.Debugf("Network of the container %s: the one of the container %s (container network mode)", container.Name, connectedData.Name)
+		return p.getIPAddress(connectedData)
 	}
 
-	for _, network := range container.NetworkSettings.Networks {
-		return network.Addr
+	// The networks are sorted so that the choice does not change from one configuration to the next.
+	names := sortedNetworkNames(container.NetworkSettings.Networks)
+	if len(names) > 0 {
+		reason := "single network"
+		if len(names) > 1 {
+			reason = "first network by name, no network label nor default network matching"
+			if container.NetworkSettings.Networks[names[len(names)-1]].Ingress && !container.NetworkSettings.Networks[names[0]].Ingress {
+				reason = "first network by name other than ingress, no network label nor default network matching"
+			}
+		}
+		logNetworkChoice(container, names[0], reason)
+		return container.NetworkSettings.Networks[names[0]].Addr
 	}
 
 	log.Warnf("Unable to find the IP address for the container %q.", container.Name)
 	return ""
 }
 
+// getContainerClient returns a client of the daemon running the container: the one of its host when listed in the hosts file.
+func (p *Provider) getContainerClient(container dockerData) (client.APIClient, error) {
+	if len(container.Host) > 0 {
+		return p.getHostClient(container.Host)
+	}
+
+	p.networkModeMu.Lock()
+	defer p.networkModeMu.Unlock()
+
+	// The client is kept between the builds, its API version being negotiated once.
+	if p.networkModeClient == nil {
+		dockerClient, err := p.getClient()
+		if err != nil {
+			return nil, err
+		}
+		p.negotiateAPIVersion(context.Background(), dockerClient)
+		p.networkModeClient = dockerClient
+	}
+	return p.networkModeClient, nil
+}
+
+// getHostModeIP returns the address of the host of a container on the host network, and how it was found.
+func (p *Provider) getHostModeIP(container dockerData) (string, string) {
+	if container.Node != nil && container.Node.IPAddress != "" {
+		return container.Node.IPAddress, "host network mode, address of the node"
+	}
+
+	if len(container.Host) > 0 {
+		if host := getHostAddress(container.Host); len(host) > 0 {
+			return host, "host network mode, address of the docker host"
+		}
+		return "127.0.0.1", "host network mode, local docker host"
+	}
+
+	// A remote daemon runs the container on its own host, not on the one of Traefik.
+	if hostURL, err := client.ParseHostURL(p.Endpoint); err == nil && hostURL.Scheme == "tcp" {
+		if host, _, err := net.SplitHostPort(hostURL.Host); err == nil && len(host) > 0 {
+			return host, "host network mode, address of the docker endpoint"
+		}
+	}
+
+	return "127.0.0.1", "host network mode, local docker daemon"
+}
+
+func sortedNetworkNames(networks map[string]*networkData) []string {
+	var names []string
+	for name := range networks {
+		names = append(names, name)
+	}
+
+	// The ingress network comes last: its VIP is meant for the routing mesh, the app networks are preferred.
+	sort.Slice(names, func(i, j int) bool {
+		if networks[names[i]].Ingress != networks[names[j]].Ingress {
+			return !networks[names[i]].Ingress
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// logNetworkChoice logs the network chosen for the address of the container, why, and the other candidates.
+func logNetworkChoice(container dockerData, chosen, reason string) {
+	if log.GetLevel() < logrus.DebugLevel {
+		return
+	}
+
+	var candidates []string
+	for _, name := range sortedNetworkNames(container.NetworkSettings.Networks) {
+		candidates = append(candidates, fmt.Sprintf("%s (%s)", name, container.NetworkSettings.Networks[name].Addr))
+	}
+	log.Debugf("Network of the container %s: %s, chosen by %s among [%s]", container.Name, chosen, reason, strings.Join(candidates, ", "))
+}
+
+func isValidAddress(address string) bool {
+	return net.ParseIP(address) != nil || hostnameRegexp.MatchString(address)
+}
+
 // Deprecated: Please use getIPPort instead
 func (p *Provider) getDeprecatedIPAddress(container dockerData) string {
 	ip, _, err := p.getIPPort(container)
@@ -258,8 +778,8 @@ func getSubDomain(name string) string {
 	return strings.Replace(strings.Replace(strings.TrimPrefix(name, "/"), "/", "-", -1), "_", "-", -1)
 }
 
-func isBackendLBSwarm(container dockerData) bool {
-	return label.GetBoolValue(container.Labels, labelBackendLoadBalancerSwarm, false)
+func (p *Provider) isBackendLBSwarm(container dockerData) bool {
+	return label.GetBoolValue(container.Labels, labelBackendLoadBalancerSwarm, p.SwarmLoadBalancer)
 }
 
 func getBackendName(container dockerData) string {
@@ -288,6 +808,10 @@ func getDefaultBackendName(container dockerData) string {
 }
 
 func getServiceName(container dockerData) string {
+	if len(container.RewrittenName) > 0 {
+		return container.RewrittenName
+	}
+
 	serviceName := container.ServiceName
 
 	if values, err := label.GetStringMultipleStrict(container.Labels, labelDockerComposeProject, labelDockerComposeService); err == nil {
@@ -297,103 +821,930 @@ func getServiceName(container dockerData) string {
 	return serviceName
 }
 
-func getPort(container dockerData) string {
-	if value := label.GetStringValue(container.SegmentLabels, label.TraefikPort, ""); len(value) != 0 {
-		return value
+// getRawLabelName returns the name of the label set on the containers for the given traefik label,
+// i.e. with the label prefix of this instance.
+func (p *Provider) getRawLabelName(name string) string {
+	if len(p.LabelPrefix) == 0 {
+		return name
 	}
+	return p.LabelPrefix + "." + strings.TrimPrefix(name, label.Prefix)
+}
 
-	// See iteration order in https://blog.golang.org/go-maps-in-action
-	var ports []nat.Port
-	for port := range container.NetworkSettings.Ports {
-		ports = append(ports, port)
+// normalizeLabels renames the labels using the custom prefix to the default traefik prefix,
+// and drops the labels using the default prefix as they belong to another instance.
+func (p *Provider) normalizeLabels(labels map[string]string) map[string]string {
+	if len(p.LabelPrefix) == 0 || p.LabelPrefix+"." == label.Prefix {
+		return labels
 	}
 
-	less := func(i, j nat.Port) bool {
-		return i.Int() < j.Int()
+	prefix := p.LabelPrefix + "."
+
+	normalized := make(map[string]string, len(labels))
+	for key, value := range labels {
+		switch {
+		case strings.HasPrefix(key, prefix):
+			normalized[label.Prefix+strings.TrimPrefix(key, prefix)] = value
+		case strings.HasPrefix(key, label.Prefix):
+			continue
+		default:
+			normalized[key] = value
+		}
 	}
-	nat.Sort(ports, less)
+	return normalized
+}
 
-	if len(ports) > 0 {
-		min := ports[0]
-		return min.Port()
+// getHealthCheck validates the health check labels, an invalid health check is ignored without ignoring the backend.
+func getHealthCheck(labels map[string]string) *types.HealthCheck {
+	if len(label.GetStringValue(labels, label.TraefikBackendHealthCheckPath, "")) == 0 {
+		return nil
 	}
 
-	return ""
-}
+	if value := label.GetStringValue(labels, label.TraefikBackendHealthCheckInterval, ""); len(value) > 0 {
+		if _, err := time.ParseDuration(value); err != nil {
+			log.Warnf("Invalid value %q in label %s, ignoring the health check: %v", value, label.TraefikBackendHealthCheckInterval, err)
+			return nil
+		}
+	}
 
-func (p *Provider) getPortBinding(container dockerData) (*nat.PortBinding, error) {
-	port := getPort(container)
-	for netPort, portBindings := range container.NetworkSettings.Ports {
-		if strings.EqualFold(string(netPort), port+"/TCP") || strings.EqualFold(string(netPort), port+"/UDP") {
-			for _, p := range portBindings {
-				return &p, nil
-			}
+	if value := label.GetStringValue(labels, label.TraefikBackendHealthCheckPort, ""); len(value) > 0 {
+		if port, err := strconv.Atoi(value); err != nil || port <= 0 || port > 65535 {
+			log.Warnf("Invalid value %q in label %s, ignoring the health check: it must be a port number", value, label.TraefikBackendHealthCheckPort)
+			return nil
 		}
 	}
 
-	return nil, fmt.Errorf("unable to find the external IP:Port for the container %q", container.Name)
+	if value := label.GetStringValue(labels, label.TraefikBackendHealthCheckScheme, ""); len(value) > 0 && value != "http" && value != "https" {
+		log.Warnf("Invalid value %q in label %s, ignoring the health check: it must be http or https", value, label.TraefikBackendHealthCheckScheme)
+		return nil
+	}
+
+	return label.GetHealthCheck(labels)
 }
 
-func (p *Provider) getIPPort(container dockerData) (string, string, error) {
-	var ip, port string
+// getMaxConn validates the max connections labels: the limit is ignored when the amount is not a positive integer
+// or the extractor function is unknown, instead of failing when the configuration is loaded.
+func getMaxConn(labels map[string]string) *types.MaxConn {
+	if !label.Has(labels, label.TraefikBackendMaxConnAmount) {
+		return nil
+	}
 
-	if p.UseBindPortIP {
-		portBinding, err := p.getPortBinding(container)
-		if err != nil {
-			return "", "", fmt.Errorf("unable to find a binding for the container %q: ignoring server", container.Name)
-		}
+	rawAmount := labels[label.TraefikBackendMaxConnAmount]
+	amount, err := strconv.ParseInt(strings.TrimSpace(rawAmount), 10, 64)
+	if err != nil || amount <= 0 {
+		log.Warnf("Invalid value %q in label %s, ignoring the max connections: it must be a positive integer", rawAmount, label.TraefikBackendMaxConnAmount)
+		return nil
+	}
 
-		if portBinding.HostIP == "0.0.0.0" {
-			return "", "", fmt.Errorf("cannot determine the IP address (got 0.0.0.0) for the container %q: ignoring server", container.Name)
-		}
+	extractorFunc := strings.TrimSpace(label.GetStringValue(labels, label.TraefikBackendMaxConnExtractorFunc, label.DefaultBackendMaxconnExtractorFunc))
+	if !isValidExtractorFunc(extractorFunc) {
+		log.Warnf("Invalid value %q in label %s, ignoring the max connections: it must be client.ip, request.host or request.header.<name>", extractorFunc, label.TraefikBackendMaxConnExtractorFunc)
+		return nil
+	}
 
-		ip = portBinding.HostIP
-		port = portBinding.HostPort
+	return &types.MaxConn{
+		Amount:        amount,
+		ExtractorFunc: extractorFunc,
+	}
+}
 
-	} else {
-		ip = p.getIPAddress(container)
-		port = getPort(container)
+func isValidExtractorFunc(extractorFunc string) bool {
+	if _, err := utils.NewExtractor(extractorFunc); err != nil {
+		return false
 	}
 
-	if len(ip) == 0 {
-		return "", "", fmt.Errorf("unable to find the IP address for the container %q: the server is ignored", container.Name)
+	// The header name is written in a TOML string of the template.
+	if strings.HasPrefix(extractorFunc, "request.header.") {
+		return headerNameRegexp.MatchString(strings.TrimPrefix(extractorFunc, "request.header."))
 	}
-	return ip, port, nil
+	return true
 }
 
-func (p *Provider) getServers(containers []dockerData) map[string]types.Server {
-	var servers map[string]types.Server
+// getCircuitBreaker validates the circuit breaker expression label: the backend is kept without circuit breaker
+// when the expression is invalid, instead of failing when the configuration is loaded.
+func getCircuitBreaker(labels map[string]string) *types.CircuitBreaker {
+	circuitBreaker := label.GetCircuitBreaker(labels)
+	if circuitBreaker == nil {
+		return nil
+	}
 
-	for _, container := range containers {
-		ip, port, err := p.getIPPort(container)
-		if err != nil {
-			log.Warn(err)
-			continue
+	// The expression is written in a TOML string of the template.
+	if strings.ContainsAny(circuitBreaker.Expression, "\"\\\n") {
+		log.Warnf("Invalid expression %q in label %s, ignoring the circuit breaker: quotes and line breaks are not allowed", circuitBreaker.Expression, label.TraefikBackendCircuitBreakerExpression)
+		return nil
+	}
+
+	if _, err := cbreaker.New(http.NotFoundHandler(), circuitBreaker.Expression); err != nil {
+		log.Warnf("Invalid expression %q in label %s, ignoring the circuit breaker: %v", circuitBreaker.Expression, label.TraefikBackendCircuitBreakerExpression, err)
+		return nil
+	}
+	return circuitBreaker
+}
+
+// getRedirect validates the redirect labels: the frontend is kept without redirect when its entry point is unknown
+// or its regular expression invalid, instead of failing on every request.
+func (p *Provider) getRedirect(labels map[string]string) *types.Redirect {
+	redirect := label.GetRedirect(labels)
+	if redirect == nil {
+		return nil
+	}
+
+	if len(redirect.EntryPoint) > 0 {
+		if len(p.EntryPoints) > 0 && !hasEntryPoint(p.EntryPoints, redirect.EntryPoint) {
+			log.Warnf("Unknown entry point %q in label %s, ignoring the redirect", redirect.EntryPoint, label.TraefikFrontendRedirectEntryPoint)
+			return nil
+		}
+		return redirect
+	}
+
+	regex, err := decodeTemplateString(redirect.Regex)
+	if err == nil {
+		_, err = regexp.Compile(regex)
+	}
+	if err != nil {
+		log.Warnf("Invalid regular expression %q in label %s, ignoring the redirect: %v", redirect.Regex, label.TraefikFrontendRedirectRegex, err)
+		return nil
+	}
+
+	if _, err = decodeTemplateString(redirect.Replacement); err != nil {
+		log.Warnf("Invalid replacement %q in label %s, ignoring the redirect: %v", redirect.Replacement, label.TraefikFrontendRedirectReplacement, err)
+		return nil
+	}
+	return redirect
+}
+
+// decodeTemplateString returns the value of a label as read from a TOML string of the template, which unescapes it (e.g. \\. for \.).
+func decodeTemplateString(value string) (string, error) {
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"', '\n', '\r':
+			return "", errors.New("unescaped quotes and line breaks are not allowed")
+		}
+	}
+
+	var decoded struct{ Value string }
+	if _, err := toml.Decode("value = \""+value+"\"", &decoded); err != nil {
+		return "", err
+	}
+	return decoded.Value, nil
+}
+
+// getBuffering reads the sizes of the buffering labels, which accept a K, M or G suffix (powers of 1024, e.g. 512K or 1M).
+// An invalid size is ignored, the buffering keeping its default value.
+func getBuffering(labels map[string]string) *types.Buffering {
+	return label.GetBuffering(getBufferingSizeLabels(labels))
+}
+
+// getBufferingSizeLabels returns the labels with the sizes of the buffering converted to bytes, and without the invalid ones.
+func getBufferingSizeLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+	for key, value := range labels {
+		result[key] = value
+	}
+
+	for _, name := range []string{
+		label.TraefikBackendBufferingMaxRequestBodyBytes,
+		label.TraefikBackendBufferingMemRequestBodyBytes,
+		label.TraefikBackendBufferingMaxResponseBodyBytes,
+		label.TraefikBackendBufferingMemResponseBodyBytes,
+	} {
+		value, ok := labels[name]
+		if !ok {
+			continue
+		}
+
+		size, err := parseSize(value)
+		if err != nil {
+			log.Warnf("Invalid size %q in label %s, ignoring it: %v", value, name, err)
+			delete(result, name)
+			continue
+		}
+		result[name] = strconv.FormatInt(size, 10)
+	}
+	return result
+}
+
+// parseSize parses a size in bytes, with an optional K, M or G suffix (powers of 1024).
+func parseSize(value string) (int64, error) {
+	matches := sizeRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return 0, errors.New("it must be a number of bytes, with an optional K, M or G suffix")
+	}
+
+	size, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	var shift uint
+	switch strings.ToUpper(matches[2]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	}
+
+	if size > math.MaxInt64>>shift {
+		return 0, errors.New("the size is too large")
+	}
+	return size << shift, nil
+}
+
+// getLoadBalancer validates the load-balancing method label, an unknown method is replaced by the default one.
+func getLoadBalancer(labels map[string]string) *types.LoadBalancer {
+	lb := label.GetLoadBalancer(labels)
+	if lb == nil {
+		return nil
+	}
+
+	if _, err := types.NewLoadBalancerMethod(lb); err != nil {
+		log.Warnf("Invalid value %q in label %s, using the %s method: it must be wrr or drr", lb.Method, label.TraefikBackendLoadBalancerMethod, label.DefaultBackendLoadBalancerMethod)
+		lb.Method = label.DefaultBackendLoadBalancerMethod
+	}
+
+	// The clients are stuck to a server either by a cookie or by their IP address.
+	if label.GetBoolValue(labels, labelBackendStickySourceIP, false) {
+		if lb.Stickiness != nil {
+			log.Warnf("Both the labels %s and %s are set, using the cookie stickiness only", label.TraefikBackendLoadBalancerStickiness, labelBackendStickySourceIP)
+		} else {
+			lb.Stickiness = &types.Stickiness{SourceIP: true}
+		}
+	}
+	return lb
+}
+
+// getHeaders adds the custom headers given one per label, e.g. traefik.frontend.headers.customRequestHeaders.X-Foo=bar,
+// to the headers of the frontend. They take precedence over the ones of the combined label, and an empty value removes the header.
+func getHeaders(labels map[string]string) *types.Headers {
+	headers := label.GetHeaders(labels)
+	if headers == nil {
+		headers = &types.Headers{}
+	}
+
+	headers.CustomRequestHeaders = addCustomHeaders(headers.CustomRequestHeaders, labels, label.TraefikFrontendRequestHeaders+".")
+	headers.CustomResponseHeaders = addCustomHeaders(headers.CustomResponseHeaders, labels, label.TraefikFrontendResponseHeaders+".")
+
+	if !headers.HasSecureHeadersDefined() && !headers.HasCustomHeadersDefined() {
+		return nil
+	}
+	return headers
+}
+
+func addCustomHeaders(headers map[string]string, labels map[string]string, prefix string) map[string]string {
+	for key, value := range labels {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(key, prefix)
+		if !headerNameRegexp.MatchString(name) {
+			log.Warnf("Invalid header name %q in label %s, ignoring it", name, key)
+			continue
+		}
+		if strings.ContainsAny(value, "\"\r\n") {
+			log.Warnf("Invalid value %q in label %s, ignoring it: quotes and line breaks are not allowed", value, key)
+			continue
+		}
+
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// getResponseForwarding validates the response forwarding labels, an invalid value is ignored to keep the default behavior.
+func getResponseForwarding(labels map[string]string) *types.ResponseForwarding {
+	value := label.GetStringValue(labels, label.TraefikBackendResponseForwardingFlushInterval, "")
+	if len(value) == 0 {
+		return nil
+	}
+
+	if flushInterval, err := time.ParseDuration(value); err != nil || flushInterval <= 0 {
+		log.Warnf("Invalid value %q in label %s, using the default flush interval: it must be a positive duration", value, label.TraefikBackendResponseForwardingFlushInterval)
+		return nil
+	}
+
+	return &types.ResponseForwarding{
+		FlushInterval: value,
+	}
+}
+
+// getBackendTLS validates the TLS labels of the backend, an invalid value is ignored.
+func getBackendTLS(labels map[string]string) *types.BackendTLS {
+	backendTLS := &types.BackendTLS{}
+
+	if value, ok := labels[labelBackendTLSInsecure]; ok {
+		insecure, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			log.Warnf("Invalid value %q in label %s, ignoring it: it must be true or false", value, labelBackendTLSInsecure)
+		}
+		backendTLS.InsecureSkipVerify = insecure
+	}
+
+	if value := strings.TrimSpace(label.GetStringValue(labels, labelBackendTLSServerName, "")); len(value) > 0 {
+		if hostnameRegexp.MatchString(value) {
+			backendTLS.ServerName = value
+		} else {
+			log.Warnf("Invalid value %q in label %s, ignoring it: it must be a hostname", value, labelBackendTLSServerName)
+		}
+	}
+
+	if !backendTLS.InsecureSkipVerify && len(backendTLS.ServerName) == 0 {
+		return nil
+	}
+	return backendTLS
+}
+
+// getRetry returns the retry attempts of the backend, taking precedence over the global retry configuration.
+// An invalid number of attempts is ignored.
+func getRetry(labels map[string]string) *types.Retry {
+	value, ok := labels[labelBackendRetryAttempts]
+	if !ok {
+		return nil
+	}
+
+	attempts, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || attempts <= 0 {
+		log.Warnf("Invalid value %q in label %s, ignoring it: it must be a positive integer", value, labelBackendRetryAttempts)
+		return nil
+	}
+	return &types.Retry{Attempts: attempts}
+}
+
+// getPriority returns the priority of the frontend, the default one letting Traefik order the rules by length.
+func getPriority(container dockerData) int {
+	rawValue, ok := container.SegmentLabels[label.TraefikFrontendPriority]
+	if !ok {
+		return label.DefaultFrontendPriority
+	}
+
+	priority, err := strconv.Atoi(strings.TrimSpace(rawValue))
+	if err != nil {
+		log.Warnf("Invalid priority %q of the frontend of the container %s, using the default priority: it must be an integer", rawValue, container.Name)
+		return label.DefaultFrontendPriority
+	}
+	return priority
+}
+
+// getPassHostHeader falls back on the provider behavior when the label is missing or invalid.
+func (p *Provider) getPassHostHeader(labels map[string]string) bool {
+	return label.GetBoolValue(labels, label.TraefikFrontendPassHostHeader, !p.DisablePassHostHeader)
+}
+
+// getEntryPoints returns the entry points requested by the labels, plus the TLS ones when the frontend opts in to TLS.
+// The entry points unknown to Traefik are ignored, a frontend bound to them would never be reachable.
+func (p *Provider) getEntryPoints(labels map[string]string) []string {
+	entryPoints := label.GetSliceStringValue(labels, label.TraefikFrontendEntryPoints)
+
+	if label.GetBoolValue(labels, labelFrontendTLS, false) {
+		if len(p.TLSEntryPoints) == 0 {
+			log.Warnf("No TLS entry point defined, ignoring the label %s", labelFrontendTLS)
+		}
+		for _, entryPoint := range p.TLSEntryPoints {
+			if !hasEntryPoint(entryPoints, entryPoint) {
+				entryPoints = append(entryPoints, entryPoint)
+			}
+		}
+	}
+
+	if len(p.EntryPoints) == 0 {
+		return entryPoints
+	}
+
+	var knownEntryPoints []string
+	for _, entryPoint := range entryPoints {
+		if !hasEntryPoint(p.EntryPoints, entryPoint) {
+			log.Warnf("Unknown entry point %q in label %s, ignoring it", entryPoint, label.TraefikFrontendEntryPoints)
+			continue
 		}
+		knownEntryPoints = append(knownEntryPoints, entryPoint)
+	}
+
+	return knownEntryPoints
+}
 
-		if servers == nil {
-			servers = make(map[string]types.Server)
+// hasReachableEntryPoints is false when the labels only request unknown entry points:
+// without any entry point left, the frontend would be bound to the default ones instead.
+func (p *Provider) hasReachableEntryPoints(container dockerData) bool {
+	entryPoints := label.GetSliceStringValue(container.SegmentLabels, label.TraefikFrontendEntryPoints)
+	if len(p.EntryPoints) == 0 || len(entryPoints) == 0 {
+		return true
+	}
+
+	if label.GetBoolValue(container.SegmentLabels, labelFrontendTLS, false) && len(p.TLSEntryPoints) > 0 {
+		return true
+	}
+
+	for _, entryPoint := range entryPoints {
+		if hasEntryPoint(p.EntryPoints, entryPoint) {
+			return true
 		}
+	}
 
-		protocol := label.GetStringValue(container.SegmentLabels, label.TraefikProtocol, label.DefaultProtocol)
+	log.Warnf("Ignoring the frontend of the container %s: none of its entry points %q is defined", container.Name, entryPoints)
+	return false
+}
 
-		serverURL := fmt.Sprintf("%s://%s", protocol, net.JoinHostPort(ip, port))
+func hasEntryPoint(entryPoints []string, name string) bool {
+	for _, entryPoint := range entryPoints {
+		if entryPoint == name {
+			return true
+		}
+	}
+	return false
+}
 
-		serverName := getServerName(container.Name, serverURL)
-		if _, exist := servers[serverName]; exist {
-			log.Debugf("Skipping server %q with the same URL.", serverName)
+func getPort(container dockerData) string {
+	if value := label.GetStringValue(container.SegmentLabels, label.TraefikPort, ""); len(value) != 0 {
+		return value
+	}
+
+	// See iteration order in https://blog.golang.org/go-maps-in-action
+	var ports []nat.Port
+	for port := range container.NetworkSettings.Ports {
+		if isPortDisabled(container, port.Port()) {
 			continue
 		}
+		ports = append(ports, port)
+	}
+
+	less := func(i, j nat.Port) bool {
+		return i.Int() < j.Int()
+	}
+	nat.Sort(ports, less)
+
+	if len(ports) > 0 {
+		min := ports[0]
+		return min.Port()
+	}
+
+	// Without ports in the network settings, the exposed ports are reachable on the internal IP
+	// but only a single one can be chosen automatically.
+	if len(container.NetworkSettings.Ports) == 0 {
+		var exposedPorts []nat.Port
+		for port := range container.ExposedPorts {
+			if !isPortDisabled(container, port.Port()) {
+				exposedPorts = append(exposedPorts, port)
+			}
+		}
 
-		servers[serverName] = types.Server{
-			URL:    serverURL,
-			Weight: label.GetIntValue(container.SegmentLabels, label.TraefikWeight, label.DefaultWeight),
+		if len(exposedPorts) == 1 {
+			return exposedPorts[0].Port()
+		}
+	}
+
+	return ""
+}
+
+func isPortDisabled(container dockerData, port string) bool {
+	return label.GetBoolValue(container.Labels, label.Prefix+port+"."+labelSuffixDisable, false)
+}
+
+// checkDisabledPorts warns about the disable labels referencing ports not exposed by the container.
+func checkDisabledPorts(container dockerData) {
+	for name := range container.Labels {
+		matches := disabledPortRegexp.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+
+		var exposed bool
+		for port := range container.NetworkSettings.Ports {
+			if port.Port() == matches[1] {
+				exposed = true
+				break
+			}
+		}
+
+		if !exposed {
+			log.Warnf("Label %s on container %s references port %s which is not exposed", name, container.Name, matches[1])
+		}
+	}
+}
+
+func (p *Provider) getPortBinding(container dockerData) (*nat.PortBinding, error) {
+	if portBindings := getPortBindings(container); len(portBindings) > 0 {
+		return &portBindings[0], nil
+	}
+
+	return nil, fmt.Errorf("unable to find the external IP:Port for the container %q", container.Name)
+}
+
+// getPortBindings returns all the bindings of the port of the container, on any host IP.
+func getPortBindings(container dockerData) []nat.PortBinding {
+	port := getPort(container)
+
+	var bindings []nat.PortBinding
+	for netPort, portBindings := range container.NetworkSettings.Ports {
+		if strings.EqualFold(string(netPort), port+"/TCP") || strings.EqualFold(string(netPort), port+"/UDP") {
+			bindings = append(bindings, portBindings...)
+		}
+	}
+	return bindings
+}
+
+// getBindAddresses returns the host addresses (IP:Port) of all the bindings of the port of the container.
+// The wildcard bindings (e.g. 0.0.0.0) are ignored, unless the BindHostIP option gives the IP to use instead.
+func (p *Provider) getBindAddresses(container dockerData) ([]string, error) {
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, portBinding := range getPortBindings(container) {
+		hostIP := p.getBindHostIP(portBinding)
+		if len(hostIP) == 0 || len(portBinding.HostPort) == 0 {
+			log.Debugf("Ignoring the binding %s:%s of the container %q: no routable IP address", portBinding.HostIP, portBinding.HostPort, container.Name)
+			continue
+		}
+
+		address := net.JoinHostPort(hostIP, portBinding.HostPort)
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("unable to find a routable binding for the container %q: ignoring server", container.Name)
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// getBindHostIP returns the host IP of the binding, the BindHostIP option for a wildcard one (empty if not set).
+func (p *Provider) getBindHostIP(portBinding nat.PortBinding) string {
+	if len(portBinding.HostIP) == 0 {
+		return p.BindHostIP
+	}
+	if ip := net.ParseIP(portBinding.HostIP); ip != nil && ip.IsUnspecified() {
+		return p.BindHostIP
+	}
+	return portBinding.HostIP
+}
+
+// useBindAddresses tells whether the servers of the container are reached through the host addresses of its
+// published ports, the swarm classic nodes and the remote hosts of the hosts file excepted.
+func (p *Provider) useBindAddresses(container dockerData) bool {
+	if p.SwarmClassic && container.Node != nil && len(container.Node.IPAddress) > 0 {
+		return false
+	}
+	if len(container.Host) > 0 && len(getHostAddress(container.Host)) > 0 {
+		return false
+	}
+	return p.UseBindPortIP || container.BindPortIP
+}
+
+func (p *Provider) getIPPort(container dockerData) (string, string, error) {
+	var ip, port string
+
+	if p.SwarmClassic && container.Node != nil && len(container.Node.IPAddress) > 0 {
+		// The containers are spread across the nodes, only reachable through their published ports.
+		portBinding, err := p.getPortBinding(container)
+		if err != nil {
+			return "", "", fmt.Errorf("no published port for the container %q on the node %s: ignoring server", container.Name, container.Node.Name)
+		}
+
+		ip = container.Node.IPAddress
+		port = portBinding.HostPort
+
+	} else if len(container.Host) > 0 && len(getHostAddress(container.Host)) > 0 && container.NetworkSettings.NetworkMode.IsHost() {
+		// Nothing is published on the host network: the container listens on its port, on the address of its host.
+		ip, _ = p.getHostModeIP(container)
+		port = getPort(container)
+
+	} else if len(container.Host) > 0 && len(getHostAddress(container.Host)) > 0 {
+		// The other containers of a remote host are only reachable through their published ports.
+		portBinding, err := p.getPortBinding(container)
+		if err != nil {
+			return "", "", fmt.Errorf("no published port for the container %q on the docker host %s: ignoring server", container.Name, container.Host)
+		}
+
+		ip = getHostAddress(container.Host)
+		if bindIP := net.ParseIP(portBinding.HostIP); bindIP != nil && !bindIP.IsUnspecified() && !bindIP.IsLoopback() {
+			ip = portBinding.HostIP
+		}
+		port = portBinding.HostPort
+
+	} else if p.UseBindPortIP || container.BindPortIP {
+		portBinding, err := p.getPortBinding(container)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to find a binding for the container %q: ignoring server", container.Name)
+		}
+
+		if ip := net.ParseIP(portBinding.HostIP); ip != nil && ip.IsUnspecified() && len(p.BindHostIP) == 0 {
+			return "", "", fmt.Errorf("cannot determine the IP address (got %s) for the container %q: ignoring server", portBinding.HostIP, container.Name)
+		}
+
+		ip = p.getBindHostIP(*portBinding)
+		port = portBinding.HostPort
+
+	} else {
+		ip = p.getIPAddress(container)
+		port = getPort(container)
+	}
+
+	if len(ip) == 0 {
+		return "", "", fmt.Errorf("unable to find the IP address for the container %q: the server is ignored", container.Name)
+	}
+	return ip, port, nil
+}
+
+func (p *Provider) getServers(containers []dockerData) map[string]types.Server {
+	var servers map[string]types.Server
+
+	// The weights can only be increased: the healthy servers get more weight when the backend has degraded ones.
+	healthyWeightRatio := 1
+	for _, container := range containers {
+		if p.isDegraded(container) {
+			healthyWeightRatio = degradedWeightRatio
+			break
+		}
+	}
+
+	primaryWeightRatio, canaryWeightRatio := getCanaryWeightRatios(containers)
+	standby := getStandbyServers(containers)
+	weightByCPU := hasCPUReservation(containers)
+
+	for i, container := range containers {
+		serverURLs, err := p.getServerURLs(container)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+
+		// A server in maintenance is kept in the configuration, to stay visible, but does not get any traffic.
+		maintenance := label.GetBoolValue(container.SegmentLabels, labelBackendMaintenance, false)
+
+		weight := label.GetIntValue(container.SegmentLabels, label.TraefikWeight, p.getDefaultWeight(container, weightByCPU))
+		if maintenance {
+			weight = 0
+		} else if !p.isDegraded(container) {
+			weight *= healthyWeightRatio
+		}
+
+		if container.CanaryWeight > 0 {
+			weight *= canaryWeightRatio
+		} else {
+			weight *= primaryWeightRatio
+		}
+
+		for _, serverURL := range serverURLs {
+			if servers == nil {
+				servers = make(map[string]types.Server)
+			}
+
+			serverName := getServerName(container.Name, serverURL)
+			if _, exist := servers[serverName]; exist {
+				log.Debugf("Skipping server %q with the same URL.", serverName)
+				continue
+			}
+
+			servers[serverName] = types.Server{
+				URL:         serverURL,
+				Weight:      weight,
+				Maintenance: maintenance,
+				Standby:     standby[i],
+			}
 		}
 	}
 
 	return servers
 }
 
+// sortServersByOrder sorts the containers of each backend by their order label, the containers without a valid one
+// coming last in their discovery order. The first container gives the backend labels, and is the primary server in failover mode.
+func sortServersByOrder(servers map[string][]dockerData) {
+	for backendName, containers := range servers {
+		orders := make(map[string]int, len(containers))
+		for _, container := range containers {
+			value, ok := container.SegmentLabels[labelBackendOrder]
+			if !ok {
+				continue
+			}
+
+			order, err := strconv.Atoi(value)
+			if err != nil {
+				log.Warnf("Invalid value %q in label %s for container %q of backend %s, sorting it last: %v", value, labelBackendOrder, container.Name, backendName, err)
+				continue
+			}
+			orders[container.Name] = order
+		}
+
+		sort.SliceStable(containers, func(i, j int) bool {
+			orderI, okI := orders[containers[i].Name]
+			orderJ, okJ := orders[containers[j].Name]
+			if !okJ {
+				return okI
+			}
+			return okI && orderI < orderJ
+		})
+	}
+}
+
+// getStandbyServers tells which containers of the backend are standby servers when it is in failover mode:
+// all but the ones with the lowest failover priority, or all but the first one when no priority is given.
+func getStandbyServers(containers []dockerData) []bool {
+	standby := make([]bool, len(containers))
+	if len(containers) == 0 || !label.GetBoolValue(containers[0].SegmentLabels, labelBackendFailover, false) {
+		return standby
+	}
+
+	var hasPriority bool
+	priorities := make([]int, len(containers))
+	for i, container := range containers {
+		if _, ok := container.SegmentLabels[labelBackendFailoverPriority]; ok {
+			hasPriority = true
+		}
+		priorities[i] = label.GetIntValue(container.SegmentLabels, labelBackendFailoverPriority, 0)
+	}
+
+	if !hasPriority {
+		for i := 1; i < len(containers); i++ {
+			standby[i] = true
+		}
+		return standby
+	}
+
+	minPriority := priorities[0]
+	for _, priority := range priorities {
+		if priority < minPriority {
+			minPriority = priority
+		}
+	}
+	for i, priority := range priorities {
+		standby[i] = priority > minPriority
+	}
+	return standby
+}
+
+// getDefaultWeight returns the weight of the server when the label is missing: the one given by the reserved resources
+// when the WeightByResources option is set, so the bigger tasks get more traffic.
+func (p *Provider) getDefaultWeight(container dockerData, weightByCPU bool) int {
+	if p.WeightByResources {
+		if weight := getResourceWeight(container.Reservations, weightByCPU); weight > 0 {
+			return weight
+		}
+	}
+	return label.DefaultWeight
+}
+
+// hasCPUReservation tells if any task of the backend reserves CPU, the tasks of the backend being then all weighted by CPU.
+func hasCPUReservation(containers []dockerData) bool {
+	for _, container := range containers {
+		if container.Reservations != nil && container.Reservations.NanoCPUs > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// getResourceWeight gives a weight proportional to the reserved resources, in a single unit for all the tasks of a backend:
+// 1 per hundredth of CPU when weighted by CPU, or else 1 per MiB of memory. It is 0 if the resource is not reserved.
+func getResourceWeight(reservations *swarmtypes.Resources, byCPU bool) int {
+	switch {
+	case reservations == nil:
+		return 0
+	case byCPU && reservations.NanoCPUs > 0:
+		return int(math.Max(1, float64(reservations.NanoCPUs)/1e7))
+	case !byCPU && reservations.MemoryBytes > 0:
+		return int(math.Max(1, float64(reservations.MemoryBytes)/(1<<20)))
+	default:
+		return 0
+	}
+}
+
+func (p *Provider) getServerURL(container dockerData) (string, error) {
+	if value := label.GetStringValue(container.SegmentLabels, labelBackendServerURL, ""); value != "" {
+		serverURL, err := parseServerURL(value)
+		if err == nil {
+			return serverURL, nil
+		}
+
+		log.Warnf("Invalid URL %q in label %s for container %q, using the discovered address: %v", value, labelBackendServerURL, container.Name, err)
+	}
+
+	ip, port, err := p.getIPPort(container)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s://%s", p.getProtocol(container), net.JoinHostPort(ip, port)), nil
+}
+
+// getServerURLs returns the URLs of the servers of the container, one per port of its published range
+// with the traefik.backend.portRange label.
+func (p *Provider) getServerURLs(container dockerData) ([]string, error) {
+	ports := getPortRange(container)
+	if len(ports) < 2 {
+		return p.getPortServerURLs(container)
+	}
+
+	var serverURLs []string
+	for _, port := range ports {
+		portContainer := container
+		portContainer.SegmentLabels = make(map[string]string, len(container.SegmentLabels)+1)
+		for key, value := range container.SegmentLabels {
+			portContainer.SegmentLabels[key] = value
+		}
+		portContainer.SegmentLabels[label.TraefikPort] = port
+
+		portURLs, err := p.getPortServerURLs(portContainer)
+		if err != nil {
+			return nil, err
+		}
+		serverURLs = append(serverURLs, portURLs...)
+	}
+	return serverURLs, nil
+}
+
+// getPortRange returns the ports of the published range of the container, starting at its port, when the
+// traefik.backend.portRange label is set. The port map holds one entry per port, whether the ports were published
+// as a range (e.g. 8000-8010) or one by one: any run of consecutive published TCP ports is taken as a range.
+func getPortRange(container dockerData) []string {
+	if !label.GetBoolValue(container.SegmentLabels, labelBackendPortRange, false) ||
+		len(label.GetStringValue(container.SegmentLabels, labelBackendServerURL, "")) > 0 {
+		return nil
+	}
+
+	start, err := strconv.Atoi(getPort(container))
+	if err != nil {
+		return nil
+	}
+
+	var ports []string
+	for port := start; port <= math.MaxUint16; port++ {
+		natPort, err := nat.NewPort("tcp", strconv.Itoa(port))
+		if err != nil {
+			break
+		}
+		if _, ok := container.NetworkSettings.Ports[natPort]; !ok || isPortDisabled(container, natPort.Port()) {
+			break
+		}
+		ports = append(ports, natPort.Port())
+	}
+	return ports
+}
+
+// getPortServerURLs returns the URLs of the servers of the port of the container: one per binding of the port when the
+// addresses of the published ports are used, so a container published on several host IPs is reachable on each of them.
+func (p *Provider) getPortServerURLs(container dockerData) ([]string, error) {
+	if !p.useBindAddresses(container) || len(label.GetStringValue(container.SegmentLabels, labelBackendServerURL, "")) > 0 {
+		serverURL, err := p.getServerURL(container)
+		if err != nil {
+			return nil, err
+		}
+		return []string{serverURL}, nil
+	}
+
+	addresses, err := p.getBindAddresses(container)
+	if err != nil {
+		return nil, err
+	}
+
+	protocol := p.getProtocol(container)
+
+	var serverURLs []string
+	for _, address := range addresses {
+		serverURLs = append(serverURLs, fmt.Sprintf("%s://%s", protocol, address))
+	}
+	return serverURLs, nil
+}
+
+func (p *Provider) getProtocol(container dockerData) string {
+	defaultProtocol := label.DefaultProtocol
+	if len(p.DefaultProtocol) > 0 {
+		defaultProtocol = p.DefaultProtocol
+	}
+	return label.GetStringValue(container.SegmentLabels, label.TraefikProtocol, defaultProtocol)
+}
+
+// parseServerURL checks that the value is an absolute URL with a host, and returns it verbatim.
+func parseServerURL(value string) (string, error) {
+	serverURL, err := url.Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	if len(serverURL.Scheme) == 0 || len(serverURL.Host) == 0 {
+		return "", fmt.Errorf("missing scheme or host in %q", value)
+	}
+
+	return value, nil
+}
+
+// hasServerURL returns true if the server URL of at least one segment is set by a valid label.
+func hasServerURL(labels map[string]string) bool {
+	for _, segmentLabels := range label.ExtractTraefikLabels(labels) {
+		if _, err := parseServerURL(segmentLabels[labelBackendServerURL]); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 func getServerName(containerName, url string) string {
 	hash := md5.New()
 	_, err := hash.Write([]byte(url))
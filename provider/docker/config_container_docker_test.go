@@ -1,16 +1,21 @@
 package docker
 
 import (
+	"bytes"
+	"os"
+	"sort"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/provider/label"
 	"github.com/containous/traefik/types"
 	docker "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/go-connections/nat"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -373,7 +378,7 @@ func TestDockerBuildConfiguration(t *testing.T) {
 						label.TraefikBackendHealthCheckScheme:                "http",
 						label.TraefikBackendHealthCheckPath:                  "/health",
 						label.TraefikBackendHealthCheckPort:                  "880",
-						label.TraefikBackendHealthCheckInterval:              "6",
+						label.TraefikBackendHealthCheckInterval:              "6s",
 						label.TraefikBackendHealthCheckHostname:              "foo.com",
 						label.TraefikBackendHealthCheckHeaders:               "Foo:bar || Bar:foo",
 						label.TraefikBackendLoadBalancerMethod:               "drr",
@@ -386,6 +391,7 @@ func TestDockerBuildConfiguration(t *testing.T) {
 						label.TraefikBackendBufferingMaxRequestBodyBytes:     "10485760",
 						label.TraefikBackendBufferingMemRequestBodyBytes:     "2097152",
 						label.TraefikBackendBufferingRetryExpression:         "IsNetworkError() && Attempts() <= 2",
+						label.TraefikBackendResponseForwardingFlushInterval:  "10ms",
 
 						label.TraefikFrontendAuthBasic:                        "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/,test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0",
 						label.TraefikFrontendAuthBasicRemoveHeader:            "true",
@@ -588,7 +594,7 @@ func TestDockerBuildConfiguration(t *testing.T) {
 						Scheme:   "http",
 						Path:     "/health",
 						Port:     880,
-						Interval: "6",
+						Interval: "6s",
 						Hostname: "foo.com",
 						Headers: map[string]string{
 							"Foo": "bar",
@@ -602,6 +608,9 @@ func TestDockerBuildConfiguration(t *testing.T) {
 						MemRequestBodyBytes:  2097152,
 						RetryExpression:      "IsNetworkError() && Attempts() <= 2",
 					},
+					ResponseForwarding: &types.ResponseForwarding{
+						FlushInterval: "10ms",
+					},
 				},
 			},
 		},
@@ -720,6 +729,81 @@ func TestDockerBuildConfiguration(t *testing.T) {
 	}
 }
 
+func TestDockerBuildConfigurationDuplicateRules(t *testing.T) {
+	containers := []docker.ContainerJSON{
+		containerJSON(
+			name("test1"),
+			labels(map[string]string{
+				label.TraefikFrontendRule: "Host:foo.bar",
+			}),
+			ports(nat.PortMap{
+				"80/tcp": {},
+			}),
+			withNetwork("bridge", ipv4("127.0.0.1")),
+		),
+		containerJSON(
+			name("test2"),
+			labels(map[string]string{
+				label.TraefikFrontendRule: "Host:foo.bar",
+			}),
+			ports(nat.PortMap{
+				"80/tcp": {},
+			}),
+			withNetwork("bridge", ipv4("127.0.0.2")),
+		),
+		containerJSON(
+			name("test3"),
+			ports(nat.PortMap{
+				"80/tcp": {},
+			}),
+			withNetwork("bridge", ipv4("127.0.0.3")),
+		),
+	}
+
+	testCases := []struct {
+		desc              string
+		strictRules       bool
+		expectedFrontends []string
+	}{
+		{
+			desc:              "duplicate rules are kept",
+			strictRules:       false,
+			expectedFrontends: []string{"frontend-Host-foo-bar-0", "frontend-Host-foo-bar-1", "frontend-Host-test3-docker-localhost-2"},
+		},
+		{
+			desc:              "duplicate rules are ignored in strict mode",
+			strictRules:       true,
+			expectedFrontends: []string{"frontend-Host-test3-docker-localhost-2"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var dockerDataList []dockerData
+			for _, cont := range containers {
+				dockerDataList = append(dockerDataList, parseContainer(cont))
+			}
+
+			provider := &Provider{
+				Domain:           "docker.localhost",
+				ExposedByDefault: true,
+				StrictRules:      test.strictRules,
+			}
+			actualConfig := provider.buildConfiguration(dockerDataList)
+			require.NotNil(t, actualConfig, "actualConfig")
+
+			var frontendNames []string
+			for frontendName := range actualConfig.Frontends {
+				frontendNames = append(frontendNames, frontendName)
+			}
+			assert.ElementsMatch(t, test.expectedFrontends, frontendNames)
+		})
+	}
+}
+
 func TestDockerTraefikFilter(t *testing.T) {
 	testCases := []struct {
 		container docker.ContainerJSON
@@ -1017,6 +1101,142 @@ func TestDockerTraefikFilter(t *testing.T) {
 			},
 			expected: true,
 		},
+		{
+			container: containerJSON(
+				name("container"),
+				labels(map[string]string{
+					"traefik.8080.disable": "true",
+				}),
+				ports(nat.PortMap{
+					"8080/tcp": {},
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+			},
+			expected: false,
+		},
+		{
+			container: containerJSON(
+				name("container"),
+				restartCount(10),
+				ports(nat.PortMap{
+					"80/tcp": {},
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+				MaxRestartCount:  5,
+			},
+			expected: false,
+		},
+		{
+			container: containerJSON(
+				name("container"),
+				restartCount(5),
+				ports(nat.PortMap{
+					"80/tcp": {},
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+				MaxRestartCount:  5,
+			},
+			expected: true,
+		},
+		{
+			container: containerJSON(
+				name("container"),
+				restartCount(10),
+				ports(nat.PortMap{
+					"80/tcp": {},
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+			},
+			expected: true,
+		},
+		{
+			container: containerJSON(
+				name("/infra-dns"),
+				labels(map[string]string{
+					label.TraefikEnable: "true",
+				}),
+				ports(nat.PortMap{
+					"80/tcp": {},
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+				NeverExpose:      NamePatterns{"infra-*"},
+			},
+			expected: false,
+		},
+		{
+			container: containerJSON(
+				name("/monitoring"),
+				image("prom/node-exporter:latest"),
+				labels(map[string]string{
+					label.TraefikEnable: "true",
+				}),
+				ports(nat.PortMap{
+					"80/tcp": {},
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+				NeverExpose:      NamePatterns{"infra-*", "prom/node-exporter*"},
+			},
+			expected: false,
+		},
+		{
+			container: containerJSON(
+				name("/web"),
+				image("nginx:latest"),
+				ports(nat.PortMap{
+					"80/tcp": {},
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+				NeverExpose:      NamePatterns{"infra-*", "prom/node-exporter*"},
+			},
+			expected: true,
+		},
+		{
+			container: containerJSON(
+				name("container"),
+				labels(map[string]string{
+					labelBackendServerURL: "http://proxy.local:8080",
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+			},
+			expected: true,
+		},
+		{
+			container: containerJSON(
+				name("container"),
+				labels(map[string]string{
+					labelBackendServerURL: "proxy.local:8080",
+				}),
+			),
+			provider: &Provider{
+				Domain:           "test",
+				ExposedByDefault: true,
+			},
+			expected: false,
+		},
 	}
 
 	for containerID, test := range testCases {
@@ -1094,10 +1314,73 @@ func TestDockerGetFrontendName(t *testing.T) {
 	}
 }
 
+func TestDockerGetFrontendRuleNetworkAliases(t *testing.T) {
+	testCases := []struct {
+		desc              string
+		useNetworkAliases bool
+		container         docker.ContainerJSON
+		expected          string
+	}{
+		{
+			desc: "aliases not used",
+			container: containerJSON(name("web"),
+				withNetwork("frontnet", ipv4("10.10.10.10"), aliases("www", "site"))),
+			expected: "Host:web.docker.localhost",
+		},
+		{
+			desc:              "two aliases",
+			useNetworkAliases: true,
+			container: containerJSON(name("web"),
+				withNetwork("frontnet", ipv4("10.10.10.10"), aliases("www", "site"))),
+			expected: "Host:web.docker.localhost,site.docker.localhost,www.docker.localhost",
+		},
+		{
+			desc:              "duplicated aliases and container ID",
+			useNetworkAliases: true,
+			container: containerJSON(name("web"),
+				func(c *docker.ContainerJSON) {
+					c.ID = "3f4e5a6b7c8d9e0f"
+				},
+				withNetwork("frontnet", ipv4("10.10.10.10"), aliases("web", "www", "3f4e5a6b7c8d")),
+				withNetwork("backnet", ipv4("10.10.11.10"), aliases("www"))),
+			expected: "Host:web.docker.localhost,www.docker.localhost",
+		},
+		{
+			desc:              "rule label",
+			useNetworkAliases: true,
+			container: containerJSON(name("web"),
+				labels(map[string]string{
+					label.TraefikFrontendRule: "Host:foo.bar",
+				}),
+				withNetwork("frontnet", ipv4("10.10.10.10"), aliases("www"))),
+			expected: "Host:foo.bar",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dData := parseContainer(test.container)
+
+			provider := &Provider{
+				Domain:            "docker.localhost",
+				UseNetworkAliases: test.useNetworkAliases,
+			}
+
+			actual := provider.getFrontendRule(dData, label.ExtractTraefikLabels(dData.Labels)[""])
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
 func TestDockerGetFrontendRule(t *testing.T) {
 	testCases := []struct {
-		container docker.ContainerJSON
-		expected  string
+		container   docker.ContainerJSON
+		segmentName string
+		defaultRule string
+		expected    string
 	}{
 		{
 			container: containerJSON(name("foo")),
@@ -1110,9 +1393,34 @@ func TestDockerGetFrontendRule(t *testing.T) {
 				})),
 			expected: "Host:foo.traefik.localhost",
 		},
+		{
+			container: containerJSON(name("foo"),
+				labels(map[string]string{
+					label.TraefikDomain: "traefik.localhost",
+					label.Prefix + "web." + label.SuffixFrontendRule: "Path:/web",
+				})),
+			segmentName: "web",
+			expected:    "Path:/web",
+		},
+		{
+			container: containerJSON(name("foo"),
+				labels(map[string]string{
+					label.TraefikDomain:                      "traefik.localhost",
+					label.Prefix + "api." + label.SuffixPort: "8080",
+				})),
+			segmentName: "api",
+			expected:    "Host:foo.traefik.localhost",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				label.TraefikFrontendRule: "Host:foo.bar",
+			})),
+			expected: "Host:foo.bar",
+		},
 		{
 			container: containerJSON(labels(map[string]string{
 				label.TraefikFrontendRule: "Host:foo.bar",
+				label.TraefikDomain:       "traefik.localhost",
 			})),
 			expected: "Host:foo.bar",
 		},
@@ -1129,6 +1437,44 @@ func TestDockerGetFrontendRule(t *testing.T) {
 			})),
 			expected: "Path:/test",
 		},
+		{
+			container:   containerJSON(name("/foo_bar")),
+			defaultRule: "Host:{{ normalize .Name }}.{{ .Domain }}",
+			expected:    "Host:foo-bar.docker.localhost",
+		},
+		{
+			container: containerJSON(name("foo"),
+				labels(map[string]string{
+					label.TraefikDomain: "traefik.localhost",
+					"app":               "web",
+				})),
+			defaultRule: "Host:{{ index .Labels \"app\" }}.{{ .ServiceName | getSubDomain }}.{{ .Domain }}",
+			expected:    "Host:web.foo.traefik.localhost",
+		},
+		{
+			container: containerJSON(name("foo"),
+				labels(map[string]string{
+					label.TraefikFrontendRule: "Host:foo.bar",
+				})),
+			defaultRule: "Host:{{ normalize .Name }}.{{ .Domain }}",
+			expected:    "Host:foo.bar",
+		},
+		{
+			container:   containerJSON(name("/app-Foo_Bar")),
+			defaultRule: "Host:{{ .Name | trimPrefix \"app-\" | replace \"_\" \".\" | lower }}.{{ .Domain }}",
+			expected:    "Host:foo.bar.docker.localhost",
+		},
+		{
+			container: containerJSON(name("web"),
+				ports(nat.PortMap{"9090/tcp": {}, "8080/tcp": {}, "8080/udp": {}, "80/tcp": {}})),
+			defaultRule: "Host:{{ .Name }}.{{ .Domain }};PathPrefix:/svc-{{ index .Ports 0 }}{{ range .Ports }},/{{ . }}{{ end }}",
+			expected:    "Host:web.docker.localhost;PathPrefix:/svc-80,/80,/8080,/9090",
+		},
+		{
+			container:   containerJSON(name("web.api")),
+			defaultRule: "Host:{{ index (split \".\" .Name) 1 | upper }}.{{ .Domain }}",
+			expected:    "Host:API.docker.localhost",
+		},
 	}
 
 	for containerID, test := range testCases {
@@ -1140,10 +1486,13 @@ func TestDockerGetFrontendRule(t *testing.T) {
 			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
 
 			provider := &Provider{
-				Domain: "docker.localhost",
+				Domain:      "docker.localhost",
+				DefaultRule: test.defaultRule,
 			}
+			err := provider.Init(nil)
+			require.NoError(t, err)
 
-			actual := provider.getFrontendRule(dData, segmentProperties[""])
+			actual := provider.getFrontendRule(dData, segmentProperties[test.segmentName])
 			assert.Equal(t, test.expected, actual)
 		})
 	}
@@ -1270,6 +1619,33 @@ func TestDockerGetIPAddress(t *testing.T) {
 			),
 			expected: "10.0.0.5",
 		},
+		{
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendAddress: "192.168.1.10",
+				}),
+				withNetwork("testnet", ipv4("10.11.12.13")),
+			),
+			expected: "192.168.1.10",
+		},
+		{
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendAddress: "backend.example.com",
+				}),
+				withNetwork("testnet", ipv4("10.11.12.13")),
+			),
+			expected: "backend.example.com",
+		},
+		{
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendAddress: "not a valid/address",
+				}),
+				withNetwork("testnet", ipv4("10.11.12.13")),
+			),
+			expected: "10.11.12.13",
+		},
 	}
 
 	for containerID, test := range testCases {
@@ -1291,26 +1667,87 @@ func TestDockerGetIPAddress(t *testing.T) {
 	}
 }
 
-func TestDockerGetIPPort(t *testing.T) {
+func TestDockerGetIPAddressLogsNetworkChoice(t *testing.T) {
 	testCases := []struct {
-		desc         string
-		container    docker.ContainerJSON
-		ip, port     string
-		expectsError bool
+		desc      string
+		container docker.ContainerJSON
+		network   string
+		expected  string
 	}{
 		{
-			desc: "label traefik.port not set, binding with ip:port should create a route to the bound ip:port",
+			desc: "label",
 			container: containerJSON(
-				ports(nat.PortMap{
-					"80/tcp": []nat.PortBinding{
-						{
-							HostIP:   "1.2.3.4",
-							HostPort: "8081",
-						},
-					},
-				}),
-				withNetwork("testnet", ipv4("10.11.12.13"))),
-			ip:   "1.2.3.4",
+				name("web"),
+				labels(map[string]string{labelDockerNetwork: "backend"}),
+				withNetwork("backend", ipv4("10.11.12.13")),
+				withNetwork("frontend", ipv4("10.11.12.14"))),
+			expected: "Network of the container web: backend, chosen by label traefik.docker.network among [backend (10.11.12.13), frontend (10.11.12.14)]",
+		},
+		{
+			desc: "default network",
+			container: containerJSON(
+				name("web"),
+				withNetwork("backend", ipv4("10.11.12.13")),
+				withNetwork("frontend", ipv4("10.11.12.14"))),
+			network:  "frontend",
+			expected: "Network of the container web: frontend, chosen by default network among [backend (10.11.12.13), frontend (10.11.12.14)]",
+		},
+		{
+			desc: "single network",
+			container: containerJSON(
+				name("web"),
+				withNetwork("backend", ipv4("10.11.12.13"))),
+			expected: "Network of the container web: backend, chosen by single network among [backend (10.11.12.13)]",
+		},
+		{
+			desc: "several networks",
+			container: containerJSON(
+				name("web"),
+				withNetwork("frontend", ipv4("10.11.12.14")),
+				withNetwork("backend", ipv4("10.11.12.13"))),
+			expected: "Network of the container web: backend, chosen by first network by name, no network label nor default network matching among [backend (10.11.12.13), frontend (10.11.12.14)]",
+		},
+	}
+
+	var logs bytes.Buffer
+	level := log.GetLevel()
+	log.SetOutput(&logs)
+	log.SetLevel(logrus.DebugLevel)
+	defer func() {
+		log.SetOutput(os.Stdout)
+		log.SetLevel(level)
+	}()
+
+	for _, test := range testCases {
+		logs.Reset()
+
+		provider := &Provider{Network: test.network}
+		provider.getIPAddress(parseContainer(test.container))
+
+		assert.Contains(t, logs.String(), strconv.Quote(test.expected), test.desc)
+	}
+}
+
+func TestDockerGetIPPort(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		container    docker.ContainerJSON
+		ip, port     string
+		expectsError bool
+	}{
+		{
+			desc: "label traefik.port not set, binding with ip:port should create a route to the bound ip:port",
+			container: containerJSON(
+				ports(nat.PortMap{
+					"80/tcp": []nat.PortBinding{
+						{
+							HostIP:   "1.2.3.4",
+							HostPort: "8081",
+						},
+					},
+				}),
+				withNetwork("testnet", ipv4("10.11.12.13"))),
+			ip:   "1.2.3.4",
 			port: "8081",
 		},
 		{
@@ -1452,68 +1889,2135 @@ func TestDockerGetIPPort(t *testing.T) {
 	}
 }
 
-func TestDockerGetPort(t *testing.T) {
+func TestDockerGetHealthCheck(t *testing.T) {
 	testCases := []struct {
-		container docker.ContainerJSON
-		expected  string
+		desc     string
+		labels   map[string]string
+		expected *types.HealthCheck
 	}{
 		{
-			container: containerJSON(name("foo")),
-			expected:  "",
+			desc:     "no health check",
+			labels:   map[string]string{},
+			expected: nil,
 		},
 		{
-			container: containerJSON(ports(nat.PortMap{
-				"80/tcp": {},
-			})),
-			expected: "80",
+			desc: "valid health check",
+			labels: map[string]string{
+				label.TraefikBackendHealthCheckPath:     "/health",
+				label.TraefikBackendHealthCheckInterval: "10s",
+				label.TraefikBackendHealthCheckPort:     "8080",
+				label.TraefikBackendHealthCheckScheme:   "https",
+			},
+			expected: &types.HealthCheck{
+				Path:     "/health",
+				Interval: "10s",
+				Port:     8080,
+				Scheme:   "https",
+			},
 		},
 		{
-			container: containerJSON(ports(nat.PortMap{
-				"80/tcp":  {},
-				"443/tcp": {},
-			})),
-			expected: "80",
+			desc: "path only",
+			labels: map[string]string{
+				label.TraefikBackendHealthCheckPath: "/health",
+			},
+			expected: &types.HealthCheck{
+				Path: "/health",
+			},
 		},
 		{
-			container: containerJSON(labels(map[string]string{
-				label.TraefikPort: "8080",
-			})),
-			expected: "8080",
+			desc: "malformed interval",
+			labels: map[string]string{
+				label.TraefikBackendHealthCheckPath:     "/health",
+				label.TraefikBackendHealthCheckInterval: "10",
+			},
+			expected: nil,
 		},
 		{
-			container: containerJSON(labels(map[string]string{
-				label.TraefikPort: "8080",
-			}), ports(nat.PortMap{
-				"80/tcp": {},
-			})),
-			expected: "8080",
+			desc: "non numeric port",
+			labels: map[string]string{
+				label.TraefikBackendHealthCheckPath: "/health",
+				label.TraefikBackendHealthCheckPort: "http",
+			},
+			expected: nil,
 		},
 		{
-			container: containerJSON(labels(map[string]string{
-				label.TraefikPort: "8080",
-			}), ports(nat.PortMap{
-				"8080/tcp": {},
-				"80/tcp":   {},
-			})),
-			expected: "8080",
+			desc: "out of range port",
+			labels: map[string]string{
+				label.TraefikBackendHealthCheckPath: "/health",
+				label.TraefikBackendHealthCheckPort: "70000",
+			},
+			expected: nil,
+		},
+		{
+			desc: "unknown scheme",
+			labels: map[string]string{
+				label.TraefikBackendHealthCheckPath:   "/health",
+				label.TraefikBackendHealthCheckScheme: "tcp",
+			},
+			expected: nil,
 		},
 	}
 
-	for containerID, test := range testCases {
+	for _, test := range testCases {
 		test := test
-		t.Run(strconv.Itoa(containerID), func(t *testing.T) {
+		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			dData := parseContainer(test.container)
-			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
-			dData.SegmentLabels = segmentProperties[""]
+			actual := getHealthCheck(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
 
-			actual := getPort(dData)
+func TestDockerGetResponseForwarding(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.ResponseForwarding
+	}{
+		{
+			desc:     "no flush interval",
+			labels:   map[string]string{},
+			expected: nil,
+		},
+		{
+			desc: "valid flush interval",
+			labels: map[string]string{
+				label.TraefikBackendResponseForwardingFlushInterval: "10ms",
+			},
+			expected: &types.ResponseForwarding{
+				FlushInterval: "10ms",
+			},
+		},
+		{
+			desc: "invalid flush interval",
+			labels: map[string]string{
+				label.TraefikBackendResponseForwardingFlushInterval: "10",
+			},
+			expected: nil,
+		},
+		{
+			desc: "negative flush interval",
+			labels: map[string]string{
+				label.TraefikBackendResponseForwardingFlushInterval: "-1s",
+			},
+			expected: nil,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := getResponseForwarding(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerGetMaxConn(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.MaxConn
+	}{
+		{
+			desc:   "no label",
+			labels: map[string]string{},
+		},
+		{
+			desc: "amount with the default extractor",
+			labels: map[string]string{
+				label.TraefikBackendMaxConnAmount: "100",
+			},
+			expected: &types.MaxConn{Amount: 100, ExtractorFunc: "request.host"},
+		},
+		{
+			desc: "amount and extractor",
+			labels: map[string]string{
+				label.TraefikBackendMaxConnAmount:        "10",
+				label.TraefikBackendMaxConnExtractorFunc: "request.header.X-Client",
+			},
+			expected: &types.MaxConn{Amount: 10, ExtractorFunc: "request.header.X-Client"},
+		},
+		{
+			desc: "invalid amount",
+			labels: map[string]string{
+				label.TraefikBackendMaxConnAmount: "ten",
+			},
+		},
+		{
+			desc: "negative amount",
+			labels: map[string]string{
+				label.TraefikBackendMaxConnAmount: "-1",
+			},
+		},
+		{
+			desc: "unknown extractor",
+			labels: map[string]string{
+				label.TraefikBackendMaxConnAmount:        "10",
+				label.TraefikBackendMaxConnExtractorFunc: "client.port",
+			},
+		},
+		{
+			desc: "invalid header name",
+			labels: map[string]string{
+				label.TraefikBackendMaxConnAmount:        "10",
+				label.TraefikBackendMaxConnExtractorFunc: `request.header.X"Client`,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := getMaxConn(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerGetBuffering(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.Buffering
+	}{
+		{
+			desc:   "no label",
+			labels: map[string]string{},
+		},
+		{
+			desc: "sizes in bytes",
+			labels: map[string]string{
+				label.TraefikBackendBufferingMaxRequestBodyBytes: "10485760",
+				label.TraefikBackendBufferingMemRequestBodyBytes: "2097152",
+			},
+			expected: &types.Buffering{
+				MaxRequestBodyBytes: 10485760,
+				MemRequestBodyBytes: 2097152,
+			},
+		},
+		{
+			desc: "human-readable sizes",
+			labels: map[string]string{
+				label.TraefikBackendBufferingMaxRequestBodyBytes:  "1G",
+				label.TraefikBackendBufferingMemRequestBodyBytes:  "1M",
+				label.TraefikBackendBufferingMaxResponseBodyBytes: "512K",
+				label.TraefikBackendBufferingMemResponseBodyBytes: "64kb",
+				label.TraefikBackendBufferingRetryExpression:      "IsNetworkError() && Attempts() <= 2",
+			},
+			expected: &types.Buffering{
+				MaxRequestBodyBytes:  1 << 30,
+				MemRequestBodyBytes:  1 << 20,
+				MaxResponseBodyBytes: 512 << 10,
+				MemResponseBodyBytes: 64 << 10,
+				RetryExpression:      "IsNetworkError() && Attempts() <= 2",
+			},
+		},
+		{
+			desc: "invalid sizes ignored",
+			labels: map[string]string{
+				label.TraefikBackendBufferingMaxRequestBodyBytes:  "1T",
+				label.TraefikBackendBufferingMemRequestBodyBytes:  "-1M",
+				label.TraefikBackendBufferingMaxResponseBodyBytes: "9999999999G",
+				label.TraefikBackendBufferingMemResponseBodyBytes: "2M",
+			},
+			expected: &types.Buffering{
+				MemResponseBodyBytes: 2 << 20,
+			},
+		},
+		{
+			desc: "only invalid sizes",
+			labels: map[string]string{
+				label.TraefikBackendBufferingMaxRequestBodyBytes: "big",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := getBuffering(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerGetBackendTLS(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.BackendTLS
+	}{
+		{
+			desc:   "no label",
+			labels: map[string]string{},
+		},
+		{
+			desc: "insecure skip verify",
+			labels: map[string]string{
+				labelBackendTLSInsecure: "true",
+			},
+			expected: &types.BackendTLS{InsecureSkipVerify: true},
+		},
+		{
+			desc: "secure",
+			labels: map[string]string{
+				labelBackendTLSInsecure: "false",
+			},
+		},
+		{
+			desc: "server name",
+			labels: map[string]string{
+				labelBackendTLSInsecure:   "false",
+				labelBackendTLSServerName: "internal.example.com",
+			},
+			expected: &types.BackendTLS{ServerName: "internal.example.com"},
+		},
+		{
+			desc: "invalid values",
+			labels: map[string]string{
+				labelBackendTLSInsecure:   "yes please",
+				labelBackendTLSServerName: "internal.example.com\"",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := getBackendTLS(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerBuildConfigurationBackendTLS(t *testing.T) {
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	containers := []dockerData{
+		parseContainer(containerJSON(name("foo"),
+			labels(map[string]string{
+				label.TraefikProtocol:     "https",
+				labelBackendTLSInsecure:   "true",
+				labelBackendTLSServerName: "foo.internal",
+			}),
+			ports(nat.PortMap{"443/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10")))),
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+	require.Contains(t, config.Backends, "backend-foo")
+
+	expected := &types.BackendTLS{
+		InsecureSkipVerify: true,
+		ServerName:         "foo.internal",
+	}
+	assert.Equal(t, expected, config.Backends["backend-foo"].TLS)
+}
+
+func TestDockerGetCircuitBreaker(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.CircuitBreaker
+	}{
+		{
+			desc:   "no label",
+			labels: map[string]string{},
+		},
+		{
+			desc: "valid expression",
+			labels: map[string]string{
+				label.TraefikBackendCircuitBreakerExpression: "NetworkErrorRatio() > 0.5",
+			},
+			expected: &types.CircuitBreaker{Expression: "NetworkErrorRatio() > 0.5"},
+		},
+		{
+			desc: "combined expression",
+			labels: map[string]string{
+				label.TraefikBackendCircuitBreakerExpression: "LatencyAtQuantileMS(50.0) > 100 || ResponseCodeRatio(500, 600, 0, 600) > 0.25",
+			},
+			expected: &types.CircuitBreaker{Expression: "LatencyAtQuantileMS(50.0) > 100 || ResponseCodeRatio(500, 600, 0, 600) > 0.25"},
+		},
+		{
+			desc: "invalid expression",
+			labels: map[string]string{
+				label.TraefikBackendCircuitBreakerExpression: "NetworkErrorRatio() >",
+			},
+		},
+		{
+			desc: "unknown function",
+			labels: map[string]string{
+				label.TraefikBackendCircuitBreakerExpression: "ErrorRatio() > 0.5",
+			},
+		},
+		{
+			desc: "quotes",
+			labels: map[string]string{
+				label.TraefikBackendCircuitBreakerExpression: `NetworkErrorRatio() > 0.5" foo = "bar`,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := getCircuitBreaker(test.labels)
 			assert.Equal(t, test.expected, actual)
 		})
 	}
 }
 
+func TestDockerGetRetry(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.Retry
+	}{
+		{
+			desc:   "no label",
+			labels: map[string]string{},
+		},
+		{
+			desc: "valid attempts",
+			labels: map[string]string{
+				labelBackendRetryAttempts: "3",
+			},
+			expected: &types.Retry{Attempts: 3},
+		},
+		{
+			desc: "zero",
+			labels: map[string]string{
+				labelBackendRetryAttempts: "0",
+			},
+		},
+		{
+			desc: "negative",
+			labels: map[string]string{
+				labelBackendRetryAttempts: "-2",
+			},
+		},
+		{
+			desc: "not a number",
+			labels: map[string]string{
+				labelBackendRetryAttempts: "three",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := getRetry(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerBuildConfigurationRetry(t *testing.T) {
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("retried"),
+			labels(map[string]string{
+				labelBackendRetryAttempts: "4",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("invalid"),
+			labels(map[string]string{
+				labelBackendRetryAttempts: "-1",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+	} {
+		containers = append(containers, parseContainer(container))
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	require.Contains(t, config.Backends, "backend-retried")
+	assert.Equal(t, &types.Retry{Attempts: 4}, config.Backends["backend-retried"].Retry)
+
+	require.Contains(t, config.Backends, "backend-invalid")
+	assert.Nil(t, config.Backends["backend-invalid"].Retry)
+}
+
+func TestDockerGetPortRange(t *testing.T) {
+	rangePorts := nat.PortMap{
+		"8000/tcp": {},
+		"8001/tcp": {},
+		"8002/tcp": {},
+		"9000/tcp": {},
+	}
+
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected []string
+	}{
+		{
+			desc:   "without label",
+			labels: map[string]string{},
+		},
+		{
+			desc: "from the first port",
+			labels: map[string]string{
+				labelBackendPortRange: "true",
+			},
+			expected: []string{"8000", "8001", "8002"},
+		},
+		{
+			desc: "from the port label",
+			labels: map[string]string{
+				labelBackendPortRange: "true",
+				label.TraefikPort:     "8001",
+			},
+			expected: []string{"8001", "8002"},
+		},
+		{
+			desc: "stopped by a disabled port",
+			labels: map[string]string{
+				labelBackendPortRange:  "true",
+				"traefik.8002.disable": "true",
+			},
+			expected: []string{"8000", "8001"},
+		},
+		{
+			desc: "with a server URL",
+			labels: map[string]string{
+				labelBackendPortRange: "true",
+				labelBackendServerURL: "http://10.0.0.1:8000",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			container := parseContainer(containerJSON(name("foo"), labels(test.labels), ports(rangePorts)))
+			container.SegmentLabels = label.ExtractTraefikLabels(container.Labels)[""]
+
+			assert.Equal(t, test.expected, getPortRange(container))
+		})
+	}
+}
+
+func TestDockerBuildConfigurationPortRange(t *testing.T) {
+	rangePorts := nat.PortMap{
+		"8000/tcp": {},
+		"8001/tcp": {},
+		"8002/tcp": {},
+	}
+
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("single"),
+			ports(rangePorts),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("ranged"),
+			labels(map[string]string{
+				labelBackendPortRange: "true",
+			}),
+			ports(rangePorts),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+	} {
+		containers = append(containers, parseContainer(container))
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	getURLs := func(backend *types.Backend) []string {
+		var urls []string
+		for _, server := range backend.Servers {
+			urls = append(urls, server.URL)
+		}
+		return urls
+	}
+
+	require.Contains(t, config.Backends, "backend-single")
+	assert.Equal(t, []string{"http://10.10.10.10:8000"}, getURLs(config.Backends["backend-single"]))
+
+	require.Contains(t, config.Backends, "backend-ranged")
+	assert.ElementsMatch(t, []string{
+		"http://10.10.10.11:8000",
+		"http://10.10.10.11:8001",
+		"http://10.10.10.11:8002",
+	}, getURLs(config.Backends["backend-ranged"]))
+}
+
+func TestDockerGetRedirect(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.Redirect
+	}{
+		{
+			desc:   "no label",
+			labels: map[string]string{},
+		},
+		{
+			desc: "entry point",
+			labels: map[string]string{
+				label.TraefikFrontendRedirectEntryPoint: "https",
+				label.TraefikFrontendRedirectPermanent:  "true",
+			},
+			expected: &types.Redirect{EntryPoint: "https", Permanent: true},
+		},
+		{
+			desc: "unknown entry point",
+			labels: map[string]string{
+				label.TraefikFrontendRedirectEntryPoint: "htps",
+			},
+		},
+		{
+			desc: "regex",
+			labels: map[string]string{
+				label.TraefikFrontendRedirectRegex:       `^http://localhost/(.*)`,
+				label.TraefikFrontendRedirectReplacement: `http://mydomain/$1`,
+			},
+			expected: &types.Redirect{Regex: `^http://localhost/(.*)`, Replacement: `http://mydomain/$1`},
+		},
+		{
+			desc: "regex with an escaped backslash",
+			labels: map[string]string{
+				label.TraefikFrontendRedirectRegex:       `^http://www\\.(.*)`,
+				label.TraefikFrontendRedirectReplacement: `http://$1`,
+			},
+			expected: &types.Redirect{Regex: `^http://www\\.(.*)`, Replacement: `http://$1`},
+		},
+		{
+			desc: "invalid regex",
+			labels: map[string]string{
+				label.TraefikFrontendRedirectRegex:       `^http://localhost/(.*`,
+				label.TraefikFrontendRedirectReplacement: `http://mydomain/$1`,
+			},
+		},
+		{
+			desc: "regex with an invalid escape",
+			labels: map[string]string{
+				label.TraefikFrontendRedirectRegex:       `^http://www\.(.*)`,
+				label.TraefikFrontendRedirectReplacement: `http://$1`,
+			},
+		},
+		{
+			desc: "replacement with quotes",
+			labels: map[string]string{
+				label.TraefikFrontendRedirectRegex:       `^http://localhost/(.*)`,
+				label.TraefikFrontendRedirectReplacement: `http://mydomain/$1" foo = "bar`,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{EntryPoints: []string{"http", "https"}}
+
+			actual := provider.getRedirect(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerBuildConfigurationRedirect(t *testing.T) {
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("secure"),
+			labels(map[string]string{
+				label.TraefikFrontendRedirectEntryPoint: "https",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("invalid"),
+			labels(map[string]string{
+				label.TraefikFrontendRedirectRegex:       `^http://(.*`,
+				label.TraefikFrontendRedirectReplacement: `https://$1`,
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+	} {
+		containers = append(containers, parseContainer(container))
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		EntryPoints:      []string{"http", "https"},
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+	require.Len(t, config.Frontends, 2)
+
+	require.Contains(t, config.Frontends, "frontend-Host-secure-docker-localhost-1")
+	assert.Equal(t, &types.Redirect{EntryPoint: "https"}, config.Frontends["frontend-Host-secure-docker-localhost-1"].Redirect)
+
+	// The frontend is kept without the invalid redirect.
+	require.Contains(t, config.Frontends, "frontend-Host-invalid-docker-localhost-0")
+	assert.Nil(t, config.Frontends["frontend-Host-invalid-docker-localhost-0"].Redirect)
+}
+
+func TestDockerGetLoadBalancer(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.LoadBalancer
+	}{
+		{
+			desc:     "no load balancer",
+			labels:   map[string]string{},
+			expected: nil,
+		},
+		{
+			desc: "valid method",
+			labels: map[string]string{
+				label.TraefikBackendLoadBalancerMethod: "drr",
+			},
+			expected: &types.LoadBalancer{
+				Method: "drr",
+			},
+		},
+		{
+			desc: "valid method with another case",
+			labels: map[string]string{
+				label.TraefikBackendLoadBalancerMethod: "WRR",
+			},
+			expected: &types.LoadBalancer{
+				Method: "WRR",
+			},
+		},
+		{
+			desc: "invalid method",
+			labels: map[string]string{
+				label.TraefikBackendLoadBalancerMethod:     "random",
+				label.TraefikBackendLoadBalancerStickiness: "true",
+			},
+			expected: &types.LoadBalancer{
+				Method:     "wrr",
+				Stickiness: &types.Stickiness{CookieName: label.DefaultBackendLoadbalancerStickinessCookieName},
+			},
+		},
+		{
+			desc: "stickiness without method",
+			labels: map[string]string{
+				label.TraefikBackendLoadBalancerStickiness: "true",
+			},
+			expected: &types.LoadBalancer{
+				Method:     "wrr",
+				Stickiness: &types.Stickiness{CookieName: label.DefaultBackendLoadbalancerStickinessCookieName},
+			},
+		},
+		{
+			desc: "source IP stickiness",
+			labels: map[string]string{
+				labelBackendStickySourceIP: "true",
+			},
+			expected: &types.LoadBalancer{
+				Method:     "wrr",
+				Stickiness: &types.Stickiness{SourceIP: true},
+			},
+		},
+		{
+			desc: "source IP and cookie stickiness",
+			labels: map[string]string{
+				label.TraefikBackendLoadBalancerStickiness: "true",
+				labelBackendStickySourceIP:                 "true",
+			},
+			expected: &types.LoadBalancer{
+				Method:     "wrr",
+				Stickiness: &types.Stickiness{CookieName: label.DefaultBackendLoadbalancerStickinessCookieName},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := getLoadBalancer(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerGetHeaders(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected *types.Headers
+	}{
+		{
+			desc:     "no headers",
+			labels:   map[string]string{},
+			expected: nil,
+		},
+		{
+			desc: "request headers added and response header removed",
+			labels: map[string]string{
+				label.TraefikFrontendRequestHeaders + ".X-Custom-One":  "one",
+				label.TraefikFrontendRequestHeaders + ".x-custom-two":  " two ",
+				label.TraefikFrontendResponseHeaders + ".X-Powered-By": "",
+			},
+			expected: &types.Headers{
+				CustomRequestHeaders: map[string]string{
+					"X-Custom-One": "one",
+					"X-Custom-Two": "two",
+				},
+				CustomResponseHeaders: map[string]string{
+					"X-Powered-By": "",
+				},
+			},
+		},
+		{
+			desc: "merged with the combined label",
+			labels: map[string]string{
+				label.TraefikFrontendRequestHeaders:                   "X-Custom-One:combined||X-Custom-Two:combined",
+				label.TraefikFrontendRequestHeaders + ".X-Custom-One": "single",
+			},
+			expected: &types.Headers{
+				CustomRequestHeaders: map[string]string{
+					"X-Custom-One": "single",
+					"X-Custom-Two": "combined",
+				},
+			},
+		},
+		{
+			desc: "invalid header name and value",
+			labels: map[string]string{
+				label.TraefikFrontendRequestHeaders + ".X Custom":     "value",
+				label.TraefikFrontendRequestHeaders + ".X-Quoted":     `a "quoted" value`,
+				label.TraefikFrontendResponseHeaders + ".X-Valid-One": "valid",
+			},
+			expected: &types.Headers{
+				CustomResponseHeaders: map[string]string{
+					"X-Valid-One": "valid",
+				},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			actual := getHeaders(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerGetPassHostHeader(t *testing.T) {
+	testCases := []struct {
+		desc                  string
+		labels                map[string]string
+		disablePassHostHeader bool
+		expected              bool
+	}{
+		{
+			desc:     "absent",
+			labels:   map[string]string{},
+			expected: true,
+		},
+		{
+			desc:                  "absent with the provider disabling it",
+			labels:                map[string]string{},
+			disablePassHostHeader: true,
+			expected:              false,
+		},
+		{
+			desc: "true",
+			labels: map[string]string{
+				label.TraefikFrontendPassHostHeader: "true",
+			},
+			disablePassHostHeader: true,
+			expected:              true,
+		},
+		{
+			desc: "false",
+			labels: map[string]string{
+				label.TraefikFrontendPassHostHeader: "false",
+			},
+			expected: false,
+		},
+		{
+			desc: "invalid",
+			labels: map[string]string{
+				label.TraefikFrontendPassHostHeader: "yes please",
+			},
+			expected: true,
+		},
+		{
+			desc: "invalid with the provider disabling it",
+			labels: map[string]string{
+				label.TraefikFrontendPassHostHeader: "yes please",
+			},
+			disablePassHostHeader: true,
+			expected:              false,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{DisablePassHostHeader: test.disablePassHostHeader}
+
+			actual := provider.getPassHostHeader(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerGetPriority(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected int
+	}{
+		{
+			desc:     "no label",
+			labels:   map[string]string{},
+			expected: label.DefaultFrontendPriority,
+		},
+		{
+			desc: "numeric",
+			labels: map[string]string{
+				label.TraefikFrontendPriority: "10",
+			},
+			expected: 10,
+		},
+		{
+			desc: "negative",
+			labels: map[string]string{
+				label.TraefikFrontendPriority: "-5",
+			},
+			expected: -5,
+		},
+		{
+			desc: "surrounded by spaces",
+			labels: map[string]string{
+				label.TraefikFrontendPriority: " 20 ",
+			},
+			expected: 20,
+		},
+		{
+			desc: "invalid",
+			labels: map[string]string{
+				label.TraefikFrontendPriority: "high",
+			},
+			expected: label.DefaultFrontendPriority,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			container := dockerData{Name: "test", SegmentLabels: test.labels}
+
+			actual := getPriority(container)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerGetEntryPoints(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		expected []string
+	}{
+		{
+			desc:     "no entry point",
+			labels:   map[string]string{},
+			expected: nil,
+		},
+		{
+			desc: "known entry points",
+			labels: map[string]string{
+				label.TraefikFrontendEntryPoints: "http,https",
+			},
+			expected: []string{"http", "https"},
+		},
+		{
+			desc: "unknown entry point",
+			labels: map[string]string{
+				label.TraefikFrontendEntryPoints: "http,htps",
+			},
+			expected: []string{"http"},
+		},
+		{
+			desc: "TLS requested",
+			labels: map[string]string{
+				labelFrontendTLS: "true",
+			},
+			expected: []string{"https"},
+		},
+		{
+			desc: "TLS requested with entry points",
+			labels: map[string]string{
+				label.TraefikFrontendEntryPoints: "http,https",
+				labelFrontendTLS:                 "true",
+			},
+			expected: []string{"http", "https"},
+		},
+		{
+			desc: "TLS disabled",
+			labels: map[string]string{
+				label.TraefikFrontendEntryPoints: "http",
+				labelFrontendTLS:                 "false",
+			},
+			expected: []string{"http"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				EntryPoints:    []string{"http", "https"},
+				TLSEntryPoints: []string{"https"},
+			}
+
+			actual := provider.getEntryPoints(test.labels)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerBuildConfigurationEntryPoints(t *testing.T) {
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("secure"),
+			labels(map[string]string{
+				label.TraefikFrontendEntryPoints: "http",
+				labelFrontendTLS:                 "true",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("typo"),
+			labels(map[string]string{
+				label.TraefikFrontendEntryPoints: "htps",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+		containerJSON(name("partial"),
+			labels(map[string]string{
+				label.TraefikFrontendEntryPoints: "http, htps",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.12"))),
+		containerJSON(name("default"),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.13"))),
+	} {
+		containers = append(containers, parseContainer(container))
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		EntryPoints:      []string{"http", "https"},
+		TLSEntryPoints:   []string{"https"},
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	require.Len(t, config.Frontends, 3)
+	require.Contains(t, config.Frontends, "frontend-Host-secure-docker-localhost-2")
+	assert.Equal(t, []string{"http", "https"}, config.Frontends["frontend-Host-secure-docker-localhost-2"].EntryPoints)
+
+	// The unknown entry points are dropped.
+	require.Contains(t, config.Frontends, "frontend-Host-partial-docker-localhost-1")
+	assert.Equal(t, []string{"http"}, config.Frontends["frontend-Host-partial-docker-localhost-1"].EntryPoints)
+
+	// Without label, the frontend is on the default entry points.
+	require.Contains(t, config.Frontends, "frontend-Host-default-docker-localhost-0")
+	assert.Empty(t, config.Frontends["frontend-Host-default-docker-localhost-0"].EntryPoints)
+}
+
+func TestDockerBuildConfigurationHeadersRule(t *testing.T) {
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("valid"),
+			labels(map[string]string{
+				label.TraefikFrontendRule: "Host:api.foo;Headers:X-Api-Version,2",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("malformed"),
+			labels(map[string]string{
+				label.TraefikFrontendRule: "Host:api.foo;Headers:X-Api-Version",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+	} {
+		containers = append(containers, parseContainer(container))
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	require.Len(t, config.Frontends, 1)
+	for _, frontend := range config.Frontends {
+		assert.Equal(t, "backend-valid", frontend.Backend)
+		for _, route := range frontend.Routes {
+			assert.Equal(t, "Host:api.foo;Headers:X-Api-Version,2", route.Rule)
+		}
+	}
+
+	// Only the frontend is skipped.
+	assert.Contains(t, config.Backends, "backend-malformed")
+}
+
+func TestCheckFrontendRule(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		rule     string
+		hasError bool
+	}{
+		{
+			desc: "without header matcher",
+			rule: "Host:foo.bar;PathPrefix:/api",
+		},
+		{
+			desc: "headers",
+			rule: "Host:foo.bar;Headers:X-Foo,bar,X-Bar,foo",
+		},
+		{
+			desc: "headers with spaces",
+			rule: "Headers: X-Foo, bar",
+		},
+		{
+			desc: "headers regexp",
+			rule: "HeadersRegexp:Content-Type,application/(text|json)",
+		},
+		{
+			desc:     "header without value",
+			rule:     "Host:foo.bar;Headers:X-Foo",
+			hasError: true,
+		},
+		{
+			desc:     "headers without arguments",
+			rule:     "Headers",
+			hasError: true,
+		},
+		{
+			desc:     "invalid header name",
+			rule:     "Headers:X Foo,bar",
+			hasError: true,
+		},
+		{
+			desc:     "invalid regular expression",
+			rule:     "HeadersRegexp:X-Foo,(bar",
+			hasError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkFrontendRule(test.rule)
+			if test.hasError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDockerGetPort(t *testing.T) {
+	testCases := []struct {
+		container docker.ContainerJSON
+		expected  string
+	}{
+		{
+			container: containerJSON(name("foo")),
+			expected:  "",
+		},
+		{
+			container: containerJSON(ports(nat.PortMap{
+				"80/tcp": {},
+			})),
+			expected: "80",
+		},
+		{
+			container: containerJSON(ports(nat.PortMap{
+				"80/tcp":  {},
+				"443/tcp": {},
+			})),
+			expected: "80",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				label.TraefikPort: "8080",
+			})),
+			expected: "8080",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				label.TraefikPort: "8080",
+			}), ports(nat.PortMap{
+				"80/tcp": {},
+			})),
+			expected: "8080",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				label.TraefikPort: "8080",
+			}), ports(nat.PortMap{
+				"8080/tcp": {},
+				"80/tcp":   {},
+			})),
+			expected: "8080",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				"traefik.9090.disable": "true",
+			}), ports(nat.PortMap{
+				"8080/tcp": {},
+				"9090/tcp": {},
+			})),
+			expected: "8080",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				"traefik.8080.disable": "true",
+			}), ports(nat.PortMap{
+				"8080/tcp": {},
+				"9090/tcp": {},
+			})),
+			expected: "9090",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				"traefik.8080.disable": "true",
+				"traefik.9090.disable": "true",
+			}), ports(nat.PortMap{
+				"8080/tcp": {},
+				"9090/tcp": {},
+			})),
+			expected: "",
+		},
+		{
+			container: containerJSON(exposedPorts("8080/tcp")),
+			expected:  "8080",
+		},
+		{
+			container: containerJSON(exposedPorts("8080/tcp", "9090/tcp")),
+			expected:  "",
+		},
+		{
+			container: containerJSON(labels(map[string]string{
+				label.TraefikPort: "9090",
+			}), exposedPorts("8080/tcp", "9090/tcp")),
+			expected: "9090",
+		},
+		{
+			container: containerJSON(ports(nat.PortMap{
+				"80/tcp": {},
+			}), exposedPorts("8080/tcp")),
+			expected: "80",
+		},
+	}
+
+	for containerID, test := range testCases {
+		test := test
+		t.Run(strconv.Itoa(containerID), func(t *testing.T) {
+			t.Parallel()
+
+			dData := parseContainer(test.container)
+			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
+			dData.SegmentLabels = segmentProperties[""]
+
+			actual := getPort(dData)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerGetServersURL(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		useBindPortIP   bool
+		swarmClassic    bool
+		endpoint        string
+		defaultProtocol string
+		container       docker.ContainerJSON
+		expected        string
+	}{
+		{
+			desc: "host network with a port label",
+			container: containerJSON(
+				networkMode("host"),
+				labels(map[string]string{
+					label.TraefikPort: "8080",
+				})),
+			expected: "http://127.0.0.1:8080",
+		},
+		{
+			desc:     "host network of a remote daemon",
+			endpoint: "tcp://10.0.0.2:2376",
+			container: containerJSON(
+				networkMode("host"),
+				labels(map[string]string{
+					label.TraefikPort: "8080",
+				})),
+			expected: "http://10.0.0.2:8080",
+		},
+		{
+			desc:     "host network of a local daemon",
+			endpoint: "unix:///var/run/docker.sock",
+			container: containerJSON(
+				networkMode("host"),
+				labels(map[string]string{
+					label.TraefikPort: "8080",
+				})),
+			expected: "http://127.0.0.1:8080",
+		},
+		{
+			desc:         "swarm classic node IP",
+			swarmClassic: true,
+			container: containerJSON(
+				nodeIP("192.168.0.2"),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {{HostIP: "0.0.0.0", HostPort: "32768"}}})),
+			expected: "http://192.168.0.2:32768",
+		},
+		{
+			desc:         "swarm classic without published port",
+			swarmClassic: true,
+			container: containerJSON(
+				nodeIP("192.168.0.2"),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "",
+		},
+		{
+			desc:         "swarm classic without node",
+			swarmClassic: true,
+			container: containerJSON(
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "http://10.10.10.10:80",
+		},
+		{
+			desc: "node IP without swarm classic",
+			container: containerJSON(
+				nodeIP("192.168.0.2"),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {{HostIP: "0.0.0.0", HostPort: "32768"}}})),
+			expected: "http://10.10.10.10:80",
+		},
+		{
+			desc:            "default protocol",
+			defaultProtocol: "https",
+			container: containerJSON(
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"443/tcp": {}})),
+			expected: "https://10.10.10.10:443",
+		},
+		{
+			desc:            "protocol label over default protocol",
+			defaultProtocol: "https",
+			container: containerJSON(
+				labels(map[string]string{
+					label.TraefikProtocol: "http",
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "http://10.10.10.10:80",
+		},
+		{
+			desc: "IPv4 network address",
+			container: containerJSON(
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "http://10.10.10.10:80",
+		},
+		{
+			desc: "IPv6 network address",
+			container: containerJSON(
+				withNetwork("testnet", ipv6("2001:db8::10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "http://[2001:db8::10]:80",
+		},
+		{
+			desc: "IPv6 address label with brackets",
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendAddress: "[2001:db8::20]",
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "http://[2001:db8::20]:80",
+		},
+		{
+			desc: "hostname address label",
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendAddress: "backend.example.com",
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "http://backend.example.com:80",
+		},
+		{
+			desc:          "IPv4 port binding",
+			useBindPortIP: true,
+			container: containerJSON(
+				ports(nat.PortMap{
+					"80/tcp": []nat.PortBinding{{HostIP: "1.2.3.4", HostPort: "8081"}},
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10"))),
+			expected: "http://1.2.3.4:8081",
+		},
+		{
+			desc:          "IPv6 port binding",
+			useBindPortIP: true,
+			container: containerJSON(
+				ports(nat.PortMap{
+					"80/tcp": []nat.PortBinding{{HostIP: "2001:db8::1", HostPort: "8081"}},
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10"))),
+			expected: "http://[2001:db8::1]:8081",
+		},
+		{
+			desc:          "unspecified IPv6 port binding",
+			useBindPortIP: true,
+			container: containerJSON(
+				ports(nat.PortMap{
+					"80/tcp": []nat.PortBinding{{HostIP: "::", HostPort: "8081"}},
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10"))),
+		},
+		{
+			desc: "server URL label",
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendServerURL: "https://proxy.local:8443/app",
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "https://proxy.local:8443/app",
+		},
+		{
+			desc: "server URL label without port",
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendServerURL: "http://proxy.local",
+				})),
+			expected: "http://proxy.local",
+		},
+		{
+			desc: "invalid server URL label",
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendServerURL: "://proxy.local",
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "http://10.10.10.10:80",
+		},
+		{
+			desc: "server URL label without scheme",
+			container: containerJSON(
+				labels(map[string]string{
+					labelBackendServerURL: "proxy.local:8080",
+				}),
+				withNetwork("testnet", ipv4("10.10.10.10")),
+				ports(nat.PortMap{"80/tcp": {}})),
+			expected: "http://10.10.10.10:80",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			p := &Provider{
+				UseBindPortIP:   test.useBindPortIP,
+				SwarmClassic:    test.swarmClassic,
+				Endpoint:        test.endpoint,
+				DefaultProtocol: test.defaultProtocol,
+			}
+
+			dData := parseContainer(test.container)
+			dData.SegmentLabels = label.ExtractTraefikLabels(dData.Labels)[""]
+
+			servers := p.getServers([]dockerData{dData})
+
+			var urls []string
+			for _, server := range servers {
+				urls = append(urls, server.URL)
+			}
+
+			if len(test.expected) == 0 {
+				assert.Empty(t, urls)
+			} else {
+				assert.Equal(t, []string{test.expected}, urls)
+			}
+		})
+	}
+}
+
+func TestDockerSharedBackend(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		stableLabels     map[string]string
+		canaryLabels     map[string]string
+		expectedConflict bool
+	}{
+		{
+			desc: "same backend labels",
+			stableLabels: map[string]string{
+				label.TraefikBackendLoadBalancerMethod:     "drr",
+				label.TraefikBackendLoadBalancerStickiness: "true",
+			},
+			canaryLabels: map[string]string{
+				label.TraefikBackendLoadBalancerMethod:     "drr",
+				label.TraefikBackendLoadBalancerStickiness: "true",
+			},
+		},
+		{
+			desc: "backend labels on a single container",
+			stableLabels: map[string]string{
+				label.TraefikBackendLoadBalancerMethod: "drr",
+			},
+			canaryLabels:     map[string]string{},
+			expectedConflict: true,
+		},
+		{
+			desc: "different backend labels",
+			stableLabels: map[string]string{
+				label.TraefikBackendLoadBalancerMethod: "drr",
+			},
+			canaryLabels: map[string]string{
+				label.TraefikBackendLoadBalancerMethod: "wrr",
+			},
+			expectedConflict: true,
+		},
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stdout)
+
+	for _, test := range testCases {
+		t.Run(test.desc, func(t *testing.T) {
+			logs.Reset()
+
+			stableLabels := map[string]string{
+				label.TraefikBackend: "web",
+				label.TraefikWeight:  "9",
+			}
+			for key, value := range test.stableLabels {
+				stableLabels[key] = value
+			}
+
+			canaryLabels := map[string]string{
+				label.TraefikBackend: "web",
+				label.TraefikWeight:  "1",
+			}
+			for key, value := range test.canaryLabels {
+				canaryLabels[key] = value
+			}
+
+			var containers []dockerData
+			for _, container := range []docker.ContainerJSON{
+				containerJSON(name("stable"), labels(stableLabels),
+					ports(nat.PortMap{"80/tcp": {}}),
+					withNetwork("testnet", ipv4("10.10.10.10"))),
+				containerJSON(name("canary"), labels(canaryLabels),
+					ports(nat.PortMap{"80/tcp": {}}),
+					withNetwork("testnet", ipv4("10.10.10.11"))),
+			} {
+				dData := parseContainer(container)
+				dData.SegmentLabels = label.ExtractTraefikLabels(dData.Labels)[""]
+				containers = append(containers, dData)
+			}
+
+			provider := &Provider{
+				Domain:           "docker.localhost",
+				ExposedByDefault: true,
+			}
+
+			config := provider.buildConfiguration(containers)
+			require.NotNil(t, config)
+			require.Len(t, config.Backends, 1)
+			require.Contains(t, config.Backends, "backend-web")
+
+			weights := make(map[string]int)
+			for _, server := range config.Backends["backend-web"].Servers {
+				weights[server.URL] = server.Weight
+			}
+			assert.Equal(t, map[string]int{"http://10.10.10.10:80": 9, "http://10.10.10.11:80": 1}, weights)
+
+			// The conflict is only reported by a warning, the labels of the first container being used.
+			if test.expectedConflict {
+				assert.Contains(t, logs.String(), "of backend web have different backend labels")
+			} else {
+				assert.NotContains(t, logs.String(), "have different backend labels")
+			}
+		})
+	}
+}
+
+func TestDockerHealthPolicy(t *testing.T) {
+	testCases := []struct {
+		healthPolicy    string
+		expectedWeights map[string]int
+	}{
+		{
+			healthPolicy: healthPolicyStrict,
+			expectedWeights: map[string]int{
+				"http://10.10.10.10:80": 1,
+			},
+		},
+		{
+			healthPolicy: healthPolicyDegraded,
+			expectedWeights: map[string]int{
+				"http://10.10.10.10:80": 10,
+				"http://10.10.10.11:80": 1,
+			},
+		},
+		{
+			healthPolicy: healthPolicyIgnore,
+			expectedWeights: map[string]int{
+				"http://10.10.10.10:80": 1,
+				"http://10.10.10.11:80": 1,
+				"http://10.10.10.12:80": 1,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.healthPolicy, func(t *testing.T) {
+			t.Parallel()
+
+			// The containers are named after their health status.
+			addresses := map[string]string{
+				"healthy":   "10.10.10.10",
+				"unhealthy": "10.10.10.11",
+				"starting":  "10.10.10.12",
+			}
+
+			var containers []dockerData
+			for _, containerName := range []string{"healthy", "unhealthy", "starting"} {
+				dData := parseContainer(containerJSON(name(containerName),
+					labels(map[string]string{label.TraefikBackend: "web"}),
+					ports(nat.PortMap{"80/tcp": {}}),
+					withNetwork("testnet", ipv4(addresses[containerName]))))
+				dData.Health = containerName
+				containers = append(containers, dData)
+			}
+
+			provider := &Provider{
+				Domain:           "docker.localhost",
+				ExposedByDefault: true,
+				HealthPolicy:     test.healthPolicy,
+			}
+
+			config := provider.buildConfiguration(containers)
+			require.NotNil(t, config)
+			require.Contains(t, config.Backends, "backend-web")
+
+			weights := make(map[string]int)
+			for _, server := range config.Backends["backend-web"].Servers {
+				weights[server.URL] = server.Weight
+			}
+			assert.Equal(t, test.expectedWeights, weights)
+		})
+	}
+}
+
+func TestDockerHostNetworkWithoutPort(t *testing.T) {
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	dData := parseContainer(containerJSON(name("web"), networkMode("host")))
+
+	reason, warning := provider.getFilterReason(dData)
+	assert.Equal(t, "no port, the containers on the host network need the traefik.port label", reason)
+	assert.True(t, warning)
+}
+
+func TestDockerBuildConfigurationWithoutPort(t *testing.T) {
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	containers := []dockerData{
+		parseContainer(containerJSON(name("portless"),
+			withNetwork("testnet", ipv4("10.10.10.10")))),
+		parseContainer(containerJSON(name("web"),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11")))),
+	}
+
+	var logs bytes.Buffer
+	level := log.GetLevel()
+	log.SetOutput(&logs)
+	log.SetLevel(logrus.WarnLevel)
+	defer func() {
+		log.SetOutput(os.Stdout)
+		log.SetLevel(level)
+	}()
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	assert.NotContains(t, config.Backends, "backend-portless")
+	assert.Contains(t, config.Backends, "backend-web")
+	assert.Contains(t, logs.String(), "Filtering container portless: no port, none is published nor exposed: set the traefik.port label")
+}
+
+func TestDockerGetServersMaintenance(t *testing.T) {
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("normal"),
+			labels(map[string]string{label.TraefikWeight: "3"}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("drained"),
+			labels(map[string]string{
+				label.TraefikWeight:     "3",
+				labelBackendMaintenance: "true",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+	} {
+		dData := parseContainer(container)
+		dData.SegmentLabels = label.ExtractTraefikLabels(dData.Labels)[""]
+		containers = append(containers, dData)
+	}
+
+	provider := &Provider{}
+
+	actual := make(map[string]types.Server)
+	for _, server := range provider.getServers(containers) {
+		actual[server.URL] = server
+	}
+
+	expected := map[string]types.Server{
+		"http://10.10.10.10:80": {
+			URL:    "http://10.10.10.10:80",
+			Weight: 3,
+		},
+		"http://10.10.10.11:80": {
+			URL:         "http://10.10.10.11:80",
+			Weight:      0,
+			Maintenance: true,
+		},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestDockerGetServersBindAddresses(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		bindHostIP string
+		bindings   []nat.PortBinding
+		expected   []string
+	}{
+		{
+			desc: "one server per specific IP binding",
+			bindings: []nat.PortBinding{
+				{HostIP: "192.168.0.10", HostPort: "8080"},
+				{HostIP: "192.168.1.10", HostPort: "8080"},
+			},
+			expected: []string{"http://192.168.0.10:8080", "http://192.168.1.10:8080"},
+		},
+		{
+			desc: "wildcard binding ignored",
+			bindings: []nat.PortBinding{
+				{HostIP: "0.0.0.0", HostPort: "8080"},
+				{HostIP: "192.168.1.10", HostPort: "8081"},
+			},
+			expected: []string{"http://192.168.1.10:8081"},
+		},
+		{
+			desc:       "wildcard binding with a bind host IP",
+			bindHostIP: "10.0.0.1",
+			bindings: []nat.PortBinding{
+				{HostIP: "0.0.0.0", HostPort: "8080"},
+				{HostIP: "192.168.1.10", HostPort: "8081"},
+			},
+			expected: []string{"http://10.0.0.1:8080", "http://192.168.1.10:8081"},
+		},
+		{
+			desc: "only wildcard bindings",
+			bindings: []nat.PortBinding{
+				{HostIP: "0.0.0.0", HostPort: "8080"},
+				{HostPort: "8081"},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dData := parseContainer(containerJSON(name("web"),
+				ports(nat.PortMap{"80/tcp": test.bindings}),
+				withNetwork("testnet", ipv4("10.10.10.10"))))
+			dData.SegmentLabels = label.ExtractTraefikLabels(dData.Labels)[""]
+
+			provider := &Provider{
+				UseBindPortIP: true,
+				BindHostIP:    test.bindHostIP,
+			}
+
+			var actual []string
+			for _, server := range provider.getServers([]dockerData{dData}) {
+				actual = append(actual, server.URL)
+			}
+			sort.Strings(actual)
+
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestDockerBuildConfigurationFailover(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		priorities map[string]string
+		failover   string
+		expected   map[string]bool
+	}{
+		{
+			desc:     "failover without priority",
+			failover: "true",
+			expected: map[string]bool{
+				"http://10.10.10.10:80": false,
+				"http://10.10.10.11:80": true,
+			},
+		},
+		{
+			desc:     "failover with priorities",
+			failover: "true",
+			priorities: map[string]string{
+				"primary": "2",
+				"standby": "1",
+			},
+			expected: map[string]bool{
+				"http://10.10.10.10:80": true,
+				"http://10.10.10.11:80": false,
+			},
+		},
+		{
+			desc:     "load balancing",
+			failover: "false",
+			expected: map[string]bool{
+				"http://10.10.10.10:80": false,
+				"http://10.10.10.11:80": false,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var containers []dockerData
+			for _, server := range []struct{ name, ip string }{{"primary", "10.10.10.10"}, {"standby", "10.10.10.11"}} {
+				containerLabels := map[string]string{
+					label.TraefikBackend: "web",
+					labelBackendFailover: test.failover,
+				}
+				if priority, ok := test.priorities[server.name]; ok {
+					containerLabels[labelBackendFailoverPriority] = priority
+				}
+
+				containers = append(containers, parseContainer(containerJSON(name(server.name),
+					labels(containerLabels),
+					ports(nat.PortMap{"80/tcp": {}}),
+					withNetwork("testnet", ipv4(server.ip)))))
+			}
+
+			provider := &Provider{
+				Domain:           "docker.localhost",
+				ExposedByDefault: true,
+			}
+
+			config := provider.buildConfiguration(containers)
+			require.NotNil(t, config)
+			require.Contains(t, config.Backends, "backend-web")
+
+			actual := make(map[string]bool)
+			for _, server := range config.Backends["backend-web"].Servers {
+				actual[server.URL] = server.Standby
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestSortServersByOrder(t *testing.T) {
+	container := func(containerName, order string) dockerData {
+		containerLabels := map[string]string{}
+		if len(order) > 0 {
+			containerLabels[labelBackendOrder] = order
+		}
+		return dockerData{Name: containerName, SegmentLabels: containerLabels}
+	}
+
+	servers := map[string][]dockerData{
+		"backend-web": {
+			container("unordered", ""),
+			container("third", "10"),
+			container("invalid", "last"),
+			container("first", "-1"),
+			container("second", "2"),
+		},
+		"backend-api": {
+			container("api", "1"),
+		},
+	}
+
+	sortServersByOrder(servers)
+
+	var actual []string
+	for _, container := range servers["backend-web"] {
+		actual = append(actual, container.Name)
+	}
+	assert.Equal(t, []string{"first", "second", "third", "unordered", "invalid"}, actual)
+	assert.Len(t, servers["backend-api"], 1)
+}
+
+func TestDockerBuildConfigurationSortServersByOrder(t *testing.T) {
+	var containers []dockerData
+	for _, server := range []struct{ name, ip, order string }{
+		{"a", "10.10.10.10", "3"},
+		{"b", "10.10.10.11", "1"},
+		{"c", "10.10.10.12", "2"},
+	} {
+		containers = append(containers, parseContainer(containerJSON(name(server.name),
+			labels(map[string]string{
+				label.TraefikBackend: "web",
+				labelBackendFailover: "true",
+				labelBackendOrder:    server.order,
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4(server.ip)))))
+	}
+
+	provider := &Provider{
+		Domain:             "docker.localhost",
+		ExposedByDefault:   true,
+		SortServersByOrder: true,
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+	require.Contains(t, config.Backends, "backend-web")
+
+	actual := make(map[string]bool)
+	for _, server := range config.Backends["backend-web"].Servers {
+		actual[server.URL] = server.Standby
+	}
+	expected := map[string]bool{
+		"http://10.10.10.10:80": true,
+		"http://10.10.10.11:80": false,
+		"http://10.10.10.12:80": true,
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestDockerNameRewrite(t *testing.T) {
+	composeLabels := func(service string) map[string]string {
+		return map[string]string{
+			labelDockerComposeProject: "myproject",
+			labelDockerComposeService: service,
+		}
+	}
+
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("myproject_web_1"), labels(composeLabels("web")),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("myproject_web_2"), labels(composeLabels("web")),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+		containerJSON(name("myproject_api_1"), labels(composeLabels("api")),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.12"))),
+	} {
+		containers = append(containers, parseContainer(container))
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		NameRewrite: &NameRewrite{
+			Regex:       `^(.+)_myproject$`,
+			Replacement: "$1",
+		},
+	}
+	require.NoError(t, provider.Init(nil))
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	var backendNames []string
+	for backendName := range config.Backends {
+		backendNames = append(backendNames, backendName)
+	}
+	assert.ElementsMatch(t, []string{"backend-web", "backend-api"}, backendNames)
+	assert.Len(t, config.Backends["backend-web"].Servers, 2)
+}
+
+func TestDockerNameRewriteCollision(t *testing.T) {
+	var containers []dockerData
+	for _, containerName := range []string{"blue-web", "green-web"} {
+		containers = append(containers, parseContainer(containerJSON(name(containerName))))
+	}
+
+	provider := &Provider{
+		NameRewrite: &NameRewrite{
+			Regex:       `^(blue|green)-`,
+			Replacement: "",
+		},
+	}
+	require.NoError(t, provider.Init(nil))
+
+	var logs bytes.Buffer
+	level := log.GetLevel()
+	log.SetOutput(&logs)
+	log.SetLevel(logrus.WarnLevel)
+	defer func() {
+		log.SetOutput(os.Stdout)
+		log.SetLevel(level)
+	}()
+
+	rewritten := provider.rewriteServiceNames(containers)
+	require.Len(t, rewritten, 2)
+	assert.Equal(t, "web", getServiceName(rewritten[0]))
+	assert.Equal(t, "web", getServiceName(rewritten[1]))
+	assert.Contains(t, logs.String(), "The services blue-web and green-web are both rewritten to web")
+}
+
+func TestDockerComposeServiceNames(t *testing.T) {
+	composeLabels := map[string]string{
+		labelDockerComposeProject: "myproject",
+		labelDockerComposeService: "web",
+	}
+
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("myproject_web_1"), labels(composeLabels),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("myproject_web_2"), labels(composeLabels),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+		containerJSON(name("standalone"),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.12"))),
+	} {
+		containers = append(containers, parseContainer(container))
+	}
+
+	testCases := []struct {
+		desc                string
+		composeServiceNames bool
+		expected            []string
+	}{
+		{
+			desc:     "named after the compose service and project",
+			expected: []string{"backend-web-myproject", "backend-standalone"},
+		},
+		{
+			desc:                "named after the compose service",
+			composeServiceNames: true,
+			expected:            []string{"backend-web", "backend-standalone"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				Domain:              "docker.localhost",
+				ExposedByDefault:    true,
+				ComposeServiceNames: test.composeServiceNames,
+			}
+
+			config := provider.buildConfiguration(containers)
+			require.NotNil(t, config)
+
+			var backendNames []string
+			for backendName := range config.Backends {
+				backendNames = append(backendNames, backendName)
+			}
+			assert.ElementsMatch(t, test.expected, backendNames)
+
+			// The scaled replicas form one backend.
+			assert.Len(t, config.Backends[test.expected[0]].Servers, 2)
+			assert.Len(t, config.Backends["backend-standalone"].Servers, 1)
+		})
+	}
+}
+
 func TestDockerGetServers(t *testing.T) {
 	p := &Provider{}
 
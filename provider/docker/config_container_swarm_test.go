@@ -1,7 +1,10 @@
 package docker
 
 import (
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -318,7 +321,7 @@ func TestSwarmBuildConfiguration(t *testing.T) {
 						label.TraefikBackendHealthCheckScheme:                "http",
 						label.TraefikBackendHealthCheckPath:                  "/health",
 						label.TraefikBackendHealthCheckPort:                  "880",
-						label.TraefikBackendHealthCheckInterval:              "6",
+						label.TraefikBackendHealthCheckInterval:              "6s",
 						label.TraefikBackendHealthCheckHostname:              "foo.com",
 						label.TraefikBackendHealthCheckHeaders:               "Foo:bar || Bar:foo",
 						label.TraefikBackendLoadBalancerMethod:               "drr",
@@ -331,6 +334,7 @@ func TestSwarmBuildConfiguration(t *testing.T) {
 						label.TraefikBackendBufferingMaxRequestBodyBytes:     "10485760",
 						label.TraefikBackendBufferingMemRequestBodyBytes:     "2097152",
 						label.TraefikBackendBufferingRetryExpression:         "IsNetworkError() && Attempts() <= 2",
+						label.TraefikBackendResponseForwardingFlushInterval:  "10ms",
 
 						label.TraefikFrontendAuthBasicRemoveHeader:            "true",
 						label.TraefikFrontendAuthBasicUsers:                   "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/,test2:$apr1$d9hr9HBB$4HxwgUir3HP4EsggP/QNo0",
@@ -529,7 +533,7 @@ func TestSwarmBuildConfiguration(t *testing.T) {
 						Scheme:   "http",
 						Path:     "/health",
 						Port:     880,
-						Interval: "6",
+						Interval: "6s",
 						Hostname: "foo.com",
 						Headers: map[string]string{
 							"Foo": "bar",
@@ -543,6 +547,9 @@ func TestSwarmBuildConfiguration(t *testing.T) {
 						MemRequestBodyBytes:  2097152,
 						RetryExpression:      "IsNetworkError() && Attempts() <= 2",
 					},
+					ResponseForwarding: &types.ResponseForwarding{
+						FlushInterval: "10ms",
+					},
 				},
 			},
 			networks: map[string]*docker.NetworkResource{
@@ -557,18 +564,18 @@ func TestSwarmBuildConfiguration(t *testing.T) {
 		test := test
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
-			var dockerDataList []dockerData
-			for _, service := range test.services {
-				dData := parseService(service, test.networks)
-				dockerDataList = append(dockerDataList, dData)
-			}
-
 			provider := &Provider{
 				Domain:           "docker.localhost",
 				ExposedByDefault: true,
 				SwarmMode:        true,
 			}
 
+			var dockerDataList []dockerData
+			for _, service := range test.services {
+				dData := provider.parseService(service, test.networks)
+				dockerDataList = append(dockerDataList, dData)
+			}
+
 			actualConfig := provider.buildConfiguration(dockerDataList)
 			require.NotNil(t, actualConfig, "actualConfig")
 
@@ -578,6 +585,72 @@ func TestSwarmBuildConfiguration(t *testing.T) {
 	}
 }
 
+func TestSwarmBuildConfigurationScaling(t *testing.T) {
+	networks := map[string]*docker.NetworkResource{
+		"1": {Name: "foo"},
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		SwarmMode:        true,
+	}
+
+	buildConfiguration := func(replicas int) *types.Configuration {
+		app := provider.parseService(swarmService(
+			serviceName("app"),
+			serviceLabels(map[string]string{label.TraefikPort: "80"}),
+			withEndpointSpec(modeVIP),
+		), networks)
+		other := provider.parseService(swarmService(
+			serviceName("other"),
+			serviceLabels(map[string]string{label.TraefikPort: "80"}),
+			withEndpointSpec(modeVIP),
+		), networks)
+
+		var dockerDataList []dockerData
+		for slot := 1; slot <= replicas; slot++ {
+			task := swarmTask(fmt.Sprintf("app%d", slot),
+				taskSlot(slot),
+				taskNetworkAttachment("1", "foo", "overlay", []string{fmt.Sprintf("10.0.0.%d/24", slot)}))
+			dockerDataList = append(dockerDataList, parseTasks(task, app, networks, false))
+		}
+		task := swarmTask("other1",
+			taskSlot(1),
+			taskNetworkAttachment("1", "foo", "overlay", []string{"10.0.1.1/24"}))
+		dockerDataList = append(dockerDataList, parseTasks(task, other, networks, false))
+
+		config := provider.buildConfiguration(dockerDataList)
+		require.NotNil(t, config)
+		return config
+	}
+
+	frontendNames := func(config *types.Configuration) []string {
+		var names []string
+		for name := range config.Frontends {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	configTwo := buildConfiguration(2)
+	configFour := buildConfiguration(4)
+
+	require.Len(t, configTwo.Backends, 2)
+	require.Len(t, configFour.Backends, 2)
+	require.Contains(t, configTwo.Backends, "backend-app")
+	require.Contains(t, configFour.Backends, "backend-app")
+
+	assert.Len(t, configTwo.Backends["backend-app"].Servers, 2)
+	assert.Len(t, configFour.Backends["backend-app"].Servers, 4)
+	for serverName := range configFour.Backends["backend-app"].Servers {
+		assert.Regexp(t, `^server-app-[1-4]-`, serverName)
+	}
+
+	assert.Equal(t, frontendNames(configTwo), frontendNames(configFour))
+}
+
 func TestSwarmTraefikFilter(t *testing.T) {
 	testCases := []struct {
 		service  swarm.Service
@@ -704,7 +777,7 @@ func TestSwarmTraefikFilter(t *testing.T) {
 		t.Run(strconv.Itoa(serviceID), func(t *testing.T) {
 			t.Parallel()
 
-			dData := parseService(test.service, test.networks)
+			dData := test.provider.parseService(test.service, test.networks)
 
 			actual := test.provider.containerFilter(dData)
 			if actual != test.expected {
@@ -763,15 +836,15 @@ func TestSwarmGetFrontendName(t *testing.T) {
 		t.Run(strconv.Itoa(serviceID), func(t *testing.T) {
 			t.Parallel()
 
-			dData := parseService(test.service, test.networks)
-			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
-			dData.SegmentLabels = segmentProperties[""]
-
 			provider := &Provider{
 				Domain:    "docker.localhost",
 				SwarmMode: true,
 			}
 
+			dData := provider.parseService(test.service, test.networks)
+			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
+			dData.SegmentLabels = segmentProperties[""]
+
 			actual := provider.getFrontendName(dData, 0)
 			assert.Equal(t, test.expected, actual)
 		})
@@ -818,14 +891,14 @@ func TestSwarmGetFrontendRule(t *testing.T) {
 		t.Run(strconv.Itoa(serviceID), func(t *testing.T) {
 			t.Parallel()
 
-			dData := parseService(test.service, test.networks)
-			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
-
 			provider := &Provider{
 				Domain:    "docker.localhost",
 				SwarmMode: true,
 			}
 
+			dData := provider.parseService(test.service, test.networks)
+			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
+
 			actual := provider.getFrontendRule(dData, segmentProperties[""])
 			assert.Equal(t, test.expected, actual)
 		})
@@ -862,7 +935,11 @@ func TestSwarmGetBackendName(t *testing.T) {
 		t.Run(strconv.Itoa(serviceID), func(t *testing.T) {
 			t.Parallel()
 
-			dData := parseService(test.service, test.networks)
+			provider := &Provider{
+				SwarmMode: true,
+			}
+
+			dData := provider.parseService(test.service, test.networks)
 			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
 			dData.SegmentLabels = segmentProperties[""]
 
@@ -916,6 +993,149 @@ func TestSwarmGetIPAddress(t *testing.T) {
 				},
 			},
 		},
+		{
+			service: swarmService(
+				serviceLabels(map[string]string{
+					labelDockerNetwork: "barnet",
+				}),
+				withEndpointSpec(modeVIP),
+				withEndpoint(
+					virtualIP("net1", "10.11.12.13/24"),
+					virtualIP("net2", "10.11.12.99/24"),
+				),
+			),
+			expected: "10.11.12.13",
+			networks: map[string]*docker.NetworkResource{
+				"net1": {
+					Name: "barnet",
+				},
+				"net2": {
+					Name: "barnet",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				serviceLabels(map[string]string{
+					labelDockerNetwork: "net2",
+				}),
+				withEndpointSpec(modeVIP),
+				withEndpoint(
+					virtualIP("net1", "10.11.12.13/24"),
+					virtualIP("net2", "10.11.12.99/24"),
+				),
+			),
+			expected: "10.11.12.99",
+			networks: map[string]*docker.NetworkResource{
+				"net1": {
+					Name: "barnet",
+				},
+				"net2": {
+					Name: "barnet",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				serviceLabels(map[string]string{
+					labelDockerNetwork: "barnet@net2",
+				}),
+				withEndpointSpec(modeVIP),
+				withEndpoint(
+					virtualIP("net1", "10.11.12.13/24"),
+					virtualIP("net2", "10.11.12.99/24"),
+				),
+			),
+			expected: "10.11.12.99",
+			networks: map[string]*docker.NetworkResource{
+				"net1": {
+					Name: "barnet",
+				},
+				"net2": {
+					Name: "barnet",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				serviceLabels(map[string]string{
+					labelDockerNetwork: "other@net2",
+				}),
+				withEndpointSpec(modeVIP),
+				withEndpoint(
+					virtualIP("net1", "10.11.12.13/24"),
+					virtualIP("net2", "10.11.12.99/24"),
+				),
+			),
+			expected: "10.11.12.99",
+			networks: map[string]*docker.NetworkResource{
+				"net1": {
+					Name: "barnet",
+				},
+				"net2": {
+					Name: "barnet",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				withEndpointSpec(modeVIP),
+				withEndpoint(
+					virtualIP("ingress-id", "10.255.0.5/16"),
+					virtualIP("app-id", "10.0.1.5/24"),
+				),
+			),
+			expected: "10.0.1.5",
+			networks: map[string]*docker.NetworkResource{
+				"ingress-id": {
+					Name:    "ingress",
+					Ingress: true,
+				},
+				"app-id": {
+					Name: "webnet",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				withEndpointSpec(modeVIP),
+				withEndpoint(
+					virtualIP("ingress-id", "10.255.0.5/16"),
+					virtualIP("app-id", "10.0.1.5/24"),
+				),
+			),
+			expected: "10.0.1.5",
+			networks: map[string]*docker.NetworkResource{
+				"ingress-id": {
+					Name: "ingress",
+				},
+				"app-id": {
+					Name: "webnet",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				serviceLabels(map[string]string{
+					labelDockerNetwork: "ingress",
+				}),
+				withEndpointSpec(modeVIP),
+				withEndpoint(
+					virtualIP("ingress-id", "10.255.0.5/16"),
+					virtualIP("app-id", "10.0.1.5/24"),
+				),
+			),
+			expected: "10.255.0.5",
+			networks: map[string]*docker.NetworkResource{
+				"ingress-id": {
+					Name:    "ingress",
+					Ingress: true,
+				},
+				"app-id": {
+					Name: "webnet",
+				},
+			},
+		},
 	}
 
 	for serviceID, test := range testCases {
@@ -927,7 +1147,7 @@ func TestSwarmGetIPAddress(t *testing.T) {
 				SwarmMode: true,
 			}
 
-			dData := parseService(test.service, test.networks)
+			dData := provider.parseService(test.service, test.networks)
 			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
 			dData.SegmentLabels = segmentProperties[""]
 
@@ -937,6 +1157,55 @@ func TestSwarmGetIPAddress(t *testing.T) {
 	}
 }
 
+func TestSwarmUseServiceDNS(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		useServiceDNS bool
+		expected      string
+	}{
+		{
+			desc:     "virtual IP",
+			expected: "http://10.11.12.13:8080",
+		},
+		{
+			desc:          "service DNS name",
+			useServiceDNS: true,
+			expected:      "http://web:8080",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				SwarmMode:     true,
+				UseServiceDNS: test.useServiceDNS,
+			}
+
+			dData := provider.parseService(swarmService(
+				serviceName("web"),
+				serviceLabels(map[string]string{
+					label.TraefikPort:             "8080",
+					labelBackendLoadBalancerSwarm: "true",
+				}),
+				withEndpointSpec(modeVIP),
+				withEndpoint(virtualIP("1", "10.11.12.13/24")),
+			), map[string]*docker.NetworkResource{
+				"1": {Name: "foonet"},
+			})
+			dData.SegmentLabels = label.ExtractTraefikLabels(dData.Labels)[""]
+
+			var actual []string
+			for _, server := range provider.getServers([]dockerData{dData}) {
+				actual = append(actual, server.URL)
+			}
+			assert.Equal(t, []string{test.expected}, actual)
+		})
+	}
+}
+
 func TestSwarmGetPort(t *testing.T) {
 	testCases := []struct {
 		service  swarm.Service
@@ -960,7 +1229,11 @@ func TestSwarmGetPort(t *testing.T) {
 		t.Run(strconv.Itoa(serviceID), func(t *testing.T) {
 			t.Parallel()
 
-			dData := parseService(test.service, test.networks)
+			provider := &Provider{
+				SwarmMode: true,
+			}
+
+			dData := provider.parseService(test.service, test.networks)
 			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
 			dData.SegmentLabels = segmentProperties[""]
 
@@ -969,3 +1242,123 @@ func TestSwarmGetPort(t *testing.T) {
 		})
 	}
 }
+
+func TestSwarmWeightByResources(t *testing.T) {
+	networks := map[string]*docker.NetworkResource{
+		"1": {Name: "foo"},
+	}
+
+	mixed := []func(*swarm.Task){
+		taskReservations(1e9, 0),
+		taskReservations(5e8, 0),
+		taskReservations(0, 512<<20),
+		taskReservations(0, 0),
+	}
+
+	testCases := []struct {
+		desc              string
+		weightByResources bool
+		labels            map[string]string
+		reservations      []func(*swarm.Task)
+		expected          map[string]int
+	}{
+		{
+			desc:         "disabled",
+			reservations: mixed,
+			expected: map[string]int{
+				"10.0.0.1": 1,
+				"10.0.0.2": 1,
+				"10.0.0.3": 1,
+				"10.0.0.4": 1,
+			},
+		},
+		{
+			desc:              "enabled with CPU reservations",
+			weightByResources: true,
+			reservations: []func(*swarm.Task){
+				taskReservations(1e9, 256<<20),
+				taskReservations(5e8, 512<<20),
+			},
+			expected: map[string]int{
+				"10.0.0.1": 100,
+				"10.0.0.2": 50,
+			},
+		},
+		{
+			desc:              "enabled with memory reservations",
+			weightByResources: true,
+			reservations: []func(*swarm.Task){
+				taskReservations(0, 256<<20),
+				taskReservations(0, 512<<20),
+				taskReservations(0, 0),
+			},
+			expected: map[string]int{
+				"10.0.0.1": 256,
+				"10.0.0.2": 512,
+				"10.0.0.3": 1,
+			},
+		},
+		{
+			desc:              "enabled with mixed reservations weighted by CPU",
+			weightByResources: true,
+			reservations:      mixed,
+			expected: map[string]int{
+				"10.0.0.1": 100,
+				"10.0.0.2": 50,
+				"10.0.0.3": 1,
+				"10.0.0.4": 1,
+			},
+		},
+		{
+			desc:              "enabled with a weight label",
+			weightByResources: true,
+			labels:            map[string]string{label.TraefikWeight: "5"},
+			reservations:      mixed,
+			expected: map[string]int{
+				"10.0.0.1": 5,
+				"10.0.0.2": 5,
+				"10.0.0.3": 5,
+				"10.0.0.4": 5,
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				Domain:            "docker.localhost",
+				ExposedByDefault:  true,
+				SwarmMode:         true,
+				WeightByResources: test.weightByResources,
+			}
+
+			labels := map[string]string{label.TraefikPort: "80"}
+			for key, value := range test.labels {
+				labels[key] = value
+			}
+			app := provider.parseService(swarmService(serviceName("app"), serviceLabels(labels), withEndpointSpec(modeDNSSR)), networks)
+
+			var dockerDataList []dockerData
+			for slot, reservations := range test.reservations {
+				task := swarmTask(fmt.Sprintf("app%d", slot+1),
+					taskSlot(slot+1),
+					reservations,
+					taskNetworkAttachment("1", "foo", "overlay", []string{fmt.Sprintf("10.0.0.%d/24", slot+1)}))
+				dockerDataList = append(dockerDataList, parseTasks(task, app, networks, false))
+			}
+
+			config := provider.buildConfiguration(dockerDataList)
+			require.NotNil(t, config)
+			require.Contains(t, config.Backends, "backend-app")
+
+			weights := make(map[string]int)
+			for _, server := range config.Backends["backend-app"].Servers {
+				weights[strings.TrimSuffix(strings.TrimPrefix(server.URL, "http://"), ":80")] = server.Weight
+			}
+			assert.Equal(t, test.expected, weights)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+	"gopkg.in/fsnotify.v1"
+)
+
+// addLabelsFromDirectory merges the labels of each container with the ones of its file in the config directory,
+// named after the container (<name>.toml) or else after its service.
+// The labels set on the container take precedence, and a missing file is not an error.
+func (p *Provider) addLabelsFromDirectory(containers []dockerData) []dockerData {
+	if len(p.ConfigDirectory) == 0 {
+		return containers
+	}
+
+	var result []dockerData
+	for _, container := range containers {
+		fileLabels, err := p.readConfigDirectoryFile(container)
+		if err != nil {
+			log.Warnf("Failed to read the config file of the container %s in %s, using its labels only: %v", container.Name, p.ConfigDirectory, err)
+		}
+
+		if len(fileLabels) > 0 {
+			labels := p.normalizeLabels(fileLabels)
+			for key, value := range container.Labels {
+				labels[key] = value
+			}
+			container.Labels = labels
+		}
+
+		result = append(result, container)
+	}
+	return result
+}
+
+// readConfigDirectoryFile returns the labels of the first existing file named after the container, nil if none exists.
+func (p *Provider) readConfigDirectoryFile(container dockerData) (map[string]string, error) {
+	for _, name := range []string{container.Name, container.ServiceName} {
+		// The API prefixes the container names with a slash.
+		name = strings.TrimPrefix(name, "/")
+		if len(name) == 0 || strings.ContainsAny(name, `/\`) {
+			continue
+		}
+
+		labels, err := readTOMLLabels(filepath.Join(p.ConfigDirectory, name+".toml"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		return labels, err
+	}
+	return nil, nil
+}
+
+// readTOMLLabels reads a TOML file whose keys, once flattened, are labels: both `"traefik.frontend.rule" = "..."`
+// and `rule = "..."` in a `[traefik.frontend]` table give the traefik.frontend.rule label.
+func readTOMLLabels(path string) (map[string]string, error) {
+	var content map[string]interface{}
+	if _, err := toml.DecodeFile(path, &content); err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]string)
+	if err := flattenTOMLLabels("", content, labels); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return labels, nil
+}
+
+func flattenTOMLLabels(prefix string, content map[string]interface{}, labels map[string]string) error {
+	for key, value := range content {
+		name := prefix + key
+
+		switch typedValue := value.(type) {
+		case map[string]interface{}:
+			if err := flattenTOMLLabels(name+".", typedValue, labels); err != nil {
+				return err
+			}
+		case []interface{}:
+			// Lists of values are given as comma separated values, as in the labels.
+			var values []string
+			for _, item := range typedValue {
+				switch item.(type) {
+				case map[string]interface{}, []interface{}:
+					return fmt.Errorf("unsupported value for the key %s: only lists of simple values are allowed", name)
+				}
+				values = append(values, fmt.Sprint(item))
+			}
+			labels[name] = strings.Join(values, ",")
+		case []map[string]interface{}:
+			return fmt.Errorf("unsupported array of tables for the key %s", name)
+		default:
+			labels[name] = fmt.Sprint(typedValue)
+		}
+	}
+	return nil
+}
+
+// watchConfigDirectory calls refresh on each change in the config directory, until the context is done.
+func (p *Provider) watchConfigDirectory(ctx context.Context, pool *safe.Pool, refresh func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating the watcher of %s: %v", p.ConfigDirectory, err)
+	}
+
+	if err = watcher.Add(p.ConfigDirectory); err != nil {
+		watcher.Close()
+		return fmt.Errorf("error watching %s: %v", p.ConfigDirectory, err)
+	}
+
+	pool.Go(func(stop chan bool) {
+		defer watcher.Close()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if filepath.Ext(event.Name) == ".toml" {
+					log.Debugf("Config directory event received %s", event)
+					refresh()
+				}
+			case err := <-watcher.Errors:
+				log.Errorf("Config directory watcher error: %s", err)
+			}
+		}
+	})
+	return nil
+}
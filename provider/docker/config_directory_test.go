@@ -0,0 +1,86 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/traefik/provider/label"
+	"github.com/containous/traefik/types"
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerBuildConfigurationConfigDirectory(t *testing.T) {
+	directory, err := ioutil.TempDir("", "traefik-docker")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	content := `
+"traefik.frontend.passHostHeader" = true
+
+[traefik.frontend]
+rule = "Host:file.docker.localhost"
+entryPoints = ["http", "https"]
+
+[traefik.backend.loadbalancer]
+method = "drr"
+`
+	err = ioutil.WriteFile(filepath.Join(directory, "web.toml"), []byte(content), 0644)
+	require.NoError(t, err)
+
+	var containers []dockerData
+	for _, container := range []docker.ContainerJSON{
+		containerJSON(name("web"),
+			labels(map[string]string{
+				label.TraefikFrontendPassHostHeader: "false",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10"))),
+		containerJSON(name("other"),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11"))),
+	} {
+		containers = append(containers, parseContainer(container))
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		ConfigDirectory:  directory,
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	require.Contains(t, config.Frontends, "frontend-Host-file-docker-localhost-1")
+	frontend := config.Frontends["frontend-Host-file-docker-localhost-1"]
+	assert.Equal(t, []string{"http", "https"}, frontend.EntryPoints)
+	// The labels set on the container take precedence over the file.
+	assert.False(t, frontend.PassHostHeader)
+
+	require.Contains(t, config.Backends, "backend-web")
+	assert.Equal(t, &types.LoadBalancer{Method: "drr"}, config.Backends["backend-web"].LoadBalancer)
+
+	// The container without file keeps its default configuration.
+	assert.Contains(t, config.Frontends, "frontend-Host-other-docker-localhost-0")
+}
+
+func TestReadTOMLLabels(t *testing.T) {
+	directory, err := ioutil.TempDir("", "traefik-docker")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "invalid.toml")
+	err = ioutil.WriteFile(path, []byte("[[traefik.frontend]]\nrule = \"Host:foo\"\n"), 0644)
+	require.NoError(t, err)
+
+	_, err = readTOMLLabels(path)
+	assert.Error(t, err)
+
+	_, err = readTOMLLabels(filepath.Join(directory, "missing.toml"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,96 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/docker/docker/client"
+)
+
+const (
+	labelDockerConfigFile = "traefik.docker.configfile"
+	maxLabelsFileSize     = 1 << 20
+)
+
+// addLabelsFromFile returns the labels of the container merged with the ones read from the file named by its label.
+// The labels set on the container take precedence, and the file is ignored when it cannot be read.
+func (p *Provider) addLabelsFromFile(ctx context.Context, dockerClient client.ContainerAPIClient, containerID string, container dockerData) map[string]string {
+	path := container.Labels[labelDockerConfigFile]
+	if !p.ConfigFromFile || len(path) == 0 {
+		return container.Labels
+	}
+
+	fileLabels, err := p.readLabelsFile(ctx, dockerClient, containerID, path)
+	if err != nil {
+		log.Warnf("Failed to read the config file %s of the container %s, using its labels only: %v", path, container.Name, err)
+		return container.Labels
+	}
+
+	labels := p.normalizeLabels(fileLabels)
+	for key, value := range container.Labels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// readLabelsFile copies the file out of the container, without needing any shell or tool in its image.
+func (p *Provider) readLabelsFile(ctx context.Context, dockerClient client.ContainerAPIClient, containerID, path string) (map[string]string, error) {
+	copyCtx, cancel := p.apiContext(ctx)
+	defer cancel()
+
+	content, _, err := dockerClient.CopyFromContainer(copyCtx, containerID, path)
+	countAPICall(ctx, "CopyFromContainer")
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	// The API sends the file in a tar archive.
+	archive := tar.NewReader(content)
+	header, err := archive.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	if header.Size > maxLabelsFileSize {
+		return nil, fmt.Errorf("%s is too large (%d bytes, max %d)", path, header.Size, maxLabelsFileSize)
+	}
+
+	return parseLabelsFile(archive)
+}
+
+// parseLabelsFile parses the format of docker run --label-file: one key=value per line, # starting a comment.
+func parseLabelsFile(reader io.Reader) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(key) == 0 {
+			return nil, fmt.Errorf("invalid line %q: missing key", line)
+		}
+
+		var value string
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		labels[key] = value
+	}
+
+	return labels, scanner.Err()
+}
@@ -0,0 +1,103 @@
+package docker
+
+import (
+	"fmt"
+
+	"github.com/containous/traefik/provider"
+	"github.com/containous/traefik/provider/label"
+	"github.com/containous/traefik/types"
+)
+
+// ConstraintContainer holds the data of a container given to the ConstraintMatcher.
+type ConstraintContainer struct {
+	Name        string
+	ServiceName string
+	Image       string
+	Labels      map[string]string
+	Env         map[string]string // Only set when the parsing of the environment variables is enabled
+}
+
+// ConstraintMatcher tells which containers match the constraints.
+// A custom one can be set when Traefik is embedded, e.g. to match the containers on external metadata.
+type ConstraintMatcher interface {
+	// MatchConstraints returns false and the reason when the container must be ignored.
+	MatchConstraints(container ConstraintContainer) (bool, string)
+}
+
+// DefaultConstraintMatcher matches the tags of the traefik.tags label against the constraints,
+// and the environment variables against the env constraints when ParseEnv is set.
+type DefaultConstraintMatcher struct {
+	Constraints types.Constraints
+	ParseEnv    bool
+}
+
+// MatchConstraints implements ConstraintMatcher.
+func (m DefaultConstraintMatcher) MatchConstraints(container ConstraintContainer) (bool, string) {
+	constraintTags := label.SplitAndTrimString(container.Labels[label.TraefikTags], ",")
+	base := provider.BaseProvider{Constraints: m.Constraints}
+	if ok, failingConstraint := base.MatchConstraints(constraintTags); !ok {
+		if failingConstraint != nil {
+			return false, fmt.Sprintf("pruned by %q constraint", failingConstraint.String())
+		}
+		return false, "pruned by constraints"
+	}
+
+	if ok, failingConstraint := m.matchEnvConstraints(container.Env); !ok {
+		return false, fmt.Sprintf("pruned by %q constraint", failingConstraint.String())
+	}
+
+	return true, ""
+}
+
+// matchEnvConstraints checks the environment variables of the container against the env constraints.
+// The values of the environment variables must never be logged as they may contain secrets.
+func (m DefaultConstraintMatcher) matchEnvConstraints(env map[string]string) (bool, *types.Constraint) {
+	if !m.ParseEnv {
+		return true, nil
+	}
+
+	for _, constraint := range m.Constraints {
+		if !constraint.IsEnv() {
+			continue
+		}
+
+		if ok := constraint.MatchConstraintWithEnv(env); ok != constraint.MustMatch {
+			return false, constraint
+		}
+	}
+
+	return true, nil
+}
+
+// matchConstraints checks the container with the custom constraint matcher when set, else with the default one.
+func (p *Provider) matchConstraints(container dockerData) (bool, string) {
+	matcher := p.ConstraintMatcher
+	if matcher == nil {
+		matcher = DefaultConstraintMatcher{Constraints: p.Constraints, ParseEnv: p.ParseEnv}
+	}
+
+	return matcher.MatchConstraints(ConstraintContainer{
+		Name:        container.Name,
+		ServiceName: container.ServiceName,
+		Image:       container.Image,
+		Labels:      container.Labels,
+		Env:         container.Env,
+	})
+}
+
+// getConstraintsLabelFilters returns the docker label filters implied by the constraints, so that the daemon only lists
+// the containers and services which may match them: a tag constraint requiring a tag implies the tags label.
+// The listed ones are still checked against all the constraints, the ones which cannot be expressed as filters included.
+func (p *Provider) getConstraintsLabelFilters() []string {
+	// The labels read after the listing, or a custom matcher, may match the constraints without the tags label.
+	if p.ConstraintMatcher != nil || p.ConfigFromFile || len(p.ConfigDirectory) > 0 {
+		return nil
+	}
+
+	for _, constraint := range p.Constraints {
+		if constraint.Key == types.ConstraintKeyTag && constraint.MustMatch {
+			return []string{p.getRawLabelName(label.TraefikTags)}
+		}
+	}
+	return nil
+}
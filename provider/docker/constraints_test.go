@@ -0,0 +1,191 @@
+package docker
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/containous/traefik/provider/label"
+	"github.com/containous/traefik/types"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type excludeAllMatcher struct {
+	matched []string
+}
+
+func (m *excludeAllMatcher) MatchConstraints(container ConstraintContainer) (bool, string) {
+	m.matched = append(m.matched, container.Name)
+	return false, "excluded by the custom matcher"
+}
+
+func TestDockerBuildConfigurationConstraintMatcher(t *testing.T) {
+	containers := []dockerData{
+		parseContainer(containerJSON(name("web"),
+			labels(map[string]string{
+				label.TraefikTags: "public",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10")))),
+	}
+
+	constraint, err := types.NewConstraint("tag==public")
+	require.NoError(t, err)
+
+	matcher := &excludeAllMatcher{}
+	provider := &Provider{
+		Domain:            "docker.localhost",
+		ExposedByDefault:  true,
+		ConstraintMatcher: matcher,
+	}
+	provider.Constraints = types.Constraints{constraint}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	assert.Empty(t, config.Frontends)
+	assert.Empty(t, config.Backends)
+	assert.Equal(t, []string{"web"}, matcher.matched)
+
+	// The default matcher is used when none is set.
+	provider.ConstraintMatcher = nil
+	config = provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	assert.Len(t, config.Frontends, 1)
+	assert.Len(t, config.Backends, 1)
+}
+
+func TestDefaultConstraintMatcher(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		constraint string
+		parseEnv   bool
+		container  ConstraintContainer
+		expected   bool
+	}{
+		{
+			desc:       "matching tag",
+			constraint: "tag==public",
+			container:  ConstraintContainer{Labels: map[string]string{label.TraefikTags: "internal, public"}},
+			expected:   true,
+		},
+		{
+			desc:       "non matching tag",
+			constraint: "tag==public",
+			container:  ConstraintContainer{Labels: map[string]string{label.TraefikTags: "internal"}},
+			expected:   false,
+		},
+		{
+			desc:       "matching env",
+			constraint: "env.ENV==prod",
+			parseEnv:   true,
+			container:  ConstraintContainer{Env: map[string]string{"ENV": "prod"}},
+			expected:   true,
+		},
+		{
+			desc:       "non matching env",
+			constraint: "env.ENV==prod",
+			parseEnv:   true,
+			container:  ConstraintContainer{Env: map[string]string{"ENV": "staging"}},
+			expected:   false,
+		},
+		{
+			desc:       "env constraint without parsing the env",
+			constraint: "env.ENV==prod",
+			container:  ConstraintContainer{},
+			expected:   true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			constraint, err := types.NewConstraint(test.constraint)
+			require.NoError(t, err)
+
+			matcher := DefaultConstraintMatcher{Constraints: types.Constraints{constraint}, ParseEnv: test.parseEnv}
+
+			ok, reason := matcher.MatchConstraints(test.container)
+			assert.Equal(t, test.expected, ok)
+			if !test.expected {
+				assert.Contains(t, reason, test.constraint)
+			}
+		})
+	}
+}
+
+func TestListContainersConstraintsFilters(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+
+	testCases := []struct {
+		desc              string
+		constraint        string
+		labelPrefix       string
+		expectedInspected []string
+	}{
+		{
+			desc:              "tag required",
+			constraint:        "tag==public",
+			expectedInspected: []string{"public"},
+		},
+		{
+			desc:              "tag required with a label prefix",
+			constraint:        "tag==public",
+			labelPrefix:       "internal",
+			expectedInspected: []string{"internal"},
+		},
+		{
+			desc:              "tag excluded",
+			constraint:        "tag!=public",
+			expectedInspected: []string{"internal", "public", "untagged"},
+		},
+		{
+			desc:              "env constraint",
+			constraint:        "env.ENV==prod",
+			expectedInspected: []string{"internal", "public", "untagged"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dockerClient := &fakeContainersClient{
+				containers: map[string]dockertypes.ContainerJSON{
+					"public": containerJSON(name("/public"), running,
+						labels(map[string]string{label.TraefikTags: "public"}),
+						ports(nat.PortMap{"80/tcp": {}}),
+						withNetwork("testnet", ipv4("10.10.10.10"))),
+					"internal": containerJSON(name("/internal"), running,
+						labels(map[string]string{"internal.tags": "public"}),
+						ports(nat.PortMap{"80/tcp": {}}),
+						withNetwork("testnet", ipv4("10.10.10.11"))),
+					"untagged": containerJSON(name("/untagged"), running,
+						ports(nat.PortMap{"80/tcp": {}}),
+						withNetwork("testnet", ipv4("10.10.10.12"))),
+				},
+			}
+
+			constraint, err := types.NewConstraint(test.constraint)
+			require.NoError(t, err)
+
+			provider := &Provider{LabelPrefix: test.labelPrefix}
+			provider.Constraints = types.Constraints{constraint}
+
+			_, err = provider.listContainers(context.Background(), dockerClient)
+			require.NoError(t, err)
+
+			sort.Strings(dockerClient.inspected)
+			assert.Equal(t, test.expectedInspected, dockerClient.inspected)
+		})
+	}
+}
@@ -2,14 +2,25 @@ package docker
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/cenk/backoff"
+	"github.com/containous/flaeg/parse"
 	"github.com/containous/traefik/job"
 	"github.com/containous/traefik/log"
 	"github.com/containous/traefik/provider"
@@ -20,11 +31,13 @@ import (
 	dockercontainertypes "github.com/docker/docker/api/types/container"
 	eventtypes "github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
+	dockernetworktypes "github.com/docker/docker/api/types/network"
 	swarmtypes "github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	"github.com/docker/go-connections/sockets"
+	"github.com/mitchellh/hashstructure"
 )
 
 const (
@@ -32,37 +45,227 @@ const (
 	SwarmAPIVersion = "1.24"
 	// SwarmDefaultWatchTime is the duration of the interval when polling docker
 	SwarmDefaultWatchTime = 15 * time.Second
+	// SwarmEventsAPIVersion is the minimal version of the Docker API providing the swarm service events
+	SwarmEventsAPIVersion = "1.30"
 )
 
+// Health policies, telling which containers are routed according to their health status.
+const (
+	healthPolicyStrict   = "strict"   // Only the healthy containers, or the ones without health check
+	healthPolicyDegraded = "degraded" // The unhealthy containers too, with a reduced weight
+	healthPolicyIgnore   = "ignore"   // Whatever their health status
+)
+
+// degradedWeightRatio is how many times more traffic a healthy server gets than an unhealthy one of the same weight.
+const degradedWeightRatio = 10
+
 var _ provider.Provider = (*Provider)(nil)
 
 // Provider holds configurations of the provider.
 type Provider struct {
-	provider.BaseProvider `mapstructure:",squash" export:"true"`
-	Endpoint              string           `description:"Docker server endpoint. Can be a tcp or a unix socket endpoint"`
-	Domain                string           `description:"Default domain used"`
-	TLS                   *types.ClientTLS `description:"Enable Docker TLS support" export:"true"`
-	ExposedByDefault      bool             `description:"Expose containers by default" export:"true"`
-	UseBindPortIP         bool             `description:"Use the ip address from the bound port, rather than from the inner network" export:"true"`
-	SwarmMode             bool             `description:"Use Docker on Swarm Mode" export:"true"`
-	Network               string           `description:"Default Docker network used" export:"true"`
+	provider.BaseProvider  `mapstructure:",squash" export:"true"`
+	Endpoint               string           `description:"Docker server endpoint. Can be a tcp or a unix socket endpoint"`
+	Domain                 string           `description:"Default domain used"`
+	TLS                    *types.ClientTLS `description:"Enable Docker TLS support" export:"true"`
+	ExposedByDefault       bool             `description:"Expose containers by default" export:"true"`
+	UseBindPortIP          bool             `description:"Use the ip address from the bound port, rather than from the inner network" export:"true"`
+	BindHostIP             string           `description:"IP address used for the wildcard bindings (e.g. 0.0.0.0) of the ports with UseBindPortIP, which are ignored otherwise" export:"true"`
+	SwarmMode              bool             `description:"Use Docker on Swarm Mode" export:"true"`
+	SwarmClassic           bool             `description:"Use Docker Swarm classic (standalone swarm manager): route to the published ports on the nodes IPs" export:"true"`
+	Network                string           `description:"Default Docker network used" export:"true"`
+	MaxRestartCount        int              `description:"Ignore containers restarted more than this number of times (0 to disable)" export:"true"`
+	APITimeout             parse.Duration   `description:"Timeout for each call to the Docker API (0 to disable)" export:"true"`
+	FilterLabel            string           `description:"Only watch the events of the swarm services having this label (key or key=value)" export:"true"`
+	DefaultRule            string           `description:"Default frontend rule template used when a container has no frontend rule label" export:"true"`
+	StrictRules            bool             `description:"Ignore the frontends sharing the same rule instead of letting one of them win" export:"true"`
+	SwarmNetworkDrivers    NetworkDrivers   `description:"Additional network drivers accepted for swarm services (e.g. network plugins)" export:"true"`
+	SwarmLoadBalancer      bool             `description:"Use Swarm's inbuilt load balancer by default (can be overridden by label)" export:"true"`
+	ParseEnv               bool             `description:"Parse the containers environment variables to match the env constraints (e.g. env.ENV==prod)" export:"true"`
+	Proxy                  string           `description:"HTTP proxy used to reach a tcp endpoint (default to the HTTP_PROXY and HTTPS_PROXY environment variables)"`
+	GlobalServiceUseNodeIP bool             `description:"Route to the published ports on the nodes IPs for the global swarm services" export:"true"`
+	NeverExpose            NamePatterns     `description:"Never expose the containers whose name or image matches one of these patterns, whatever their labels" export:"true"`
+	ExcludeDrainedNodes    bool             `description:"Ignore the swarm tasks running on drained nodes" export:"true"`
+	SkipInspect            bool             `description:"Only inspect the containers when their data from the list is not enough (e.g. health check)" export:"true"`
+	IncludeJobs            bool             `description:"Expose the tasks of the swarm job services (replicated-job and global-job modes)" export:"true"`
+	IgnoreHealthEvents     bool             `description:"Do not refresh the configuration on the health events of the containers, only on start and die" export:"true"`
+	ConfigFromFile         bool             `description:"Read additional labels from the file named by the traefik.docker.configfile label, inside the containers" export:"true"`
+	DefaultProtocol        string           `description:"Default protocol of the backend servers, overridden by the traefik.protocol label (http or https)" export:"true"`
+	LabelPrefix            string           `description:"Prefix of the labels read by this instance, instead of traefik (e.g. traefik-internal)" export:"true"`
+	ConfigDirectory        string           `description:"Directory of TOML files holding additional labels, named after the containers (<name>.toml)" export:"true"`
+	HealthPolicy           string           `description:"Routing of the containers by health status: strict (only the healthy ones), degraded (the unhealthy ones too, with a reduced weight) or ignore" export:"true"`
+	DisablePassHostHeader  bool             `description:"Do not forward the client Host header to the backends by default (can be overridden by label)" export:"true"`
+	NameRewrite            *NameRewrite     `description:"Rewrite the service names of the containers used in the backend names" export:"true"`
+	ServiceLabelsOverride  bool             `description:"Give the precedence to the labels of the swarm services over the labels of their tasks" export:"true"`
+	WeightByResources      bool             `description:"Weight the swarm tasks by their CPU reservation when any task of the backend reserves CPU, or else their memory reservation (can be overridden by the traefik.weight label)" export:"true"`
+	SelfExclude            bool             `description:"Do not expose the container of Traefik itself, found by its ID or else by its image, unless the traefik.enable label is set to true" export:"true"`
+	ComposeServiceNames    bool             `description:"Name the backends of the compose containers after their compose service, without the project name" export:"true"`
+	EventsBufferSize       int              `description:"Number of docker events buffered while the configuration is refreshed, the next ones being collapsed into a single refresh" export:"true"`
+	InspectCache           bool             `description:"Reuse the inspection of the containers unchanged since the previous refresh (same state, creation date and status)" export:"true"`
+	ComposeProject         string           `description:"Only discover the containers of this compose project (com.docker.compose.project label)" export:"true"`
+	UseServiceDNS          bool             `description:"Route to the swarm services in VIP mode by their DNS name, resolved by the embedded DNS, instead of their VIP" export:"true"`
+	EventsStaleTimeout     parse.Duration   `description:"Open the events stream again, with a full refresh, when no event is received for this duration (0 to disable)" export:"true"`
+	UseNetworkAliases      bool             `description:"Add the network aliases of the containers, under the domain, to the hosts of their default frontend rule" export:"true"`
+	Middlewares            Middlewares      `description:"Middlewares usable as default middlewares, given by their options as in the typed labels (e.g. basicauth.users)" export:"true"`
+	DefaultMiddlewares     MiddlewareNames  `description:"Middlewares applied to all the frontends, unless the traefik.frontend.defaultMiddlewares label is set to false" export:"true"`
+	CoalesceSwarmEvents    bool             `description:"Refresh the configuration out of the swarm events stream, the events received during a refresh collapsing into one more refresh" export:"true"`
+	HostsFile              string           `description:"File listing the endpoints of docker hosts, one per line, whose containers are discovered instead of the ones of the Endpoint" export:"true"`
+	SortServersByOrder     bool             `description:"Sort the servers of a backend by their traefik.backend.order label, the servers without it coming last" export:"true"`
+	defaultRuleTemplate    *template.Template
+
+	// ClientFactory is used instead of the Endpoint to create the Docker client when set, e.g. when Traefik is embedded.
+	ClientFactory func() (client.APIClient, error) `json:"-"`
+
+	// DialContext is used to connect to the Docker daemon when set, e.g. over vsock, instead of the dialer given by the Endpoint scheme.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error) `json:"-"`
+
+	// RefreshHook is called with the stats of each listing of the containers or services when set, e.g. to export them as metrics.
+	RefreshHook func(RefreshStats) `json:"-"`
+
+	// ConstraintMatcher is used instead of the DefaultConstraintMatcher to filter the containers when set, e.g. when Traefik is embedded.
+	ConstraintMatcher ConstraintMatcher `json:"-"`
+
+	// EntryPoints and TLSEntryPoints are the entry points defined in the global configuration, used to check the ones requested by the containers.
+	EntryPoints    []string `json:"-"`
+	TLSEntryPoints []string `json:"-"`
+
+	stateMu sync.Mutex   // Serializes the updates of the state
+	state   atomic.Value // ConnectionState, read without lock
+
+	pushMu      sync.Mutex
+	lastHash    uint64                     // Hash of the last configuration pushed, 0 if none
+	paused      int32                      // Set while the discovery is paused, read atomically
+	pending     *types.Configuration       // Last configuration built while paused, pushed on resume without watch
+	pendingChan chan<- types.ConfigMessage // Channel of the pending configuration
+	resyncMu    sync.Mutex
+	resync      func() // Triggers a refresh of the configuration without blocking, set while watching
+
+	pingMu     sync.Mutex
+	pingClient client.APIClient // Client used by Ping only, nil until the first ping or after a failure
+
+	networkModeMu     sync.Mutex
+	networkModeClient client.APIClient // Client inspecting the containers joined in container network mode, negotiated once
+
+	inspected inspectCache
+
+	selfContainerID string // ID of the container running Traefik, or its short ID, empty if not running in a container
+
+	networksMu        sync.Mutex
+	lastSwarmNetworks map[string]*dockertypes.NetworkResource // Networks of the last successful listing, used when the listing fails
+
+	hostsMu           sync.Mutex
+	hostClients       map[string]client.APIClient                     // Clients of the hosts of the hosts file, by endpoint
+	hostInspected     map[string]*inspectCache                        // Inspect caches of the hosts of the hosts file, by endpoint
+	hostClientFactory func(endpoint string) (client.APIClient, error) // Used instead of createEndpointClient when set, e.g. in tests
 }
 
 // Init the provider
 func (p *Provider) Init(constraints types.Constraints) error {
-	return p.BaseProvider.Init(constraints)
+	if err := p.setupDefaultRuleTemplate(); err != nil {
+		return err
+	}
+
+	if len(p.DefaultProtocol) > 0 && p.DefaultProtocol != "http" && p.DefaultProtocol != "https" {
+		return fmt.Errorf("invalid default protocol %q: it must be http or https", p.DefaultProtocol)
+	}
+
+	if err := p.NameRewrite.compile(); err != nil {
+		return err
+	}
+
+	if err := p.checkDefaultMiddlewares(); err != nil {
+		return err
+	}
+
+	if p.SwarmMode && p.SwarmClassic {
+		return errors.New("swarmMode and swarmClassic cannot be both enabled")
+	}
+
+	if len(p.HostsFile) > 0 && (p.SwarmMode || p.SwarmClassic) {
+		return errors.New("hostsFile cannot be used with swarmMode or swarmClassic")
+	}
+
+	switch p.HealthPolicy {
+	case "", healthPolicyStrict, healthPolicyDegraded, healthPolicyIgnore:
+	default:
+		return fmt.Errorf("invalid health policy %q: it must be %s, %s or %s", p.HealthPolicy, healthPolicyStrict, healthPolicyDegraded, healthPolicyIgnore)
+	}
+
+	if err := p.BaseProvider.Init(constraints); err != nil {
+		return err
+	}
+
+	if !p.ParseEnv {
+		for _, constraint := range p.Constraints {
+			if constraint.IsEnv() {
+				log.Warnf("Constraint %q is ignored: the parsing of the environment variables is disabled", constraint.String())
+			}
+		}
+	}
+
+	if p.SelfExclude {
+		p.selfContainerID = getSelfContainerID()
+	}
+	return nil
+}
+
+func (p *Provider) setupDefaultRuleTemplate() error {
+	if len(p.DefaultRule) == 0 {
+		return nil
+	}
+
+	// Only a small set of string helpers is available, the arguments follow the sprig order.
+	funcMap := template.FuncMap{
+		"normalize":    provider.Normalize,
+		"getSubDomain": getSubDomain,
+		"lower":        strings.ToLower,
+		"upper":        strings.ToUpper,
+		"replace": func(old, new, s string) string {
+			return strings.Replace(s, old, new, -1)
+		},
+		"trimPrefix": func(prefix, s string) string {
+			return strings.TrimPrefix(s, prefix)
+		},
+		"split": func(sep, s string) []string {
+			return strings.Split(s, sep)
+		},
+	}
+
+	tmpl, err := template.New("docker default rule").Funcs(funcMap).Parse(p.DefaultRule)
+	if err != nil {
+		return fmt.Errorf("invalid default rule template %q: %v", p.DefaultRule, err)
+	}
+
+	p.defaultRuleTemplate = tmpl
+	return nil
 }
 
 // dockerData holds the need data to the Provider p
 type dockerData struct {
-	ServiceName     string
-	Name            string
-	Labels          map[string]string // List of labels set to container or service
-	NetworkSettings networkSettings
-	Health          string
-	Node            *dockertypes.ContainerNode
-	SegmentLabels   map[string]string
-	SegmentName     string
+	ID               string // ID of the container, empty for the swarm services and tasks
+	ServiceName      string
+	Name             string
+	Labels           map[string]string // List of labels set to container or service
+	NetworkSettings  networkSettings
+	Health           string
+	RestartCount     int
+	Node             *dockertypes.ContainerNode
+	SegmentLabels    map[string]string
+	SegmentName      string
+	SpecVersion      string                // Digest of the converged task spec, only set while a stop-first swarm service update is in progress
+	SwarmLB          bool                  // Use the swarm virtual IP instead of the tasks IPs
+	Env              map[string]string     // Only parsed when enabled, may contain secrets
+	NodeID           string                // Swarm node running the task
+	NodeRole         string                // Role of the swarm node running the task
+	NodeAvailability string                // Availability of the swarm node running the task
+	BindPortIP       bool                  // Use the ip address from the bound port, as with UseBindPortIP
+	Image            string                // Image of the container or of the swarm service
+	ExposedPorts     nat.PortSet           // Ports declared by the image or at run, published or not
+	RewrittenName    string                // Service name given by the ComposeServiceNames and NameRewrite options, used instead of the service name when set
+	Reservations     *swarmtypes.Resources // Resources reserved by the swarm task, nil if none is reserved
+	CanaryOf         string                // Service name of the primary service of a canary container
+	CanaryWeight     int                   // Percentage of the traffic of the primary backend sent to the canary containers, 0 if not a canary
+	Platform         string                // Platform of the container, e.g. windows, only set when inspected
+	Host             string                // Endpoint of the docker host running the container, only set with the HostsFile option
 }
 
 // NetworkSettings holds the networks data to the Provider p
@@ -79,31 +282,26 @@ type networkData struct {
 	Port     int
 	Protocol string
 	ID       string
+	Aliases  []string
+	Ingress  bool // Swarm ingress network, used by the routing mesh of the published ports
 }
 
-func (p *Provider) createClient() (client.APIClient, error) {
-	var httpClient *http.Client
-
-	if p.TLS != nil {
-		config, err := p.TLS.CreateTLSConfig()
-		if err != nil {
-			return nil, err
-		}
-		tr := &http.Transport{
-			TLSClientConfig: config,
-		}
+func (p *Provider) getClient() (client.APIClient, error) {
+	if p.ClientFactory != nil {
+		return p.ClientFactory()
+	}
+	return p.createClient()
+}
 
-		hostURL, err := client.ParseHostURL(p.Endpoint)
-		if err != nil {
-			return nil, err
-		}
-		if err := sockets.ConfigureTransport(tr, hostURL.Scheme, hostURL.Host); err != nil {
-			return nil, err
-		}
+func (p *Provider) createClient() (client.APIClient, error) {
+	return p.createEndpointClient(p.Endpoint)
+}
 
-		httpClient = &http.Client{
-			Transport: tr,
-		}
+// createEndpointClient creates a client of the daemon of the endpoint, with the options of the provider (e.g. TLS).
+func (p *Provider) createEndpointClient(endpoint string) (client.APIClient, error) {
+	httpClient, err := p.createHTTPClient(endpoint)
+	if err != nil {
+		return nil, err
 	}
 
 	httpHeaders := map[string]string{
@@ -117,54 +315,247 @@ func (p *Provider) createClient() (client.APIClient, error) {
 		apiVersion = DockerAPIVersion
 	}
 
-	return client.NewClient(p.Endpoint, apiVersion, httpClient, httpHeaders)
+	dockerClient, err := client.NewClient(endpoint, apiVersion, httpClient, httpHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	// The transport is only wrapped once the client is created: the docker library needs the *http.Transport to detect TLS.
+	if httpClient != nil {
+		transport := newRateLimitTransport(httpClient.Transport)
+		httpClient.Transport = transport
+		return &endpointClient{APIClient: dockerClient, transport: transport}, nil
+	}
+	return dockerClient, nil
+}
+
+// endpointClient is a docker client whose transport is wrapped, closing the idle connections of the wrapped transport.
+type endpointClient struct {
+	client.APIClient
+	transport *rateLimitTransport
+}
+
+func (c *endpointClient) Close() error {
+	c.transport.CloseIdleConnections()
+	return nil
+}
+
+// createHTTPClient returns nil when the default client of the docker library can be used.
+func (p *Provider) createHTTPClient(endpoint string) (*http.Client, error) {
+	hostURL, err := client.ParseHostURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	clientTLS := p.getTLS(hostURL, os.Getenv)
+	if clientTLS == nil && hostURL.Scheme != "tcp" && p.DialContext == nil {
+		return nil, nil
+	}
+
+	tr := &http.Transport{}
+
+	if clientTLS != nil {
+		config, err := clientTLS.CreateTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		tr.TLSClientConfig = config
+	}
+
+	if p.DialContext != nil {
+		// The custom dialer connects to the daemon whatever the scheme of the endpoint, e.g. vsock://3:2375.
+		tr.DialContext = p.DialContext
+	} else if err := sockets.ConfigureTransport(tr, hostURL.Scheme, hostURL.Host); err != nil {
+		return nil, err
+	}
+
+	// Unix sockets and named pipes are never proxied.
+	if hostURL.Scheme == "tcp" {
+		tr.Proxy = http.ProxyFromEnvironment
+
+		if len(p.Proxy) > 0 {
+			proxyURL, err := url.Parse(p.Proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy URL %q: %v", p.Proxy, err)
+			}
+			tr.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+
+	return &http.Client{
+		Transport: tr,
+	}, nil
+}
+
+// negotiateAPIVersion downgrades the API version of the client when the daemon is older,
+// the pinned API version is kept as a ceiling.
+func (p *Provider) negotiateAPIVersion(ctx context.Context, dockerClient client.APIClient) {
+	pingCtx, cancel := p.apiContext(ctx)
+	dockerClient.NegotiateAPIVersion(pingCtx)
+	cancel()
+
+	log.Debugf("Using docker API version %s", dockerClient.ClientVersion())
+}
+
+// isPodman tells if the daemon is Podman, through its Docker compatible API.
+// The API types vendored here do not decode the components of the version, so Podman is recognized by its own
+// version numbers (2.x to 5.x): the Docker Engine is versioned 1.x, then by year since 17.03.
+func isPodman(version dockertypes.Version) bool {
+	major, err := strconv.Atoi(strings.SplitN(version.Version, ".", 2)[0])
+	if err != nil {
+		return false
+	}
+	return major >= 2 && major < 17
+}
+
+// checkSwarmSupport returns an error if the daemon does not provide the swarm endpoints.
+func checkSwarmSupport(version dockertypes.Version) error {
+	if isPodman(version) {
+		return fmt.Errorf("swarm mode is not supported by Podman %s: disable the swarm mode to watch its containers", version.Version)
+	}
+	return nil
 }
 
 // Provide allows the docker provider to provide configurations to traefik
 // using the given configuration channel.
 func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *safe.Pool) error {
+	if len(p.HostsFile) > 0 {
+		safe.Go(func() {
+			p.provideHosts(configurationChan, pool)
+		})
+		return nil
+	}
+
 	// TODO register this routine in pool, and watch for stop channel
 	safe.Go(func() {
 		operation := func() error {
 			var err error
 
-			dockerClient, err := p.createClient()
+			dockerClient, err := p.getClient()
 			if err != nil {
 				log.Errorf("Failed to create a client for docker, error: %s", err)
 				return err
 			}
 
 			ctx := context.Background()
-			serverVersion, err := dockerClient.ServerVersion(ctx)
+			p.negotiateAPIVersion(ctx, dockerClient)
+
+			versionCtx, cancelVersion := p.apiContext(ctx)
+			serverVersion, err := dockerClient.ServerVersion(versionCtx)
+			cancelVersion()
 			if err != nil {
 				log.Errorf("Failed to retrieve information of the docker client and server host: %s", err)
 				return err
 			}
 			log.Debugf("Provider connection established with docker %s (API %s)", serverVersion.Version, serverVersion.APIVersion)
+
+			if p.SwarmMode {
+				if err = checkSwarmSupport(serverVersion); err != nil {
+					log.Error(err)
+					// Retrying would not help
+					return backoff.Permanent(err)
+				}
+			}
+
 			var dockerDataList []dockerData
 			if p.SwarmMode {
-				dockerDataList, err = listServices(ctx, dockerClient)
+				dockerDataList, err = p.listServices(ctx, dockerClient)
 				if err != nil {
 					log.Errorf("Failed to list services for docker swarm mode, error %s", err)
 					return err
 				}
 			} else {
-				dockerDataList, err = listContainers(ctx, dockerClient)
+				dockerDataList, err = p.listContainers(ctx, dockerClient)
 				if err != nil {
 					log.Errorf("Failed to list containers for docker, error %s", err)
 					return err
 				}
 			}
 
-			configuration := p.buildConfiguration(dockerDataList)
-			configurationChan <- types.ConfigMessage{
-				ProviderName:  "docker",
-				Configuration: configuration,
-			}
+			p.pushConfiguration(configurationChan, p.buildConfiguration(dockerDataList))
+			p.setRefreshed()
+
 			if p.Watch {
 				ctx, cancel := context.WithCancel(ctx)
+				defer cancel()
+
+				if len(p.ConfigDirectory) > 0 {
+					err = p.watchConfigDirectory(ctx, pool, func() {
+						var dockerDataList []dockerData
+						var err error
+						if p.SwarmMode {
+							dockerDataList, err = p.listServices(ctx, dockerClient)
+						} else {
+							dockerDataList, err = p.listContainers(ctx, dockerClient)
+						}
+						if err != nil {
+							log.Errorf("Failed to refresh the configuration on a change in %s, error %s", p.ConfigDirectory, err)
+							return
+						}
+						p.pushConfiguration(configurationChan, p.buildConfiguration(dockerDataList))
+						p.setRefreshed()
+					})
+					if err != nil {
+						log.Warnf("Changes in the config directory will only be applied on the next docker event: %v", err)
+					}
+				}
+
 				if p.SwarmMode {
 					errChan := make(chan error)
+
+					refreshServices := func() error {
+						services, err := p.listServices(ctx, dockerClient)
+						if err != nil {
+							return err
+						}
+						p.pushConfiguration(configurationChan, p.buildConfiguration(services))
+						p.setRefreshed()
+						return nil
+					}
+
+					p.setResync(func() {
+						safe.Go(func() {
+							if err := refreshServices(); err != nil {
+								log.Errorf("Failed to list services for docker on resume, error %s", err)
+							}
+						})
+					})
+					defer p.setResync(nil)
+
+					// Service events are only available since Docker 17.06, polling is kept as a fallback.
+					if versions.GreaterThanOrEqualTo(serverVersion.APIVersion, SwarmEventsAPIVersion) {
+						refresh := func() {
+							if err := refreshServices(); err != nil {
+								p.setDisconnected(err)
+								log.Errorf("Failed to list services for docker, error %s", err)
+							}
+						}
+
+						var eventsQueue *eventQueue
+						if p.CoalesceSwarmEvents {
+							// Each refresh lists all the services: the events of any service received meanwhile
+							// collapse into the next refresh, run once the current one is done.
+							eventsQueue = newEventQueue(p.EventsBufferSize)
+							pool.Go(func(stop chan bool) {
+								eventsQueue.run(ctx, refresh)
+							})
+						}
+
+						pool.Go(func(stop chan bool) {
+							err := p.listenSwarmEvents(ctx, dockerClient, func(m eventtypes.Message) {
+								log.Debugf("Provider event received %+v", m)
+								if eventsQueue != nil {
+									eventsQueue.push(m)
+									return
+								}
+								refresh()
+							})
+							if err != nil && ctx.Err() == nil {
+								log.Warnf("Swarm events stream closed, error %s. Relying on polling only.", err)
+							}
+						})
+					}
+
 					// TODO: This need to be change. Linked to Swarm events docker/docker#23827
 					ticker := time.NewTicker(SwarmDefaultWatchTime)
 					pool.Go(func(stop chan bool) {
@@ -172,19 +563,11 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 						for {
 							select {
 							case <-ticker.C:
-								services, err := listServices(ctx, dockerClient)
-								if err != nil {
+								if err := refreshServices(); err != nil {
 									log.Errorf("Failed to list services for docker, error %s", err)
 									errChan <- err
 									return
 								}
-								configuration := p.buildConfiguration(services)
-								if configuration != nil {
-									configurationChan <- types.ConfigMessage{
-										ProviderName:  "docker",
-										Configuration: configuration,
-									}
-								}
 
 							case <-stop:
 								ticker.Stop()
@@ -203,56 +586,41 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 						<-stop
 						cancel()
 					})
-					f := filters.NewArgs()
-					f.Add("type", "container")
-					options := dockertypes.EventsOptions{
-						Filters: f,
-					}
-
-					startStopHandle := func(m eventtypes.Message) {
-						log.Debugf("Provider event received %+v", m)
-						containers, err := listContainers(ctx, dockerClient)
+					startStopHandle := func() {
+						containers, err := p.listContainers(ctx, dockerClient)
 						if err != nil {
 							log.Errorf("Failed to list containers for docker, error %s", err)
 							// Call cancel to get out of the monitor
 							cancel()
 							return
 						}
-						configuration := p.buildConfiguration(containers)
-						if configuration != nil {
-							configurationChan <- types.ConfigMessage{
-								ProviderName:  "docker",
-								Configuration: configuration,
-							}
-						}
+						p.pushConfiguration(configurationChan, p.buildConfiguration(containers))
+						p.setRefreshed()
 					}
 
-					eventsc, errc := dockerClient.Events(ctx, options)
-					for {
-						select {
-						case event := <-eventsc:
-							if event.Action == "start" ||
-								event.Action == "die" ||
-								strings.HasPrefix(event.Action, "health_status") {
-								startStopHandle(event)
-							}
-						case err := <-errc:
-							if err == io.EOF {
-								log.Debug("Provider event stream closed")
-							}
+					// The refreshes run apart from the events loop, so that the stream is always read.
+					eventsQueue := newEventQueue(p.EventsBufferSize)
+					safe.Go(func() {
+						eventsQueue.run(ctx, startStopHandle)
+					})
 
-							return err
-						}
-					}
+					p.setResync(func() {
+						eventsQueue.push(eventtypes.Message{Action: "resume"})
+					})
+					defer p.setResync(nil)
+
+					return p.listenContainersEvents(ctx, dockerClient, eventsQueue)
 				}
 			}
 			return nil
 		}
 		notify := func(err error, time time.Duration) {
+			p.setDisconnected(err)
 			log.Errorf("Provider connection error %+v, retrying in %s", err, time)
 		}
 		err := backoff.RetryNotify(safe.OperationWithRecover(operation), job.NewBackOff(backoff.NewExponentialBackOff()), notify)
 		if err != nil {
+			p.setDisconnected(err)
 			log.Errorf("Cannot connect to docker server %+v", err)
 		}
 	})
@@ -260,47 +628,409 @@ func (p *Provider) Provide(configurationChan chan<- types.ConfigMessage, pool *s
 	return nil
 }
 
-func listContainers(ctx context.Context, dockerClient client.ContainerAPIClient) ([]dockerData, error) {
-	containerList, err := dockerClient.ContainerList(ctx, dockertypes.ContainerListOptions{})
+// pushConfiguration sends the configuration, unless it is identical to the last one sent.
+func (p *Provider) pushConfiguration(configurationChan chan<- types.ConfigMessage, configuration *types.Configuration) {
+	if configuration == nil {
+		return
+	}
+
+	// Also serializes the pushes, so that an older configuration is never sent after a newer one.
+	p.pushMu.Lock()
+	defer p.pushMu.Unlock()
+
+	if atomic.LoadInt32(&p.paused) == 1 {
+		log.Debug("Docker discovery paused, keeping the configuration until it is resumed")
+		p.pending = configuration
+		p.pendingChan = configurationChan
+		return
+	}
+
+	p.sendConfiguration(configurationChan, configuration)
+}
+
+// sendConfiguration sends the configuration unless it is unchanged, p.pushMu must be held.
+func (p *Provider) sendConfiguration(configurationChan chan<- types.ConfigMessage, configuration *types.Configuration) {
+	hash, err := configurationHash(configuration)
+	if err != nil {
+		log.Warnf("Failed to hash the configuration, sending it anyway: %v", err)
+	} else if hash == p.lastHash {
+		log.Debug("Skipping the unchanged configuration")
+		return
+	}
+
+	configurationChan <- types.ConfigMessage{
+		ProviderName:  "docker",
+		Configuration: configuration,
+	}
+	p.lastHash = hash
+}
+
+// Pause stops pushing the configurations, e.g. during a maintenance window: the last configuration pushed stays active.
+// The connection to docker and the watch of the events are kept, and the configurations are still built.
+func (p *Provider) Pause() {
+	if atomic.CompareAndSwapInt32(&p.paused, 0, 1) {
+		log.Info("Docker discovery paused")
+	}
+}
+
+// Resume pushes the configurations again, starting with a configuration rebuilt from a new listing of the containers
+// in watch mode, so that the changes missed while paused are applied. Otherwise, the last one built while paused is pushed, if any.
+func (p *Provider) Resume() {
+	p.pushMu.Lock()
+	if !atomic.CompareAndSwapInt32(&p.paused, 1, 0) {
+		p.pushMu.Unlock()
+		return
+	}
+	log.Info("Docker discovery resumed")
+
+	resync := p.getResync()
+	if resync == nil && p.pending != nil {
+		p.sendConfiguration(p.pendingChan, p.pending)
+	}
+	p.pending = nil
+	p.pendingChan = nil
+	p.pushMu.Unlock()
+
+	// The refresh pushes its configuration, so it is triggered once p.pushMu is released.
+	if resync != nil {
+		resync()
+	}
+}
+
+// setResync sets how to refresh the configuration on resume, nil when not watching.
+func (p *Provider) setResync(resync func()) {
+	p.resyncMu.Lock()
+	defer p.resyncMu.Unlock()
+	p.resync = resync
+}
+
+func (p *Provider) getResync() func() {
+	p.resyncMu.Lock()
+	defer p.resyncMu.Unlock()
+	return p.resync
+}
+
+// configurationHash returns a hash of the content of the configuration, whatever the order of its maps.
+func configurationHash(configuration *types.Configuration) (uint64, error) {
+	return hashstructure.Hash(configuration, nil)
+}
+
+// apiContext returns a context bounded by the API timeout, to be used for a single call to the Docker API.
+func (p *Provider) apiContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.APITimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Duration(p.APITimeout))
+}
+
+// listenContainersEvents queues the relevant container events, until the events stream is closed.
+// When no event is received for EventsStaleTimeout, the stream may have silently stalled, e.g. behind some proxies:
+// it is opened again and a full refresh is queued, as the events missed meanwhile are unknown.
+func (p *Provider) listenContainersEvents(ctx context.Context, dockerClient client.SystemAPIClient, eventsQueue *eventQueue) error {
+	options := dockertypes.EventsOptions{
+		Filters: p.containersEventsFilters(),
+	}
+	eventsFilter := newEventFilter(p.IgnoreHealthEvents, p.getHealthRouting)
+
+	// Each stream is opened with its own context, so that a stalled one can be closed.
+	var cancelEvents context.CancelFunc
+	defer func() { cancelEvents() }()
+	openEvents := func() (<-chan eventtypes.Message, <-chan error) {
+		var eventsCtx context.Context
+		eventsCtx, cancelEvents = context.WithCancel(ctx)
+		return dockerClient.Events(eventsCtx, options)
+	}
+	eventsc, errc := openEvents()
+
+	staleTimeout := time.Duration(p.EventsStaleTimeout)
+	var staleTimer *time.Timer
+	var stale <-chan time.Time
+	if staleTimeout > 0 {
+		staleTimer = time.NewTimer(staleTimeout)
+		defer staleTimer.Stop()
+		stale = staleTimer.C
+	}
+
+	for {
+		select {
+		case event := <-eventsc:
+			if staleTimer != nil {
+				resetTimer(staleTimer, staleTimeout)
+			}
+
+			if eventsFilter.accept(event) {
+				log.Debugf("Provider event received %+v", event)
+				eventsQueue.push(event)
+			}
+		case err := <-errc:
+			if err == io.EOF {
+				log.Debug("Provider event stream closed")
+			}
+
+			return err
+		case <-stale:
+			log.Warnf("No docker event received for %s, opening the events stream again", staleTimeout)
+			cancelEvents()
+			eventsc, errc = openEvents()
+
+			eventsQueue.push(eventtypes.Message{Action: "reconnect"})
+			staleTimer.Reset(staleTimeout)
+		}
+	}
+}
+
+// resetTimer resets a timer which may have fired without its value being received.
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// swarmEventsDedupWindow is how long an event is remembered, to drop its copy received from the other events stream.
+const swarmEventsDedupWindow = time.Minute
+
+// swarmEventKey identifies a swarm event received from both the events streams.
+type swarmEventKey struct {
+	id       string
+	action   string
+	timeNano int64
+}
+
+// listenSwarmEvents calls the handler for each relevant swarm service event, until the events stream is closed.
+// When a label filter is defined, only the events of the matching services are handled,
+// except the update and removal events which are always handled: the removed services may not carry their labels,
+// and an update may remove the label of a service which has to be dropped.
+func (p *Provider) listenSwarmEvents(ctx context.Context, dockerClient client.SystemAPIClient, handler func(eventtypes.Message)) error {
+	eventsc, errc := dockerClient.Events(ctx, dockertypes.EventsOptions{Filters: p.swarmEventsFilters()})
+
+	var changeEventsc <-chan eventtypes.Message
+	var changeErrc <-chan error
+	if len(p.FilterLabel) > 0 {
+		changeFilters := filters.NewArgs()
+		changeFilters.Add("scope", "swarm")
+		changeFilters.Add("type", "service")
+		changeFilters.Add("event", "update")
+		changeFilters.Add("event", "remove")
+		changeEventsc, changeErrc = dockerClient.Events(ctx, dockertypes.EventsOptions{Filters: changeFilters})
+	}
+
+	// The update and removal events of the labeled services are received from both the streams, but handled once.
+	seen := make(map[swarmEventKey]time.Time)
+	handle := func(event eventtypes.Message) {
+		if changeEventsc == nil || (event.Action != "update" && event.Action != "remove") {
+			handler(event)
+			return
+		}
+
+		now := time.Now()
+		for key, receivedAt := range seen {
+			if now.Sub(receivedAt) > swarmEventsDedupWindow {
+				delete(seen, key)
+			}
+		}
+
+		key := swarmEventKey{id: event.Actor.ID, action: event.Action, timeNano: event.TimeNano}
+		if _, ok := seen[key]; ok {
+			delete(seen, key)
+			return
+		}
+		seen[key] = now
+		handler(event)
+	}
+
+	for {
+		select {
+		case event := <-eventsc:
+			handle(event)
+		case event := <-changeEventsc:
+			handle(event)
+		case err := <-errc:
+			return err
+		case err := <-changeErrc:
+			return err
+		}
+	}
+}
+
+func (p *Provider) swarmEventsFilters() filters.Args {
+	f := filters.NewArgs()
+	f.Add("scope", "swarm")
+	f.Add("type", "service")
+	if len(p.FilterLabel) > 0 {
+		f.Add("label", p.FilterLabel)
+	}
+	return f
+}
+
+// servicesFilters restricts the services to the ones which may match the constraints.
+func (p *Provider) servicesFilters() filters.Args {
+	f := filters.NewArgs()
+	for _, labelFilter := range p.getConstraintsLabelFilters() {
+		f.Add("label", labelFilter)
+	}
+	return f
+}
+
+func (p *Provider) containersEventsFilters() filters.Args {
+	f := p.containersFilters()
+	f.Add("type", "container")
+	return f
+}
+
+// containersFilters restricts the containers to the ones of the compose project when the ComposeProject option is set,
+// and to the ones which may match the constraints.
+func (p *Provider) containersFilters() filters.Args {
+	f := filters.NewArgs()
+	if len(p.ComposeProject) > 0 {
+		f.Add("label", labelDockerComposeProject+"="+p.ComposeProject)
+	}
+	for _, labelFilter := range p.getConstraintsLabelFilters() {
+		f.Add("label", labelFilter)
+	}
+	return f
+}
+
+func (p *Provider) listContainers(ctx context.Context, dockerClient client.ContainerAPIClient) ([]dockerData, error) {
+	return p.listCachedContainers(ctx, dockerClient, &p.inspected)
+}
+
+// listCachedContainers lists the containers of a daemon, reusing the inspections of its cache when the InspectCache option is set.
+func (p *Provider) listCachedContainers(ctx context.Context, dockerClient client.ContainerAPIClient, inspected *inspectCache) ([]dockerData, error) {
+	ctx, recorder := withRefreshRecorder(ctx, "containers")
+	defer p.reportRefreshStats(recorder)
+
+	listCtx, cancel := p.apiContext(ctx)
+	containerList, err := dockerClient.ContainerList(listCtx, dockertypes.ContainerListOptions{Filters: p.containersFilters()})
+	countAPICall(ctx, "ContainerList")
+	cancel()
 	if err != nil {
 		return nil, err
 	}
 
+	if p.InspectCache {
+		inspected.prune(containerList)
+	}
+
 	var containersInspected []dockerData
 	// get inspect containers
 	for _, container := range containerList {
-		dData := inspectContainers(ctx, dockerClient, container.ID)
+		var dData dockerData
+		if p.needsInspect(container) {
+			dData = p.inspectContainer(ctx, dockerClient, inspected, container)
+		} else if container.State == "running" {
+			dData = parseContainerSummary(container)
+			dData.Labels = p.normalizeLabels(dData.Labels)
+		}
+
 		if len(dData.Name) > 0 {
+			dData.Labels = p.addLabelsFromFile(ctx, dockerClient, container.ID, dData)
 			containersInspected = append(containersInspected, dData)
 		}
 	}
 	return containersInspected, nil
 }
 
-func inspectContainers(ctx context.Context, dockerClient client.ContainerAPIClient, containerID string) dockerData {
+// needsInspect tells if the container data used by the provider is missing from the list response.
+func (p *Provider) needsInspect(container dockertypes.Container) bool {
+	// The node of a container running on a swarm classic cluster is only given by the inspection.
+	if !p.SkipInspect || p.MaxRestartCount > 0 || p.ParseEnv || p.SwarmClassic {
+		return true
+	}
+
+	// A container which is not running is ignored anyway.
+	if container.State != "running" {
+		return false
+	}
+
+	// The network aliases, and the exposed ports used without ports in the network settings, are only given by the inspection,
+	// as the platform: a container without address in the list response may be a Windows one, whose addresses are parsed differently.
+	if p.UseNetworkAliases || len(container.Ports) == 0 || !hasNetworkAddress(container) {
+		return true
+	}
+
+	// The list response only tells if there is a health check, e.g. "Up 2 minutes (healthy)", not its status.
+	return strings.Contains(container.Status, "health")
+}
+
+// hasNetworkAddress tells if the list response gives an address of the container on one of its networks.
+func hasNetworkAddress(container dockertypes.Container) bool {
+	if container.NetworkSettings == nil {
+		return false
+	}
+
+	for _, network := range container.NetworkSettings.Networks {
+		if network != nil && (len(network.IPAddress) > 0 || len(network.GlobalIPv6Address) > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// inspectContainer inspects the container, or reuses its previous inspection when the cache is enabled and the container is unchanged.
+func (p *Provider) inspectContainer(ctx context.Context, dockerClient client.ContainerAPIClient, inspected *inspectCache, container dockertypes.Container) dockerData {
+	if !p.InspectCache {
+		return p.inspectContainers(ctx, dockerClient, container.ID)
+	}
+
+	if dData, ok := inspected.get(container); ok {
+		return dData
+	}
+
+	dData := p.inspectContainers(ctx, dockerClient, container.ID)
+	// The failed inspections are retried on the next refresh.
+	if len(dData.Name) > 0 {
+		inspected.set(container, dData)
+	}
+	return dData
+}
+
+func (p *Provider) inspectContainers(ctx context.Context, dockerClient client.ContainerAPIClient, containerID string) dockerData {
 	dData := dockerData{}
-	containerInspected, err := dockerClient.ContainerInspect(ctx, containerID)
+	inspectCtx, cancel := p.apiContext(ctx)
+	containerInspected, err := dockerClient.ContainerInspect(inspectCtx, containerID)
+	countAPICall(ctx, "ContainerInspect")
+	cancel()
 	if err != nil {
 		log.Warnf("Failed to inspect container %s, error: %s", containerID, err)
 	} else {
 		// This condition is here to avoid to have empty IP https://github.com/containous/traefik/issues/2459
 		// We register only container which are running
-		if containerInspected.ContainerJSONBase != nil && containerInspected.ContainerJSONBase.State != nil && containerInspected.ContainerJSONBase.State.Running {
+		if containerInspected.ContainerJSONBase != nil && isRunning(containerInspected.ContainerJSONBase.State) {
 			dData = parseContainer(containerInspected)
+			dData.Labels = p.normalizeLabels(dData.Labels)
+			if p.ParseEnv && containerInspected.Config != nil {
+				dData.Env = parseEnv(containerInspected.Config.Env)
+			}
 		}
 	}
 	return dData
 }
 
+// isRunning tells if the inspected container is running. A container being removed, or dead,
+// may still be reported as running for a short while: it is excluded, its backend vanishing.
+func isRunning(state *dockertypes.ContainerState) bool {
+	if state == nil || !state.Running || state.Dead {
+		return false
+	}
+	return state.Status != "removing" && state.Status != "dead"
+}
+
 func parseContainer(container dockertypes.ContainerJSON) dockerData {
 	dData := dockerData{
 		NetworkSettings: networkSettings{},
 	}
 
 	if container.ContainerJSONBase != nil {
+		dData.ID = container.ContainerJSONBase.ID
 		dData.Name = container.ContainerJSONBase.Name
 		dData.ServiceName = dData.Name // Default ServiceName to be the container's Name.
 		dData.Node = container.ContainerJSONBase.Node
+		dData.RestartCount = container.ContainerJSONBase.RestartCount
+		dData.Platform = container.ContainerJSONBase.Platform
 
 		if container.ContainerJSONBase.HostConfig != nil {
 			dData.NetworkSettings.NetworkMode = container.ContainerJSONBase.HostConfig.NetworkMode
@@ -311,93 +1041,337 @@ func parseContainer(container dockertypes.ContainerJSON) dockerData {
 		}
 	}
 
-	if container.Config != nil && container.Config.Labels != nil {
+	if container.Config != nil {
 		dData.Labels = container.Config.Labels
+		dData.Image = container.Config.Image
+		dData.ExposedPorts = container.Config.ExposedPorts
 	}
 
 	if container.NetworkSettings != nil {
 		if container.NetworkSettings.Ports != nil {
 			dData.NetworkSettings.Ports = container.NetworkSettings.Ports
 		}
-		if container.NetworkSettings.Networks != nil {
-			dData.NetworkSettings.Networks = make(map[string]*networkData)
-			for name, containerNetwork := range container.NetworkSettings.Networks {
-				dData.NetworkSettings.Networks[name] = &networkData{
-					ID:   containerNetwork.NetworkID,
-					Name: name,
-					Addr: containerNetwork.IPAddress,
-				}
+		dData.NetworkSettings.Networks = parseNetworks(container.NetworkSettings.Networks)
+		if isWindowsContainer(dData) {
+			dData.NetworkSettings.Networks = parseWindowsNetworks(container, dData.NetworkSettings.Networks)
+		}
+	}
+	return dData
+}
+
+// parseContainerSummary builds the container data from the list response, without inspecting the container.
+func parseContainerSummary(container dockertypes.Container) dockerData {
+	dData := dockerData{
+		ID:     container.ID,
+		Labels: container.Labels,
+		Image:  container.Image,
+		NetworkSettings: networkSettings{
+			NetworkMode: dockercontainertypes.NetworkMode(container.HostConfig.NetworkMode),
+		},
+	}
+
+	if len(container.Names) > 0 {
+		dData.Name = container.Names[0]
+		dData.ServiceName = dData.Name // Default ServiceName to be the container's Name.
+	}
+
+	if len(container.Ports) > 0 {
+		dData.NetworkSettings.Ports = nat.PortMap{}
+		for _, port := range container.Ports {
+			natPort, err := nat.NewPort(port.Type, strconv.Itoa(int(port.PrivatePort)))
+			if err != nil {
+				log.Warnf("Invalid port %d/%s for the container %s: %v", port.PrivatePort, port.Type, dData.Name, err)
+				continue
 			}
+
+			bindings := dData.NetworkSettings.Ports[natPort]
+			if port.PublicPort > 0 {
+				bindings = append(bindings, nat.PortBinding{
+					HostIP:   port.IP,
+					HostPort: strconv.Itoa(int(port.PublicPort)),
+				})
+			}
+			dData.NetworkSettings.Ports[natPort] = bindings
 		}
 	}
+
+	if container.NetworkSettings != nil {
+		dData.NetworkSettings.Networks = parseNetworks(container.NetworkSettings.Networks)
+	}
 	return dData
 }
 
-func listServices(ctx context.Context, dockerClient client.APIClient) ([]dockerData, error) {
-	serviceList, err := dockerClient.ServiceList(ctx, dockertypes.ServiceListOptions{})
-	if err != nil {
-		return nil, err
+func parseNetworks(networks map[string]*dockernetworktypes.EndpointSettings) map[string]*networkData {
+	if networks == nil {
+		return nil
+	}
+
+	networksData := make(map[string]*networkData)
+	for name, containerNetwork := range networks {
+		addr := containerNetwork.IPAddress
+		if len(addr) == 0 {
+			// IPv6 only network
+			addr = containerNetwork.GlobalIPv6Address
+		}
+
+		networksData[name] = &networkData{
+			ID:      containerNetwork.NetworkID,
+			Name:    name,
+			Addr:    addr,
+			Aliases: containerNetwork.Aliases,
+		}
+	}
+	return networksData
+}
+
+// addNetwork adds a network keyed by its name, or by its ID when another network with the same name is already added.
+// The names of the networks are not unique, e.g. between a local and a swarm scoped network.
+func addNetwork(networks map[string]*networkData, network *networkData) {
+	if existing, ok := networks[network.Name]; ok && existing.ID != network.ID {
+		networks[network.ID] = network
+		return
+	}
+	networks[network.Name] = network
+}
+
+// parseEnv transforms the KEY=value environment variables of a container into a map.
+func parseEnv(env []string) map[string]string {
+	envMap := make(map[string]string)
+	for _, variable := range env {
+		kv := strings.SplitN(variable, "=", 2)
+		if len(kv) == 2 {
+			envMap[kv[0]] = kv[1]
+		} else {
+			envMap[kv[0]] = ""
+		}
 	}
+	return envMap
+}
 
-	serverVersion, err := dockerClient.ServerVersion(ctx)
+func (p *Provider) listServices(ctx context.Context, dockerClient client.APIClient) ([]dockerData, error) {
+	ctx, recorder := withRefreshRecorder(ctx, "services")
+	defer p.reportRefreshStats(recorder)
+
+	versionCtx, cancel := p.apiContext(ctx)
+	serverVersion, err := dockerClient.ServerVersion(versionCtx)
+	countAPICall(ctx, "ServerVersion")
+	cancel()
 	if err != nil {
 		return nil, err
 	}
 
-	networkListArgs := filters.NewArgs()
-	// https://docs.docker.com/engine/api/v1.29/#tag/Network (Docker 17.06)
-	if versions.GreaterThanOrEqualTo(serverVersion.APIVersion, "1.29") {
-		networkListArgs.Add("scope", "swarm")
-	} else {
-		networkListArgs.Add("driver", "overlay")
+	if err = checkSwarmSupport(serverVersion); err != nil {
+		return nil, err
 	}
 
-	networkList, err := dockerClient.NetworkList(ctx, dockertypes.NetworkListOptions{Filters: networkListArgs})
+	listCtx, cancel := p.apiContext(ctx)
+	serviceList, err := dockerClient.ServiceList(listCtx, dockertypes.ServiceListOptions{Filters: p.servicesFilters()})
+	countAPICall(ctx, "ServiceList")
+	cancel()
 	if err != nil {
-		log.Debugf("Failed to network inspect on client for docker, error: %s", err)
 		return nil, err
 	}
 
-	networkMap := make(map[string]*dockertypes.NetworkResource)
-	for _, network := range networkList {
-		networkToAdd := network
-		networkMap[network.ID] = &networkToAdd
+	networkMap, err := p.listSwarmNetworks(ctx, dockerClient, serverVersion.APIVersion)
+	if err != nil {
+		// The tasks can still be resolved from the networks they are attached to, the services are not dropped.
+		networkMap = p.getLastSwarmNetworks()
+		if networkMap != nil {
+			log.Warnf("Failed to list the swarm networks, using the previous ones: %v", err)
+		} else {
+			log.Warnf("Failed to list the swarm networks, using the networks attached to the tasks: %v", err)
+		}
+	} else {
+		p.setLastSwarmNetworks(networkMap)
 	}
 
 	var dockerDataList []dockerData
 	var dockerDataListTasks []dockerData
+	var nodes map[string]swarmtypes.Node
+	var nodesListed bool
 
 	for _, service := range serviceList {
-		dData := parseService(service, networkMap)
+		if isJobService(service) && !p.IncludeJobs {
+			log.Debugf("Filtering job service %s", service.Spec.Annotations.Name)
+			continue
+		}
+
+		dData := p.parseService(service, networkMap)
+		isGlobalSvc := service.Spec.Mode.Global != nil
+		minReplicas := getMinReplicas(dData)
+
+		if dData.SwarmLB {
+			if len(dData.NetworkSettings.Networks) == 0 {
+				continue
+			}
 
-		if isBackendLBSwarm(dData) {
-			if len(dData.NetworkSettings.Networks) > 0 {
-				dockerDataList = append(dockerDataList, dData)
+			// The tasks are only listed to be counted, the service being routed by its VIP.
+			if minReplicas > 0 {
+				dockerDataListTasks, err = p.listTasks(ctx, dockerClient, service.ID, dData, networkMap, isGlobalSvc)
+				if err != nil {
+					log.Warn(err)
+					continue
+				}
+				if !hasMinReplicas(dData, len(dockerDataListTasks), minReplicas) {
+					continue
+				}
 			}
+
+			dockerDataList = append(dockerDataList, dData)
 		} else {
-			isGlobalSvc := service.Spec.Mode.Global != nil
-			dockerDataListTasks, err = listTasks(ctx, dockerClient, service.ID, dData, networkMap, isGlobalSvc)
+			dockerDataListTasks, err = p.listTasks(ctx, dockerClient, service.ID, dData, networkMap, isGlobalSvc)
+			if client.IsErrNotFound(err) {
+				// Removed since the services were listed, its remove event triggers another refresh.
+				log.Debugf("Service %s removed while listing its tasks", service.Spec.Annotations.Name)
+				continue
+			}
 			if err != nil {
 				log.Warn(err)
-			} else {
-				dockerDataList = append(dockerDataList, dockerDataListTasks...)
+				continue
 			}
+
+			if !hasMinReplicas(dData, len(dockerDataListTasks), minReplicas) {
+				continue
+			}
+
+			// The nodes are listed once per refresh, whatever the number of services, and only when needed.
+			if !nodesListed && p.needsNodes(ctx, isGlobalSvc) {
+				nodesListed = true
+				nodes, err = p.listNodes(ctx, dockerClient)
+				if err != nil {
+					log.Warnf("Failed to list the swarm nodes, the tasks are listed without the data of their node: %v", err)
+				}
+			}
+
+			dockerDataListTasks = p.setNodesData(dockerDataListTasks, nodes)
+
+			if isGlobalSvc && p.GlobalServiceUseNodeIP {
+				dockerDataListTasks = setNodeAddresses(dockerDataListTasks, service, nodes)
+			}
+
+			dockerDataList = append(dockerDataList, dockerDataListTasks...)
 		}
 	}
-	return dockerDataList, err
+	// The failures of a single service must not fail the whole refresh.
+	return dockerDataList, nil
 }
 
-func parseService(service swarmtypes.Service, networkMap map[string]*dockertypes.NetworkResource) dockerData {
+// getMinReplicas returns the number of running tasks required to route to a service, given by its traefik.minReplicas label,
+// or 0 without valid label.
+func getMinReplicas(dData dockerData) int {
+	value, ok := dData.Labels[labelMinReplicas]
+	if !ok {
+		return 0
+	}
+
+	minReplicas, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || minReplicas < 0 {
+		log.Warnf("Invalid value %q in label %s of service %s, ignoring it: it must be a positive integer", value, labelMinReplicas, dData.Name)
+		return 0
+	}
+	return minReplicas
+}
+
+// hasMinReplicas tells if the service has enough running tasks to be routed to, warning when it has not:
+// it is checked again on each refresh, as its replicas come up.
+func hasMinReplicas(dData dockerData, running int, minReplicas int) bool {
+	if running >= minReplicas {
+		return true
+	}
+
+	log.Warnf("Ignoring the service %s: %d running tasks out of the %d required by the label %s", dData.Name, running, minReplicas, labelMinReplicas)
+	return false
+}
+
+// isJobService returns true if the service runs in replicated-job or global-job mode.
+// The API types vendored here predate the job modes (API 1.41): a job service is decoded without any mode.
+func isJobService(service swarmtypes.Service) bool {
+	return service.Spec.Mode.Replicated == nil && service.Spec.Mode.Global == nil
+}
+
+func (p *Provider) getLastSwarmNetworks() map[string]*dockertypes.NetworkResource {
+	p.networksMu.Lock()
+	defer p.networksMu.Unlock()
+	return p.lastSwarmNetworks
+}
+
+func (p *Provider) setLastSwarmNetworks(networkMap map[string]*dockertypes.NetworkResource) {
+	p.networksMu.Lock()
+	defer p.networksMu.Unlock()
+	p.lastSwarmNetworks = networkMap
+}
+
+func (p *Provider) listSwarmNetworks(ctx context.Context, dockerClient client.NetworkAPIClient, apiVersion string) (map[string]*dockertypes.NetworkResource, error) {
+	networkListArgs := filters.NewArgs()
+	// https://docs.docker.com/engine/api/v1.29/#tag/Network (Docker 17.06)
+	if versions.GreaterThanOrEqualTo(apiVersion, "1.29") {
+		networkListArgs.Add("scope", "swarm")
+	} else {
+		networkListArgs.Add("driver", "overlay")
+	}
+
+	// Macvlan and ipvlan networks directly assign addresses to the tasks, they may not be swarm scoped.
+	// Networks provided by plugins may use other drivers than overlay.
+	driversListArgs := filters.NewArgs()
+	for _, driver := range append([]string{"macvlan", "ipvlan"}, p.SwarmNetworkDrivers...) {
+		driversListArgs.Add("driver", driver)
+	}
+
+	networkListsArgs := []filters.Args{networkListArgs, driversListArgs}
+
+	networkMap := make(map[string]*dockertypes.NetworkResource)
+	for _, listArgs := range networkListsArgs {
+		networkCtx, cancel := p.apiContext(ctx)
+		networkList, err := dockerClient.NetworkList(networkCtx, dockertypes.NetworkListOptions{Filters: listArgs})
+		countAPICall(ctx, "NetworkList")
+		cancel()
+		if err != nil {
+			log.Debugf("Failed to network inspect on client for docker, error: %s", err)
+			return nil, err
+		}
+
+		for _, network := range networkList {
+			if _, exists := networkMap[network.ID]; exists {
+				continue
+			}
+			log.Debugf("Considering network %s (driver: %s, scope: %s) for swarm services", network.Name, network.Driver, network.Scope)
+			networkToAdd := network
+			networkMap[network.ID] = &networkToAdd
+		}
+	}
+
+	return networkMap, nil
+}
+
+func (p *Provider) parseService(service swarmtypes.Service, networkMap map[string]*dockertypes.NetworkResource) dockerData {
 	dData := dockerData{
 		ServiceName:     service.Spec.Annotations.Name,
 		Name:            service.Spec.Annotations.Name,
-		Labels:          service.Spec.Annotations.Labels,
+		Labels:          p.normalizeLabels(service.Spec.Annotations.Labels),
 		NetworkSettings: networkSettings{},
 	}
+	dData.SwarmLB = p.isBackendLBSwarm(dData)
+
+	if service.Spec.TaskTemplate.ContainerSpec != nil {
+		dData.Image = service.Spec.TaskTemplate.ContainerSpec.Image
+		if p.ParseEnv {
+			dData.Env = parseEnv(service.Spec.TaskTemplate.ContainerSpec.Env)
+		}
+	}
+
+	if service.UpdateStatus != nil {
+		switch service.UpdateStatus.State {
+		case swarmtypes.UpdateStateUpdating:
+			dData.SpecVersion = getUpdateSpecVersion(service, service.Spec.UpdateConfig)
+		case swarmtypes.UpdateStateRollbackStarted:
+			dData.SpecVersion = getUpdateSpecVersion(service, service.Spec.RollbackConfig)
+		}
+	}
 
 	if service.Spec.EndpointSpec != nil {
 		if service.Spec.EndpointSpec.Mode == swarmtypes.ResolutionModeDNSRR {
-			if isBackendLBSwarm(dData) {
+			if dData.SwarmLB {
+				dData.SwarmLB = false
 				log.Warnf("Ignored %s endpoint-mode not supported, service name: %s. Fallback to Træfik load balancing", swarmtypes.ResolutionModeDNSRR, service.Spec.Annotations.Name)
 			}
 		} else if service.Spec.EndpointSpec.Mode == swarmtypes.ResolutionModeVIP {
@@ -407,12 +1381,19 @@ func parseService(service swarmtypes.Service, networkMap map[string]*dockertypes
 				if networkService != nil {
 					if len(virtualIP.Addr) > 0 {
 						ip, _, _ := net.ParseCIDR(virtualIP.Addr)
+						addr := ip.String()
+						if p.UseServiceDNS {
+							// The embedded DNS resolves the service name to its VIP, following its changes.
+							addr = service.Spec.Annotations.Name
+						}
+
 						network := &networkData{
-							Name: networkService.Name,
-							ID:   virtualIP.NetworkID,
-							Addr: ip.String(),
+							Name:    networkService.Name,
+							ID:      virtualIP.NetworkID,
+							Addr:    addr,
+							Ingress: isIngressNetwork(networkService.Name, networkService.Ingress),
 						}
-						dData.NetworkSettings.Networks[network.Name] = network
+						addNetwork(dData.NetworkSettings.Networks, network)
 					} else {
 						log.Debugf("No virtual IPs found in network %s", virtualIP.NetworkID)
 					}
@@ -425,30 +1406,234 @@ func parseService(service swarmtypes.Service, networkMap map[string]*dockertypes
 	return dData
 }
 
-func listTasks(ctx context.Context, dockerClient client.APIClient, serviceID string,
+func (p *Provider) listTasks(ctx context.Context, dockerClient client.APIClient, serviceID string,
 	serviceDockerData dockerData, networkMap map[string]*dockertypes.NetworkResource, isGlobalSvc bool) ([]dockerData, error) {
 	serviceIDFilter := filters.NewArgs()
 	serviceIDFilter.Add("service", serviceID)
 	serviceIDFilter.Add("desired-state", "running")
 
-	taskList, err := dockerClient.TaskList(ctx, dockertypes.TaskListOptions{Filters: serviceIDFilter})
+	taskCtx, cancel := p.apiContext(ctx)
+	taskList, err := dockerClient.TaskList(taskCtx, dockertypes.TaskListOptions{Filters: serviceIDFilter})
+	countAPICall(ctx, "TaskList")
+	cancel()
 	if err != nil {
 		return nil, err
 	}
 
-	var dockerDataList []dockerData
+	var runningTasks []swarmtypes.Task
 	for _, task := range taskList {
-		if task.Status.State != swarmtypes.TaskStateRunning {
+		// The current state of a task being shut down lags behind its desired state, e.g. during a scale down.
+		if task.Status.State != swarmtypes.TaskStateRunning || task.DesiredState != swarmtypes.TaskStateRunning {
 			continue
 		}
+		runningTasks = append(runningTasks, task)
+	}
+
+	if len(serviceDockerData.SpecVersion) > 0 {
+		runningTasks = filterConvergedTasks(runningTasks, serviceDockerData)
+	}
+
+	// The tasks of a global service routed by node IP may not be attached to any network.
+	useNodeIP := isGlobalSvc && p.GlobalServiceUseNodeIP
+
+	var dockerDataList []dockerData
+	for _, task := range runningTasks {
 		dData := parseTasks(task, serviceDockerData, networkMap, isGlobalSvc)
-		if len(dData.NetworkSettings.Networks) > 0 {
+		dData.Labels = p.mergeTaskLabels(serviceDockerData.Labels, task.Labels)
+		if len(dData.NetworkSettings.Networks) > 0 || useNodeIP {
 			dockerDataList = append(dockerDataList, dData)
 		}
 	}
 	return dockerDataList, err
 }
 
+// mergeTaskLabels merges the labels of the task with the ones of its service,
+// the task labels taking precedence unless the ServiceLabelsOverride option is set.
+func (p *Provider) mergeTaskLabels(serviceLabels map[string]string, taskLabels map[string]string) map[string]string {
+	if len(taskLabels) == 0 {
+		return serviceLabels
+	}
+
+	first, second := serviceLabels, p.normalizeLabels(taskLabels)
+	if p.ServiceLabelsOverride {
+		first, second = second, first
+	}
+
+	merged := make(map[string]string, len(first)+len(second))
+	for key, value := range first {
+		merged[key] = value
+	}
+	for key, value := range second {
+		merged[key] = value
+	}
+	return merged
+}
+
+// needsNodes tells whether the nodes are needed for the tasks of a service: by the options relying on them,
+// or by a snapshot showing the node of each task.
+func (p *Provider) needsNodes(ctx context.Context, isGlobalSvc bool) bool {
+	withNodes, _ := ctx.Value(nodesDataKey{}).(bool)
+	return withNodes || p.ExcludeDrainedNodes || (isGlobalSvc && p.GlobalServiceUseNodeIP)
+}
+
+func (p *Provider) listNodes(ctx context.Context, dockerClient client.NodeAPIClient) (map[string]swarmtypes.Node, error) {
+	nodeCtx, cancel := p.apiContext(ctx)
+	nodeList, err := dockerClient.NodeList(nodeCtx, dockertypes.NodeListOptions{})
+	countAPICall(ctx, "NodeList")
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]swarmtypes.Node)
+	for _, node := range nodeList {
+		nodes[node.ID] = node
+	}
+	return nodes, nil
+}
+
+// setNodesData adds the role and the availability of their node to the tasks,
+// and removes the tasks running on drained nodes when enabled.
+func (p *Provider) setNodesData(tasks []dockerData, nodes map[string]swarmtypes.Node) []dockerData {
+	var dockerDataList []dockerData
+	for _, dData := range tasks {
+		if node, ok := nodes[dData.NodeID]; ok {
+			dData.NodeRole = string(node.Spec.Role)
+			dData.NodeAvailability = string(node.Spec.Availability)
+		}
+
+		if p.ExcludeDrainedNodes && dData.NodeAvailability == string(swarmtypes.NodeAvailabilityDrain) {
+			log.Debugf("Filtering task %s running on the drained node %s", dData.Name, dData.NodeID)
+			continue
+		}
+
+		dockerDataList = append(dockerDataList, dData)
+	}
+	return dockerDataList
+}
+
+// setNodeAddresses binds the published ports of a global service to the address of the node of each task.
+func setNodeAddresses(tasks []dockerData, service swarmtypes.Service, nodes map[string]swarmtypes.Node) []dockerData {
+	var dockerDataList []dockerData
+	for _, dData := range tasks {
+		addr := getNodeAddress(nodes[dData.NodeID])
+		if len(addr) == 0 {
+			log.Warnf("Unable to find the address of the node %s for the task %s: ignoring server", dData.NodeID, dData.Name)
+			continue
+		}
+
+		portMap := nat.PortMap{}
+		for _, port := range service.Endpoint.Ports {
+			if port.PublishedPort == 0 {
+				continue
+			}
+
+			natPort, err := nat.NewPort(string(port.Protocol), strconv.Itoa(int(port.TargetPort)))
+			if err != nil {
+				log.Warnf("Invalid port %d/%s for the service %s: %v", port.TargetPort, port.Protocol, service.Spec.Name, err)
+				continue
+			}
+			portMap[natPort] = append(portMap[natPort], nat.PortBinding{
+				HostIP:   addr,
+				HostPort: strconv.Itoa(int(port.PublishedPort)),
+			})
+		}
+
+		if len(portMap) == 0 {
+			log.Warnf("No published port for the global service %s: ignoring server %s", service.Spec.Name, dData.Name)
+			continue
+		}
+
+		dData.NetworkSettings.Ports = portMap
+		dData.BindPortIP = true
+		dockerDataList = append(dockerDataList, dData)
+	}
+	return dockerDataList
+}
+
+// getNodeAddress returns the address of the node, empty if unknown. The address of a manager node may be reported
+// as 0.0.0.0, the one it advertises to the other managers is then used.
+func getNodeAddress(node swarmtypes.Node) string {
+	if ip := net.ParseIP(node.Status.Addr); ip != nil && !ip.IsUnspecified() {
+		return node.Status.Addr
+	}
+
+	if node.ManagerStatus != nil {
+		host, _, err := net.SplitHostPort(node.ManagerStatus.Addr)
+		if ip := net.ParseIP(host); err == nil && ip != nil && !ip.IsUnspecified() {
+			return host
+		}
+	}
+	return ""
+}
+
+// filterConvergedTasks keeps only the tasks running the current spec of a service being updated or rolled back.
+// If no task has converged yet, all the tasks are kept to avoid dropping the service.
+func filterConvergedTasks(tasks []swarmtypes.Task, serviceDockerData dockerData) []swarmtypes.Task {
+	var convergedTasks []swarmtypes.Task
+	for _, task := range tasks {
+		if getTaskSpecVersion(task.Spec) == serviceDockerData.SpecVersion {
+			convergedTasks = append(convergedTasks, task)
+		} else {
+			log.Debugf("Ignoring task %s of service %s: it does not match the current service spec", task.ID, serviceDockerData.Name)
+		}
+	}
+
+	if len(convergedTasks) == 0 {
+		log.Debugf("No task of service %s matches the current service spec, keeping all running tasks", serviceDockerData.Name)
+		return tasks
+	}
+	return convergedTasks
+}
+
+// getUpdateSpecVersion returns the spec version of the tasks to keep while a service is updated, or "" to keep all of them.
+// With start-first, swarm only stops an old task once its replacement is healthy: both are kept meanwhile.
+// With stop-first, the default, only the converged tasks are kept to avoid registering both specs at once.
+func getUpdateSpecVersion(service swarmtypes.Service, updateConfig *swarmtypes.UpdateConfig) string {
+	if updateConfig != nil && updateConfig.Order == swarmtypes.UpdateOrderStartFirst {
+		return ""
+	}
+	return getTaskSpecVersion(service.Spec.TaskTemplate)
+}
+
+func getTaskSpecVersion(spec swarmtypes.TaskSpec) string {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		log.Errorf("Fail to marshal task spec: %v", err)
+		return ""
+	}
+
+	hash := md5.Sum(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// getReservations returns the resources reserved by the task, nil if none is reserved.
+func getReservations(resources *swarmtypes.ResourceRequirements) *swarmtypes.Resources {
+	if resources == nil || resources.Reservations == nil {
+		return nil
+	}
+	return resources.Reservations
+}
+
+// getAttachmentNetworkName returns the name of the network the task is attached to, if it is one of the swarm networks.
+// A nil network map means the swarm networks are unknown: the name is then taken from the attachment.
+func getAttachmentNetworkName(attachment swarmtypes.NetworkAttachment, networkMap map[string]*dockertypes.NetworkResource) (string, bool) {
+	if networkMap == nil {
+		name := attachment.Network.Spec.Annotations.Name
+		return name, len(name) > 0
+	}
+
+	if networkService, present := networkMap[attachment.Network.ID]; present {
+		return networkService.Name, true
+	}
+	return "", false
+}
+
+// isIngressNetwork tells if a network is the swarm ingress network, by its flag or, for the daemons not setting it, by its name.
+func isIngressNetwork(name string, ingress bool) bool {
+	return ingress || name == "ingress"
+}
+
 func parseTasks(task swarmtypes.Task, serviceDockerData dockerData,
 	networkMap map[string]*dockertypes.NetworkResource, isGlobalSvc bool) dockerData {
 	dData := dockerData{
@@ -456,6 +1641,10 @@ func parseTasks(task swarmtypes.Task, serviceDockerData dockerData,
 		Name:            serviceDockerData.Name + "." + strconv.Itoa(task.Slot),
 		Labels:          serviceDockerData.Labels,
 		NetworkSettings: networkSettings{},
+		NodeID:          task.NodeID,
+		Image:           serviceDockerData.Image,
+		Env:             serviceDockerData.Env,
+		Reservations:    getReservations(task.Spec.Resources),
 	}
 
 	if isGlobalSvc {
@@ -465,17 +1654,18 @@ func parseTasks(task swarmtypes.Task, serviceDockerData dockerData,
 	if task.NetworksAttachments != nil {
 		dData.NetworkSettings.Networks = make(map[string]*networkData)
 		for _, virtualIP := range task.NetworksAttachments {
-			if networkService, present := networkMap[virtualIP.Network.ID]; present {
+			if networkName, present := getAttachmentNetworkName(virtualIP, networkMap); present {
 				if len(virtualIP.Addresses) > 0 {
 					// Not sure about this next loop - when would a task have multiple IP's for the same network?
 					for _, addr := range virtualIP.Addresses {
 						ip, _, _ := net.ParseCIDR(addr)
 						network := &networkData{
-							ID:   virtualIP.Network.ID,
-							Name: networkService.Name,
-							Addr: ip.String(),
+							ID:      virtualIP.Network.ID,
+							Name:    networkName,
+							Addr:    ip.String(),
+							Ingress: isIngressNetwork(networkName, virtualIP.Network.Spec.Ingress),
 						}
-						dData.NetworkSettings.Networks[network.Name] = network
+						addNetwork(dData.NetworkSettings.Networks, network)
 					}
 				} else {
 					log.Debugf("No IP addresses found for network %s", virtualIP.Network.ID)
@@ -0,0 +1,1469 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containous/flaeg/parse"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/label"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	dockertypes "github.com/docker/docker/api/types"
+	eventtypes "github.com/docker/docker/api/types/events"
+	dockernetworktypes "github.com/docker/docker/api/types/network"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeContainersClient struct {
+	dockerclient.APIClient
+	containers     map[string]dockertypes.ContainerJSON
+	slowContainers map[string]bool
+	inspected      []string
+	files          map[string]string // Content of the files, by container ID and path
+	version        string
+	closed         bool
+	err            error
+}
+
+// ContainerList builds the summaries from the inspected containers, as the daemon does.
+func (c *fakeContainersClient) ContainerList(ctx context.Context, options dockertypes.ContainerListOptions) ([]dockertypes.Container, error) {
+	var containers []dockertypes.Container
+	for id, inspected := range c.containers {
+		if !options.Filters.MatchKVList("label", inspected.Config.Labels) {
+			continue
+		}
+
+		container := dockertypes.Container{
+			ID:     id,
+			Names:  []string{inspected.Name},
+			Labels: inspected.Config.Labels,
+			Image:  inspected.Config.Image,
+		}
+
+		if inspected.State != nil && inspected.State.Running {
+			container.State = "running"
+			container.Status = "Up 2 minutes"
+			if inspected.State.Health != nil {
+				container.Status += " (" + inspected.State.Health.Status + ")"
+			}
+		}
+
+		for port, bindings := range inspected.NetworkSettings.Ports {
+			if len(bindings) == 0 {
+				container.Ports = append(container.Ports, dockertypes.Port{PrivatePort: uint16(port.Int()), Type: port.Proto()})
+			}
+			for _, binding := range bindings {
+				publicPort, _ := strconv.Atoi(binding.HostPort)
+				container.Ports = append(container.Ports, dockertypes.Port{
+					IP:          binding.HostIP,
+					PrivatePort: uint16(port.Int()),
+					PublicPort:  uint16(publicPort),
+					Type:        port.Proto(),
+				})
+			}
+		}
+
+		container.NetworkSettings = &dockertypes.SummaryNetworkSettings{Networks: inspected.NetworkSettings.Networks}
+
+		containers = append(containers, container)
+	}
+	return containers, c.err
+}
+
+// CopyFromContainer sends the file in a tar archive, as the daemon does.
+func (c *fakeContainersClient) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, dockertypes.ContainerPathStat, error) {
+	content, ok := c.files[containerID+":"+srcPath]
+	if !ok {
+		return nil, dockertypes.ContainerPathStat{}, fmt.Errorf("no such file %s in container %s", srcPath, containerID)
+	}
+
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	if err := writer.WriteHeader(&tar.Header{Name: path.Base(srcPath), Mode: 0644, Size: int64(len(content))}); err != nil {
+		return nil, dockertypes.ContainerPathStat{}, err
+	}
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return nil, dockertypes.ContainerPathStat{}, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, dockertypes.ContainerPathStat{}, err
+	}
+
+	return ioutil.NopCloser(&archive), dockertypes.ContainerPathStat{Name: path.Base(srcPath), Size: int64(len(content))}, nil
+}
+
+func (c *fakeContainersClient) ContainerInspect(ctx context.Context, containerID string) (dockertypes.ContainerJSON, error) {
+	c.inspected = append(c.inspected, containerID)
+	if c.slowContainers[containerID] {
+		<-ctx.Done()
+		return dockertypes.ContainerJSON{}, ctx.Err()
+	}
+	return c.containers[containerID], c.err
+}
+
+func (c *fakeContainersClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeContainersClient) ServerVersion(ctx context.Context) (dockertypes.Version, error) {
+	version := c.version
+	if len(version) == 0 {
+		version = "fake"
+	}
+	return dockertypes.Version{Version: version, APIVersion: DockerAPIVersion}, c.err
+}
+
+func (c *fakeContainersClient) NegotiateAPIVersion(ctx context.Context) {}
+
+func (c *fakeContainersClient) ClientVersion() string {
+	return DockerAPIVersion
+}
+
+func TestListContainersAPITimeout(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"fast": containerJSON(name("fast"), running),
+			"slow": containerJSON(name("slow"), running),
+		},
+		slowContainers: map[string]bool{
+			"slow": true,
+		},
+	}
+
+	provider := &Provider{
+		APITimeout: parse.Duration(10 * time.Millisecond),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dockerDataList, err := provider.listContainers(ctx, dockerClient)
+	require.NoError(t, err)
+
+	require.Len(t, dockerDataList, 1)
+	assert.Equal(t, "fast", dockerDataList[0].Name)
+	assert.NoError(t, ctx.Err(), "the parent context must not be cancelled")
+}
+
+func TestListContainersVanishingStates(t *testing.T) {
+	state := func(status string) func(*dockertypes.ContainerJSON) {
+		return func(c *dockertypes.ContainerJSON) {
+			c.State = &dockertypes.ContainerState{Status: status, Running: true}
+		}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"running":  containerJSON(name("running"), state("running")),
+			"removing": containerJSON(name("removing"), state("removing")),
+			"dead":     containerJSON(name("dead"), state("dead")),
+			"flagged": containerJSON(name("flagged"), func(c *dockertypes.ContainerJSON) {
+				c.State = &dockertypes.ContainerState{Status: "running", Running: true, Dead: true}
+			}),
+		},
+	}
+
+	provider := &Provider{}
+
+	dockerDataList, err := provider.listContainers(context.Background(), dockerClient)
+	require.NoError(t, err)
+
+	require.Len(t, dockerDataList, 1)
+	assert.Equal(t, "running", dockerDataList[0].Name)
+}
+
+func TestListContainersInspectCache(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"unchanged": containerJSON(name("unchanged"), running),
+			"restarted": containerJSON(name("restarted"), running),
+		},
+	}
+
+	provider := &Provider{
+		InspectCache: true,
+	}
+
+	dockerDataList, err := provider.listContainers(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, dockerDataList, 2)
+	assert.Len(t, dockerClient.inspected, 2)
+
+	// The restarted container gets a new status, the other one is unchanged.
+	restarted := dockerClient.containers["restarted"]
+	restarted.State = &dockertypes.ContainerState{Running: true, Health: &dockertypes.Health{Status: "starting"}}
+	dockerClient.containers["restarted"] = restarted
+	dockerClient.inspected = nil
+
+	dockerDataList, err = provider.listContainers(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, dockerDataList, 2)
+	assert.Equal(t, []string{"restarted"}, dockerClient.inspected)
+
+	// The removed containers are dropped from the cache.
+	delete(dockerClient.containers, "unchanged")
+	_, err = provider.listContainers(context.Background(), dockerClient)
+	require.NoError(t, err)
+	assert.NotContains(t, provider.inspected.entries, "unchanged")
+}
+
+type fakeEventsClient struct {
+	dockerclient.APIClient
+	events []eventtypes.Message
+}
+
+func (c *fakeEventsClient) Events(ctx context.Context, options dockertypes.EventsOptions) (<-chan eventtypes.Message, <-chan error) {
+	eventsc := make(chan eventtypes.Message)
+	errc := make(chan error, 1)
+
+	go func() {
+		for _, event := range c.events {
+			if options.Filters.Include("event") && !options.Filters.ExactMatch("event", event.Action) {
+				continue
+			}
+			if !options.Filters.MatchKVList("label", event.Actor.Attributes) {
+				continue
+			}
+			select {
+			case eventsc <- event:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+		<-ctx.Done()
+		errc <- ctx.Err()
+	}()
+
+	return eventsc, errc
+}
+
+func TestListenSwarmEvents(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		filterLabel      string
+		events           []eventtypes.Message
+		expectedServices []string
+	}{
+		{
+			desc: "without label filter",
+			events: []eventtypes.Message{
+				{Action: "update", Actor: eventtypes.Actor{ID: "unlabeled"}},
+				{Action: "update", Actor: eventtypes.Actor{ID: "labeled", Attributes: map[string]string{"traefik.watch": "true"}}},
+			},
+			expectedServices: []string{"unlabeled", "labeled"},
+		},
+		{
+			desc:        "with label filter",
+			filterLabel: "traefik.watch=true",
+			events: []eventtypes.Message{
+				{Action: "create", Actor: eventtypes.Actor{ID: "unlabeled"}},
+				{Action: "create", Actor: eventtypes.Actor{ID: "labeled", Attributes: map[string]string{"traefik.watch": "true"}}},
+			},
+			expectedServices: []string{"labeled"},
+		},
+		{
+			desc:        "with label filter and removed service",
+			filterLabel: "traefik.watch=true",
+			events: []eventtypes.Message{
+				{Action: "remove", Actor: eventtypes.Actor{ID: "removed"}},
+			},
+			expectedServices: []string{"removed"},
+		},
+		{
+			desc:        "with label filter and service updated without the label",
+			filterLabel: "traefik.watch=true",
+			events: []eventtypes.Message{
+				{Action: "update", Actor: eventtypes.Actor{ID: "unlabeled"}},
+			},
+			expectedServices: []string{"unlabeled"},
+		},
+		{
+			desc:        "with label filter and labeled service updated and removed",
+			filterLabel: "traefik.watch=true",
+			events: []eventtypes.Message{
+				{Action: "update", TimeNano: 1, Actor: eventtypes.Actor{ID: "labeled1", Attributes: map[string]string{"traefik.watch": "true"}}},
+				{Action: "update", TimeNano: 2, Actor: eventtypes.Actor{ID: "labeled2", Attributes: map[string]string{"traefik.watch": "true"}}},
+				{Action: "remove", TimeNano: 3, Actor: eventtypes.Actor{ID: "labeled1", Attributes: map[string]string{"traefik.watch": "true"}}},
+			},
+			expectedServices: []string{"labeled1", "labeled2", "labeled1"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{FilterLabel: test.filterLabel}
+			dockerClient := &fakeEventsClient{events: test.events}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			handled := make(chan string, 10)
+			errc := make(chan error, 1)
+			go func() {
+				errc <- provider.listenSwarmEvents(ctx, dockerClient, func(m eventtypes.Message) {
+					handled <- m.Actor.ID
+				})
+			}()
+
+			var services []string
+			for len(services) < len(test.expectedServices) {
+				select {
+				case service := <-handled:
+					services = append(services, service)
+				case <-time.After(5 * time.Second):
+					require.Fail(t, "the events were not handled")
+				}
+			}
+
+			// No event is handled twice.
+			select {
+			case service := <-handled:
+				services = append(services, service)
+			case <-time.After(50 * time.Millisecond):
+			}
+			cancel()
+
+			assert.Equal(t, context.Canceled, <-errc)
+			assert.Equal(t, test.expectedServices, services)
+		})
+	}
+}
+
+type fakeStalledEventsClient struct {
+	dockerclient.APIClient
+	opened chan struct{}
+}
+
+// Events returns a stream which never delivers any event until it is closed.
+func (c *fakeStalledEventsClient) Events(ctx context.Context, options dockertypes.EventsOptions) (<-chan eventtypes.Message, <-chan error) {
+	select {
+	case c.opened <- struct{}{}:
+	default:
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		errc <- ctx.Err()
+	}()
+	return make(chan eventtypes.Message), errc
+}
+
+func TestListenContainersEventsStaleTimeout(t *testing.T) {
+	provider := &Provider{EventsStaleTimeout: parse.Duration(20 * time.Millisecond)}
+	dockerClient := &fakeStalledEventsClient{opened: make(chan struct{}, 10)}
+	eventsQueue := newEventQueue(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- provider.listenContainersEvents(ctx, dockerClient, eventsQueue)
+	}()
+
+	// The stalled stream is opened again, with a full refresh.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-dockerClient.opened:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the events stream was not opened again")
+		}
+	}
+
+	select {
+	case event := <-eventsQueue.events:
+		assert.Equal(t, "reconnect", event.Action)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no refresh queued")
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-errc)
+}
+
+func TestListenContainersEventsWithoutStaleTimeout(t *testing.T) {
+	provider := &Provider{}
+	dockerClient := &fakeStalledEventsClient{opened: make(chan struct{}, 10)}
+	eventsQueue := newEventQueue(10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := provider.listenContainersEvents(ctx, dockerClient, eventsQueue)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Len(t, dockerClient.opened, 1)
+	assert.Zero(t, eventsQueue.drain())
+}
+
+func TestInitDefaultRule(t *testing.T) {
+	provider := &Provider{DefaultRule: "Host:{{ .Name }"}
+
+	err := provider.Init(nil)
+	assert.Error(t, err)
+
+	provider = &Provider{DefaultRule: "Host:{{ .Name }}"}
+
+	err = provider.Init(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, provider.defaultRuleTemplate)
+}
+
+func TestInitDefaultProtocol(t *testing.T) {
+	provider := &Provider{DefaultProtocol: "tcp"}
+
+	err := provider.Init(nil)
+	assert.Error(t, err)
+
+	provider = &Provider{DefaultProtocol: "https"}
+
+	err = provider.Init(nil)
+	require.NoError(t, err)
+}
+
+func TestInitSwarmClassic(t *testing.T) {
+	provider := &Provider{SwarmMode: true, SwarmClassic: true}
+
+	err := provider.Init(nil)
+	assert.Error(t, err)
+
+	provider = &Provider{SwarmClassic: true}
+
+	err = provider.Init(nil)
+	require.NoError(t, err)
+}
+
+func TestInitNameRewrite(t *testing.T) {
+	provider := &Provider{NameRewrite: &NameRewrite{Regex: "(web"}}
+
+	err := provider.Init(nil)
+	assert.Error(t, err)
+}
+
+func TestInitHealthPolicy(t *testing.T) {
+	provider := &Provider{HealthPolicy: "lenient"}
+
+	err := provider.Init(nil)
+	assert.Error(t, err)
+
+	provider = &Provider{HealthPolicy: healthPolicyDegraded}
+
+	err = provider.Init(nil)
+	require.NoError(t, err)
+}
+
+func TestDockerEnvConstraints(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+	env := func(env ...string) func(*dockertypes.ContainerJSON) {
+		return func(c *dockertypes.ContainerJSON) {
+			c.Config.Env = env
+		}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"prod":    containerJSON(name("prod"), running, env("ENV=prod", "PASSWORD=s3cr3t")),
+			"staging": containerJSON(name("staging"), running, env("ENV=staging", "PASSWORD=s3cr3t")),
+			"none":    containerJSON(name("none"), running, env("PASSWORD=s3cr3t")),
+		},
+	}
+
+	testCases := []struct {
+		desc       string
+		parseEnv   bool
+		constraint string
+		expected   []string
+	}{
+		{
+			desc:       "matching env constraint",
+			parseEnv:   true,
+			constraint: "env.ENV==prod",
+			expected:   []string{"prod"},
+		},
+		{
+			desc:       "non matching env constraint",
+			parseEnv:   true,
+			constraint: "env.ENV!=prod",
+			expected:   []string{"staging", "none"},
+		},
+		{
+			desc:       "env constraint ignored when env parsing is disabled",
+			parseEnv:   false,
+			constraint: "env.ENV==prod",
+			expected:   []string{"prod", "staging", "none"},
+		},
+	}
+
+	var logs bytes.Buffer
+	level := log.GetLevel()
+	log.SetOutput(&logs)
+	log.SetLevel(logrus.DebugLevel)
+	defer func() {
+		log.SetOutput(os.Stdout)
+		log.SetLevel(level)
+	}()
+
+	for _, test := range testCases {
+		constraint, err := types.NewConstraint(test.constraint)
+		require.NoError(t, err)
+
+		provider := &Provider{
+			ExposedByDefault: true,
+			Domain:           "docker.localhost",
+			ParseEnv:         test.parseEnv,
+		}
+		err = provider.Init(types.Constraints{constraint})
+		require.NoError(t, err)
+
+		dockerDataList, err := provider.listContainers(context.Background(), dockerClient)
+		require.NoError(t, err)
+
+		var actual []string
+		for _, dData := range dockerDataList {
+			dData.NetworkSettings.Ports = nat.PortMap{"80/tcp": {}}
+			if provider.containerFilter(dData) {
+				actual = append(actual, dData.Name)
+			}
+		}
+
+		assert.ElementsMatch(t, test.expected, actual, test.desc)
+	}
+
+	assert.NotContains(t, logs.String(), "s3cr3t")
+}
+
+func TestNegotiateAPIVersion(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		serverVersion   string
+		expectedVersion string
+	}{
+		{
+			desc:            "older daemon",
+			serverVersion:   "1.22",
+			expectedVersion: "1.22",
+		},
+		{
+			desc:            "newer daemon",
+			serverVersion:   "1.35",
+			expectedVersion: SwarmAPIVersion,
+		},
+		{
+			desc:            "daemon without version header",
+			expectedVersion: SwarmAPIVersion,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var paths []string
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				paths = append(paths, req.URL.Path)
+				if len(test.serverVersion) > 0 {
+					rw.Header().Set("API-Version", test.serverVersion)
+				}
+				if strings.HasSuffix(req.URL.Path, "/version") {
+					rw.Header().Set("Content-Type", "application/json")
+					_, err := rw.Write([]byte(`{"Version":"test","ApiVersion":"` + test.serverVersion + `"}`))
+					require.NoError(t, err)
+				}
+			}))
+			defer server.Close()
+
+			provider := &Provider{
+				Endpoint:  "tcp://" + server.Listener.Addr().String(),
+				SwarmMode: true,
+			}
+
+			dockerClient, err := provider.createClient()
+			require.NoError(t, err)
+
+			provider.negotiateAPIVersion(context.Background(), dockerClient)
+			assert.Equal(t, test.expectedVersion, dockerClient.ClientVersion())
+
+			_, err = dockerClient.ServerVersion(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "/v"+test.expectedVersion+"/version", paths[len(paths)-1])
+		})
+	}
+}
+
+func TestContainerNetworkClientNegotiatedOnce(t *testing.T) {
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"vpn": containerJSON(name("vpn"), withNetwork("bridge", ipv4("172.17.0.5"))),
+		},
+	}
+
+	var created int
+	provider := &Provider{
+		ClientFactory: func() (dockerclient.APIClient, error) {
+			created++
+			return dockerClient, nil
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		dData := parseContainer(containerJSON(name(fmt.Sprintf("web%d", i)), networkMode("container:vpn")))
+		assert.Equal(t, "172.17.0.5", provider.getIPAddress(dData))
+	}
+	assert.Equal(t, 1, created, "the client must be kept between the containers")
+}
+
+func TestCreateHTTPClientProxy(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		endpoint      string
+		proxy         string
+		expectedProxy string
+	}{
+		{
+			desc:     "unix socket",
+			endpoint: "unix:///var/run/docker.sock",
+		},
+		{
+			desc:     "tcp endpoint with proxy from environment",
+			endpoint: "tcp://10.0.0.1:2375",
+		},
+		{
+			desc:          "tcp endpoint with proxy option",
+			endpoint:      "tcp://10.0.0.1:2375",
+			proxy:         "http://proxy.localhost:8080",
+			expectedProxy: "http://proxy.localhost:8080",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				Endpoint: test.endpoint,
+				Proxy:    test.proxy,
+			}
+
+			httpClient, err := provider.createHTTPClient(provider.Endpoint)
+			require.NoError(t, err)
+
+			if !strings.HasPrefix(test.endpoint, "tcp://") {
+				assert.Nil(t, httpClient)
+				return
+			}
+
+			require.NotNil(t, httpClient)
+			tr, ok := httpClient.Transport.(*http.Transport)
+			require.True(t, ok)
+			require.NotNil(t, tr.Proxy)
+
+			// The environment is only read once per process by net/http, only the overridden proxy can be checked.
+			if len(test.expectedProxy) > 0 {
+				req := httptest.NewRequest(http.MethodGet, "http://10.0.0.1:2375/_ping", nil)
+				proxyURL, err := tr.Proxy(req)
+				require.NoError(t, err)
+				require.NotNil(t, proxyURL)
+				assert.Equal(t, test.expectedProxy, proxyURL.String())
+			}
+		})
+	}
+}
+
+func TestCreateHTTPClientTLSServerName(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		serverName string
+	}{
+		{
+			desc: "endpoint host",
+		},
+		{
+			desc:       "server name override",
+			serverName: "docker.example.com",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				Endpoint: "tcp://10.0.0.1:2376",
+				TLS: &types.ClientTLS{
+					InsecureSkipVerify: true,
+					ServerName:         test.serverName,
+				},
+			}
+
+			httpClient, err := provider.createHTTPClient(provider.Endpoint)
+			require.NoError(t, err)
+			require.NotNil(t, httpClient)
+
+			tr, ok := httpClient.Transport.(*http.Transport)
+			require.True(t, ok)
+			require.NotNil(t, tr.TLSClientConfig)
+
+			assert.Equal(t, test.serverName, tr.TLSClientConfig.ServerName)
+			assert.True(t, tr.TLSClientConfig.InsecureSkipVerify)
+		})
+	}
+}
+
+func TestCreateClientDialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, err := rw.Write([]byte(`{"Version":"test","ApiVersion":"` + DockerAPIVersion + `"}`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	var dialed []string
+	provider := &Provider{
+		Endpoint: "vsock://3:2375",
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "tcp", server.Listener.Addr().String())
+		},
+	}
+
+	dockerClient, err := provider.createClient()
+	require.NoError(t, err)
+
+	version, err := dockerClient.ServerVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test", version.Version)
+	assert.Equal(t, []string{"3:2375"}, dialed)
+}
+
+func TestProvideClientFactory(t *testing.T) {
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"test": containerJSON(
+				name("test"),
+				func(c *dockertypes.ContainerJSON) {
+					c.State = &dockertypes.ContainerState{Running: true}
+				},
+				ports(nat.PortMap{"80/tcp": {}}),
+				withNetwork("testnet", ipv4("10.10.10.10"))),
+		},
+	}
+
+	provider := &Provider{
+		Endpoint:         "tcp://unreachable.localhost:2375",
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		ClientFactory: func() (dockerclient.APIClient, error) {
+			return dockerClient, nil
+		},
+	}
+
+	configurationChan := make(chan types.ConfigMessage, 1)
+	err := provider.Provide(configurationChan, safe.NewPool(context.Background()))
+	require.NoError(t, err)
+
+	select {
+	case message := <-configurationChan:
+		require.NotNil(t, message.Configuration)
+		assert.Contains(t, message.Configuration.Backends, "backend-test")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no configuration received from the provider")
+	}
+}
+
+func TestIsPodman(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected bool
+	}{
+		{version: "3.4.4", expected: true},
+		{version: "4.9.3", expected: true},
+		{version: "1.13.1", expected: false},
+		{version: "17.03.0-ce", expected: false},
+		{version: "20.10.7", expected: false},
+		{version: "fake", expected: false},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.version, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, isPodman(dockertypes.Version{Version: test.version}))
+		})
+	}
+}
+
+func TestProvidePodman(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		swarmMode bool
+	}{
+		{
+			desc: "docker mode",
+		},
+		{
+			desc:      "swarm mode",
+			swarmMode: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dockerClient := &fakeContainersClient{
+				version: "4.3.1",
+				containers: map[string]dockertypes.ContainerJSON{
+					"test": containerJSON(
+						name("test"),
+						func(c *dockertypes.ContainerJSON) {
+							c.State = &dockertypes.ContainerState{Running: true}
+						},
+						ports(nat.PortMap{"80/tcp": {}}),
+						withNetwork("podman", ipv4("10.88.0.2"))),
+				},
+			}
+
+			provider := &Provider{
+				Domain:           "docker.localhost",
+				ExposedByDefault: true,
+				SwarmMode:        test.swarmMode,
+				ClientFactory: func() (dockerclient.APIClient, error) {
+					return dockerClient, nil
+				},
+			}
+
+			configurationChan := make(chan types.ConfigMessage, 1)
+			err := provider.Provide(configurationChan, safe.NewPool(context.Background()))
+			require.NoError(t, err)
+
+			if !test.swarmMode {
+				select {
+				case message := <-configurationChan:
+					require.NotNil(t, message.Configuration)
+					assert.Contains(t, message.Configuration.Backends, "backend-test")
+				case <-time.After(5 * time.Second):
+					t.Fatal("no configuration received from the provider")
+				}
+				return
+			}
+
+			timeout := time.After(5 * time.Second)
+			for provider.ConnectionState().LastError == nil {
+				select {
+				case <-timeout:
+					t.Fatal("the provider did not fail")
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+			assert.Contains(t, provider.ConnectionState().LastError.Error(), "not supported by Podman")
+			assert.Empty(t, configurationChan)
+		})
+	}
+}
+
+func TestListContainersSkipInspect(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+	healthy := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true, Health: &dockertypes.Health{Status: "healthy"}}
+	}
+	stopped := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: false}
+	}
+
+	testCases := []struct {
+		desc              string
+		provider          *Provider
+		expectedInspected []string
+	}{
+		{
+			desc:              "inspect all the containers by default",
+			provider:          &Provider{},
+			expectedInspected: []string{"simple", "health", "stopped"},
+		},
+		{
+			desc:              "only inspect the containers with a health check",
+			provider:          &Provider{SkipInspect: true},
+			expectedInspected: []string{"health"},
+		},
+		{
+			desc:              "inspect all the containers when the restart count is needed",
+			provider:          &Provider{SkipInspect: true, MaxRestartCount: 5},
+			expectedInspected: []string{"simple", "health", "stopped"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dockerClient := &fakeContainersClient{
+				containers: map[string]dockertypes.ContainerJSON{
+					"simple": containerJSON(name("/simple"), running,
+						labels(map[string]string{"traefik.port": "80"}),
+						ports(nat.PortMap{"80/tcp": {}, "443/tcp": {{HostIP: "0.0.0.0", HostPort: "8443"}}}),
+						withNetwork("testnet", ipv4("10.10.10.10"))),
+					"health": containerJSON(name("/health"), healthy,
+						ports(nat.PortMap{"80/tcp": {}}),
+						withNetwork("testnet", ipv4("10.10.10.11"))),
+					"stopped": containerJSON(name("/stopped"), stopped),
+				},
+			}
+
+			dockerDataList, err := test.provider.listContainers(context.Background(), dockerClient)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, test.expectedInspected, dockerClient.inspected)
+
+			actual := make(map[string]dockerData)
+			for _, dData := range dockerDataList {
+				actual[dData.Name] = dData
+			}
+			require.Len(t, actual, 2)
+
+			assert.Equal(t, "healthy", actual["/health"].Health)
+
+			simple := actual["/simple"]
+			assert.Equal(t, map[string]string{"traefik.port": "80"}, simple.Labels)
+			assert.Equal(t, "10.10.10.10", simple.NetworkSettings.Networks["testnet"].Addr)
+			require.Len(t, simple.NetworkSettings.Ports, 2)
+			assert.Empty(t, simple.NetworkSettings.Ports["80/tcp"])
+			assert.Equal(t, []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: "8443"}}, simple.NetworkSettings.Ports["443/tcp"])
+		})
+	}
+}
+
+func TestNeedsInspect(t *testing.T) {
+	container := func(status string, ports []dockertypes.Port, addr string) dockertypes.Container {
+		return dockertypes.Container{
+			State:  "running",
+			Status: status,
+			Ports:  ports,
+			NetworkSettings: &dockertypes.SummaryNetworkSettings{
+				Networks: map[string]*dockernetworktypes.EndpointSettings{
+					"testnet": {IPAddress: addr},
+				},
+			},
+		}
+	}
+	httpPorts := []dockertypes.Port{{PrivatePort: 80, Type: "tcp"}}
+
+	testCases := []struct {
+		desc      string
+		provider  *Provider
+		container dockertypes.Container
+		expected  bool
+	}{
+		{
+			desc:      "without skipInspect",
+			provider:  &Provider{},
+			container: container("Up 2 minutes", httpPorts, "10.10.10.10"),
+			expected:  true,
+		},
+		{
+			desc:      "complete list response",
+			provider:  &Provider{SkipInspect: true},
+			container: container("Up 2 minutes", httpPorts, "10.10.10.10"),
+		},
+		{
+			desc:      "health check",
+			provider:  &Provider{SkipInspect: true},
+			container: container("Up 2 minutes (healthy)", httpPorts, "10.10.10.10"),
+			expected:  true,
+		},
+		{
+			desc:      "network aliases",
+			provider:  &Provider{SkipInspect: true, UseNetworkAliases: true},
+			container: container("Up 2 minutes", httpPorts, "10.10.10.10"),
+			expected:  true,
+		},
+		{
+			desc:      "exposed ports only given by the inspection",
+			provider:  &Provider{SkipInspect: true},
+			container: container("Up 2 minutes", nil, "10.10.10.10"),
+			expected:  true,
+		},
+		{
+			desc:      "without address, e.g. a Windows container",
+			provider:  &Provider{SkipInspect: true},
+			container: container("Up 2 minutes", httpPorts, ""),
+			expected:  true,
+		},
+		{
+			desc:      "not running",
+			provider:  &Provider{SkipInspect: true},
+			container: dockertypes.Container{State: "exited", Status: "Exited (0) 2 minutes ago"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, test.provider.needsInspect(test.container))
+		})
+	}
+}
+
+func TestListContainersLabelPrefix(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"public": containerJSON(name("/public"), running,
+				labels(map[string]string{
+					"traefik.enable":        "true",
+					"traefik.frontend.rule": "Host:public.localhost",
+				}),
+				ports(nat.PortMap{"80/tcp": {}}),
+				withNetwork("testnet", ipv4("10.10.10.10"))),
+			"internal": containerJSON(name("/internal"), running,
+				labels(map[string]string{
+					"traefik-internal.enable":        "true",
+					"traefik-internal.frontend.rule": "Host:internal.localhost",
+					"traefik-internal.port":          "8080",
+				}),
+				ports(nat.PortMap{"80/tcp": {}, "8080/tcp": {}}),
+				withNetwork("testnet", ipv4("10.10.10.11"))),
+		},
+	}
+
+	testCases := []struct {
+		desc             string
+		labelPrefix      string
+		expectedRule     string
+		expectedBackend  string
+		expectedServerIP string
+	}{
+		{
+			desc:             "default prefix",
+			labelPrefix:      "traefik",
+			expectedRule:     "Host:public.localhost",
+			expectedBackend:  "backend-public",
+			expectedServerIP: "http://10.10.10.10:80",
+		},
+		{
+			desc:             "custom prefix",
+			labelPrefix:      "traefik-internal",
+			expectedRule:     "Host:internal.localhost",
+			expectedBackend:  "backend-internal",
+			expectedServerIP: "http://10.10.10.11:8080",
+		},
+	}
+
+	for _, test := range testCases {
+		provider := &Provider{
+			Domain:      "docker.localhost",
+			LabelPrefix: test.labelPrefix,
+		}
+
+		dockerDataList, err := provider.listContainers(context.Background(), dockerClient)
+		require.NoError(t, err, test.desc)
+
+		config := provider.buildConfiguration(dockerDataList)
+		require.NotNil(t, config, test.desc)
+
+		require.Len(t, config.Frontends, 1, test.desc)
+		for _, frontend := range config.Frontends {
+			for _, route := range frontend.Routes {
+				assert.Equal(t, test.expectedRule, route.Rule, test.desc)
+			}
+		}
+
+		require.Len(t, config.Backends, 1, test.desc)
+		require.Contains(t, config.Backends, test.expectedBackend, test.desc)
+		for _, server := range config.Backends[test.expectedBackend].Servers {
+			assert.Equal(t, test.expectedServerIP, server.URL, test.desc)
+		}
+	}
+}
+
+func TestListContainersComposeProject(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"shop": containerJSON(name("/shop_web_1"), running,
+				labels(map[string]string{
+					labelDockerComposeProject: "shop",
+					labelDockerComposeService: "web",
+				}),
+				ports(nat.PortMap{"80/tcp": {}}),
+				withNetwork("testnet", ipv4("10.10.10.10"))),
+			"blog": containerJSON(name("/blog_web_1"), running,
+				labels(map[string]string{
+					labelDockerComposeProject: "blog",
+					labelDockerComposeService: "web",
+				}),
+				ports(nat.PortMap{"80/tcp": {}}),
+				withNetwork("testnet", ipv4("10.10.10.11"))),
+			"standalone": containerJSON(name("/standalone"), running,
+				ports(nat.PortMap{"80/tcp": {}}),
+				withNetwork("testnet", ipv4("10.10.10.12"))),
+		},
+	}
+
+	testCases := []struct {
+		desc           string
+		composeProject string
+		expected       []string
+	}{
+		{
+			desc:     "all the containers",
+			expected: []string{"/blog_web_1", "/shop_web_1", "/standalone"},
+		},
+		{
+			desc:           "containers of the project",
+			composeProject: "shop",
+			expected:       []string{"/shop_web_1"},
+		},
+		{
+			desc:           "unknown project",
+			composeProject: "wiki",
+		},
+	}
+
+	for _, test := range testCases {
+		provider := &Provider{
+			ComposeProject: test.composeProject,
+		}
+
+		dockerDataList, err := provider.listContainers(context.Background(), dockerClient)
+		require.NoError(t, err, test.desc)
+
+		var actual []string
+		for _, dData := range dockerDataList {
+			actual = append(actual, dData.Name)
+		}
+		sort.Strings(actual)
+		assert.Equal(t, test.expected, actual, test.desc)
+
+		// The events of the containers outside the project are filtered out by the daemon too.
+		eventsFilters := provider.containersEventsFilters()
+		assert.Equal(t, []string{"container"}, eventsFilters.Get("type"), test.desc)
+		if len(test.composeProject) > 0 {
+			assert.Equal(t, []string{labelDockerComposeProject + "=" + test.composeProject}, eventsFilters.Get("label"), test.desc)
+		} else {
+			assert.Empty(t, eventsFilters.Get("label"), test.desc)
+		}
+	}
+}
+
+func TestListContainersConfigFromFile(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"withfile": containerJSON(name("/withfile"), running,
+				labels(map[string]string{
+					labelDockerConfigFile: "/etc/traefik/labels",
+					label.TraefikPort:     "80",
+				}),
+				ports(nat.PortMap{"80/tcp": {}, "8080/tcp": {}})),
+			"missingfile": containerJSON(name("/missingfile"), running,
+				labels(map[string]string{
+					labelDockerConfigFile: "/etc/traefik/missing",
+				}),
+				ports(nat.PortMap{"80/tcp": {}})),
+		},
+		files: map[string]string{
+			"withfile:/etc/traefik/labels": "# Routing\ntraefik.frontend.rule=Host:file.localhost\n\ntraefik.port=8080\n",
+		},
+	}
+
+	testCases := []struct {
+		desc           string
+		configFromFile bool
+		expected       map[string]map[string]string
+	}{
+		{
+			desc: "disabled",
+			expected: map[string]map[string]string{
+				"/withfile": {
+					labelDockerConfigFile: "/etc/traefik/labels",
+					label.TraefikPort:     "80",
+				},
+				"/missingfile": {
+					labelDockerConfigFile: "/etc/traefik/missing",
+				},
+			},
+		},
+		{
+			desc:           "enabled",
+			configFromFile: true,
+			expected: map[string]map[string]string{
+				"/withfile": {
+					labelDockerConfigFile:     "/etc/traefik/labels",
+					label.TraefikFrontendRule: "Host:file.localhost",
+					label.TraefikPort:         "80",
+				},
+				"/missingfile": {
+					labelDockerConfigFile: "/etc/traefik/missing",
+				},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		provider := &Provider{ConfigFromFile: test.configFromFile}
+
+		dockerDataList, err := provider.listContainers(context.Background(), dockerClient)
+		require.NoError(t, err, test.desc)
+
+		actual := make(map[string]map[string]string)
+		for _, dData := range dockerDataList {
+			actual[dData.Name] = dData.Labels
+		}
+		assert.Equal(t, test.expected, actual, test.desc)
+	}
+}
+
+func TestParseLabelsFile(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		content     string
+		expected    map[string]string
+		expectedErr bool
+	}{
+		{
+			desc:     "key values and comments",
+			content:  "# comment\ntraefik.enable=true\ntraefik.frontend.rule=Host:a.localhost;Path:/a=b\n\ntraefik.tags\n",
+			expected: map[string]string{"traefik.enable": "true", "traefik.frontend.rule": "Host:a.localhost;Path:/a=b", "traefik.tags": ""},
+		},
+		{
+			desc:        "missing key",
+			content:     "=value\n",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			labels, err := parseLabelsFile(strings.NewReader(test.content))
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, labels)
+		})
+	}
+}
+
+func TestPushConfigurationUnchanged(t *testing.T) {
+	containers := []dockerData{
+		parseContainer(containerJSON(name("foo"),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10")))),
+		parseContainer(containerJSON(name("bar"),
+			labels(map[string]string{
+				label.TraefikBackendHealthCheckPath: "/health",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11")))),
+	}
+	reversed := []dockerData{containers[1], containers[0]}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	configurationChan := make(chan types.ConfigMessage, 3)
+
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(containers))
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(reversed))
+	assert.Len(t, configurationChan, 1)
+
+	containers[0].NetworkSettings.Networks["testnet"].Addr = "10.10.10.12"
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(containers))
+	assert.Len(t, configurationChan, 2)
+
+	first := <-configurationChan
+	second := <-configurationChan
+	assert.NotEqual(t, first.Configuration, second.Configuration)
+}
+
+func TestPushConfigurationPaused(t *testing.T) {
+	containers := []dockerData{
+		parseContainer(containerJSON(name("foo"),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10")))),
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	configurationChan := make(chan types.ConfigMessage, 3)
+
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(containers))
+	require.Len(t, configurationChan, 1)
+	<-configurationChan
+
+	provider.Pause()
+
+	// The events received while paused build configurations that are not pushed.
+	containers[0].NetworkSettings.Networks["testnet"].Addr = "10.10.10.11"
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(containers))
+	containers[0].NetworkSettings.Networks["testnet"].Addr = "10.10.10.12"
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(containers))
+	assert.Len(t, configurationChan, 0)
+
+	provider.Resume()
+	require.Len(t, configurationChan, 1)
+
+	message := <-configurationChan
+	require.Contains(t, message.Configuration.Backends, "backend-foo")
+	for _, server := range message.Configuration.Backends["backend-foo"].Servers {
+		assert.Equal(t, "http://10.10.10.12:80", server.URL)
+	}
+
+	// Resuming again does not push anything, and the next configurations are pushed.
+	provider.Resume()
+	assert.Len(t, configurationChan, 0)
+
+	containers[0].NetworkSettings.Networks["testnet"].Addr = "10.10.10.13"
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(containers))
+	assert.Len(t, configurationChan, 1)
+}
+
+func TestResumeRefresh(t *testing.T) {
+	container := func(ip string) dockertypes.ContainerJSON {
+		return containerJSON(
+			name("test"),
+			func(c *dockertypes.ContainerJSON) {
+				c.State = &dockertypes.ContainerState{Running: true}
+			},
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4(ip)))
+	}
+
+	dockerClient := &fakeWatchClient{
+		fakeContainersClient: &fakeContainersClient{
+			containers: map[string]dockertypes.ContainerJSON{"test": container("10.10.10.10")},
+		},
+		errc: make(chan error),
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		ClientFactory: func() (dockerclient.APIClient, error) {
+			return dockerClient, nil
+		},
+	}
+	provider.Watch = true
+
+	configurationChan := make(chan types.ConfigMessage, 1)
+
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+
+	require.NoError(t, provider.Provide(configurationChan, pool))
+
+	receiveURL := func() string {
+		select {
+		case message := <-configurationChan:
+			require.Contains(t, message.Configuration.Backends, "backend-test")
+			for _, server := range message.Configuration.Backends["backend-test"].Servers {
+				return server.URL
+			}
+			t.Fatal("no server in the configuration")
+		case <-time.After(5 * time.Second):
+			t.Fatal("no configuration received from the provider")
+		}
+		return ""
+	}
+
+	assert.Equal(t, "http://10.10.10.10:80", receiveURL())
+
+	// The resync is registered once the events are watched.
+	for i := 0; provider.getResync() == nil; i++ {
+		require.True(t, i < 500, "the provider never watched the events")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	provider.Pause()
+
+	// No event is received for the change made while paused: the resume lists the containers again.
+	dockerClient.containers["test"] = container("10.10.10.11")
+
+	provider.Resume()
+	assert.Equal(t, "http://10.10.10.11:80", receiveURL())
+}
+
+func TestResumeUnchangedConfiguration(t *testing.T) {
+	containers := []dockerData{
+		parseContainer(containerJSON(name("foo"),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10")))),
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	configurationChan := make(chan types.ConfigMessage, 2)
+
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(containers))
+	<-configurationChan
+
+	provider.Pause()
+	provider.pushConfiguration(configurationChan, provider.buildConfiguration(containers))
+	provider.Resume()
+
+	assert.Len(t, configurationChan, 0)
+}
@@ -0,0 +1,119 @@
+package docker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	eventtypes "github.com/docker/docker/api/types/events"
+)
+
+const healthStatusAction = "health_status"
+
+// defaultEventsBufferSize is the number of events buffered while the configuration is refreshed, when not configured.
+const defaultEventsBufferSize = 100
+
+// eventFilter selects the container events which trigger a refresh of the configuration.
+// It is not safe for concurrent use: it is owned by the events loop.
+type eventFilter struct {
+	ignoreHealthEvents bool
+	healthRouting      func(health string) string
+	routing            map[string]string // Last known routing of the containers given by their health, by ID
+}
+
+func newEventFilter(ignoreHealthEvents bool, healthRouting func(health string) string) *eventFilter {
+	return &eventFilter{
+		ignoreHealthEvents: ignoreHealthEvents,
+		healthRouting:      healthRouting,
+		routing:            make(map[string]string),
+	}
+}
+
+// accept returns true if the event may change the configuration.
+// A health event only does when the new health changes the routing of the container under the health policy.
+func (f *eventFilter) accept(event eventtypes.Message) bool {
+	switch {
+	case event.Action == "start" || event.Action == "die":
+		delete(f.routing, event.Actor.ID)
+		return true
+
+	case strings.HasPrefix(event.Action, healthStatusAction):
+		if f.ignoreHealthEvents {
+			return false
+		}
+
+		// The action is "health_status: healthy", "health_status: unhealthy" or "health_status: starting".
+		status := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(event.Action, healthStatusAction), ":"))
+		routing := f.healthRouting(status)
+
+		if previous, ok := f.routing[event.Actor.ID]; ok && previous == routing {
+			return false
+		}
+		f.routing[event.Actor.ID] = routing
+		return true
+	}
+
+	return false
+}
+
+// eventQueue decouples the reading of the events stream from the refreshes of the configuration,
+// so that a slow refresh never blocks the stream.
+// As each refresh lists all the containers, the events queued meanwhile are coalesced into the next refresh.
+type eventQueue struct {
+	events   chan eventtypes.Message
+	overflow chan struct{} // Marks the events dropped on a full queue, a single refresh covering them all
+}
+
+func newEventQueue(size int) *eventQueue {
+	if size <= 0 {
+		size = defaultEventsBufferSize
+	}
+
+	return &eventQueue{
+		events:   make(chan eventtypes.Message, size),
+		overflow: make(chan struct{}, 1),
+	}
+}
+
+// push queues the event without ever blocking.
+func (q *eventQueue) push(event eventtypes.Message) {
+	select {
+	case q.events <- event:
+	default:
+		select {
+		case q.overflow <- struct{}{}:
+			log.Debugf("Docker events queue full (%d events), collapsing the next events into a single refresh", cap(q.events))
+		default:
+		}
+	}
+}
+
+// run calls refresh once for each batch of queued events, until the context is done.
+func (q *eventQueue) run(ctx context.Context, refresh func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.events:
+		case <-q.overflow:
+		}
+
+		log.Debugf("Refreshing the configuration for %d docker events", 1+q.drain())
+		refresh()
+	}
+}
+
+// drain empties the queue, as the next refresh covers its events, and returns how many events were removed.
+func (q *eventQueue) drain() int {
+	var count int
+	for {
+		select {
+		case <-q.events:
+			count++
+		case <-q.overflow:
+			count++
+		default:
+			return count
+		}
+	}
+}
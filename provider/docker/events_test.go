@@ -0,0 +1,243 @@
+package docker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	eventtypes "github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFilter(t *testing.T) {
+	event := func(id, action string) eventtypes.Message {
+		return eventtypes.Message{
+			Type:   "container",
+			Action: action,
+			Actor:  eventtypes.Actor{ID: id},
+		}
+	}
+
+	testCases := []struct {
+		desc               string
+		ignoreHealthEvents bool
+		healthPolicy       string
+		events             []eventtypes.Message
+		expected           []bool
+	}{
+		{
+			desc: "start and die",
+			events: []eventtypes.Message{
+				event("c1", "start"),
+				event("c1", "die"),
+				event("c1", "exec_start: sh"),
+			},
+			expected: []bool{true, true, false},
+		},
+		{
+			desc: "repeated identical health events",
+			events: []eventtypes.Message{
+				event("c1", "health_status: healthy"),
+				event("c1", "health_status: healthy"),
+				event("c1", "health_status: healthy"),
+			},
+			expected: []bool{true, false, false},
+		},
+		{
+			desc: "health transitions",
+			events: []eventtypes.Message{
+				event("c1", "health_status: starting"),
+				event("c1", "health_status: unhealthy"),
+				event("c1", "health_status: healthy"),
+				event("c1", "health_status: unhealthy"),
+			},
+			expected: []bool{true, false, true, true},
+		},
+		{
+			desc:         "health transitions with the degraded policy",
+			healthPolicy: healthPolicyDegraded,
+			events: []eventtypes.Message{
+				event("c1", "health_status: starting"),
+				event("c1", "health_status: unhealthy"),
+				event("c1", "health_status: healthy"),
+				event("c1", "health_status: starting"),
+			},
+			expected: []bool{true, true, true, true},
+		},
+		{
+			desc:         "health transitions with the ignore policy",
+			healthPolicy: healthPolicyIgnore,
+			events: []eventtypes.Message{
+				event("c1", "health_status: starting"),
+				event("c1", "health_status: unhealthy"),
+				event("c1", "health_status: healthy"),
+			},
+			expected: []bool{true, false, false},
+		},
+		{
+			desc: "health tracked by container",
+			events: []eventtypes.Message{
+				event("c1", "health_status: healthy"),
+				event("c2", "health_status: healthy"),
+				event("c1", "health_status: healthy"),
+			},
+			expected: []bool{true, true, false},
+		},
+		{
+			desc: "health reset on restart",
+			events: []eventtypes.Message{
+				event("c1", "health_status: healthy"),
+				event("c1", "die"),
+				event("c1", "start"),
+				event("c1", "health_status: healthy"),
+			},
+			expected: []bool{true, true, true, true},
+		},
+		{
+			desc:               "health events ignored",
+			ignoreHealthEvents: true,
+			events: []eventtypes.Message{
+				event("c1", "start"),
+				event("c1", "health_status: healthy"),
+				event("c1", "health_status: unhealthy"),
+			},
+			expected: []bool{true, false, false},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{HealthPolicy: test.healthPolicy}
+			filter := newEventFilter(test.ignoreHealthEvents, provider.getHealthRouting)
+
+			var actual []bool
+			for _, event := range test.events {
+				actual = append(actual, filter.accept(event))
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestEventQueueBurst(t *testing.T) {
+	queue := newEventQueue(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first refresh is slow, while the burst of events is received.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var refreshes int32
+	refreshed := make(chan struct{}, 1000)
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		queue.run(ctx, func() {
+			if atomic.AddInt32(&refreshes, 1) == 1 {
+				close(started)
+				<-release
+			}
+			refreshed <- struct{}{}
+		})
+	}()
+
+	queue.push(eventtypes.Message{Type: "container", Action: "start"})
+	<-started
+
+	pushed := make(chan struct{})
+	go func() {
+		defer close(pushed)
+		for i := 0; i < 1000; i++ {
+			queue.push(eventtypes.Message{Type: "container", Action: "start"})
+		}
+	}()
+
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "pushing the events blocked")
+	}
+
+	close(release)
+
+	// The events received during the slow refresh are coalesced into a single one.
+	<-refreshed
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the events received during the refresh were lost")
+	}
+	assert.Len(t, queue.events, 0)
+	assert.Len(t, queue.overflow, 0)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&refreshes))
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the queue did not stop with its context")
+	}
+}
+
+func TestEventQueueSwarmServices(t *testing.T) {
+	queue := newEventQueue(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first refresh is slow, while several services are scaled.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var refreshes, running, overlaps int32
+	refreshed := make(chan struct{}, 100)
+
+	go queue.run(ctx, func() {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		if atomic.AddInt32(&refreshes, 1) == 1 {
+			close(started)
+			<-release
+		}
+		atomic.AddInt32(&running, -1)
+		refreshed <- struct{}{}
+	})
+
+	service := func(id string) eventtypes.Message {
+		return eventtypes.Message{Type: "service", Action: "update", Actor: eventtypes.Actor{ID: id}}
+	}
+
+	queue.push(service("service1"))
+	<-started
+
+	for i := 0; i < 5; i++ {
+		queue.push(service("service1"))
+		queue.push(service("service2"))
+	}
+	close(release)
+
+	// The events of all the services received during the refresh are coalesced into a single one,
+	// which runs after the current one so that the newest listing is pushed last.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-refreshed:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the events received during the refresh were lost")
+		}
+	}
+
+	select {
+	case <-refreshed:
+		require.Fail(t, "the events were not coalesced")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&refreshes))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&overlaps))
+}
@@ -0,0 +1,384 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cenk/backoff"
+	"github.com/containous/traefik/job"
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	eventtypes "github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+	"gopkg.in/fsnotify.v1"
+)
+
+// hostEventsRetryDelay is the delay before listening again to the events of a host of the hosts file,
+// once its events stream is closed.
+const hostEventsRetryDelay = 5 * time.Second
+
+// readHostsFile returns the docker endpoints listed in the file, one per line, e.g. tcp://10.0.0.2:2376.
+// The empty lines, the comments starting with # and the duplicate endpoints are ignored.
+func readHostsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var endpoints []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		endpoint := strings.TrimSpace(scanner.Text())
+		if len(endpoint) == 0 || strings.HasPrefix(endpoint, "#") || seen[endpoint] {
+			continue
+		}
+
+		if _, err := client.ParseHostURL(endpoint); err != nil {
+			log.Warnf("Invalid docker endpoint %q in %s, ignoring it: %v", endpoint, path, err)
+			continue
+		}
+
+		seen[endpoint] = true
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// getHostAddress returns the address of the host of a tcp endpoint, empty for a local one (unix socket or named pipe).
+func getHostAddress(endpoint string) string {
+	hostURL, err := client.ParseHostURL(endpoint)
+	if err != nil || hostURL.Scheme != "tcp" {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(hostURL.Host)
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// getHostClient returns the client of a host of the hosts file, created on the first use.
+// The API version is negotiated out of the lock, so that an unreachable host does not hold the other ones.
+func (p *Provider) getHostClient(endpoint string) (client.APIClient, error) {
+	p.hostsMu.Lock()
+	dockerClient, ok := p.hostClients[endpoint]
+	p.hostsMu.Unlock()
+	if ok {
+		return dockerClient, nil
+	}
+
+	var err error
+	if p.hostClientFactory != nil {
+		dockerClient, err = p.hostClientFactory(endpoint)
+	} else {
+		dockerClient, err = p.createEndpointClient(endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p.negotiateAPIVersion(context.Background(), dockerClient)
+
+	p.hostsMu.Lock()
+	defer p.hostsMu.Unlock()
+
+	// The client may have been created meanwhile for another refresh.
+	if existing, ok := p.hostClients[endpoint]; ok {
+		closeHostClient(endpoint, dockerClient)
+		return existing, nil
+	}
+
+	if p.hostClients == nil {
+		p.hostClients = make(map[string]client.APIClient)
+	}
+	p.hostClients[endpoint] = dockerClient
+	return dockerClient, nil
+}
+
+// closeHostClient closes the idle connections of the client of a host.
+func closeHostClient(endpoint string, dockerClient client.APIClient) {
+	closer, ok := dockerClient.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		log.Debugf("Failed to close the client of the docker host %s: %v", endpoint, err)
+	}
+}
+
+// getHostInspectCache returns the inspect cache of a host of the hosts file: each host has its own,
+// as the caches are pruned from the containers listed on their daemon.
+func (p *Provider) getHostInspectCache(endpoint string) *inspectCache {
+	p.hostsMu.Lock()
+	defer p.hostsMu.Unlock()
+
+	if p.hostInspected == nil {
+		p.hostInspected = make(map[string]*inspectCache)
+	}
+	if _, ok := p.hostInspected[endpoint]; !ok {
+		p.hostInspected[endpoint] = &inspectCache{}
+	}
+	return p.hostInspected[endpoint]
+}
+
+// pruneHosts drops the inspect caches of the hosts removed from the hosts file, and closes their clients.
+func (p *Provider) pruneHosts(endpoints []string) {
+	p.hostsMu.Lock()
+	defer p.hostsMu.Unlock()
+
+	listed := make(map[string]bool, len(endpoints))
+	for _, endpoint := range endpoints {
+		listed[endpoint] = true
+	}
+
+	for endpoint := range p.hostInspected {
+		if !listed[endpoint] {
+			delete(p.hostInspected, endpoint)
+		}
+	}
+
+	for endpoint, dockerClient := range p.hostClients {
+		if !listed[endpoint] {
+			closeHostClient(endpoint, dockerClient)
+			delete(p.hostClients, endpoint)
+		}
+	}
+}
+
+// listHosts lists the containers of the hosts, tagged with the endpoint of their host.
+// A failing host is ignored, without dropping the containers of the other ones: an error is only returned when all of them fail.
+func (p *Provider) listHosts(ctx context.Context, endpoints []string) ([]dockerData, error) {
+	p.pruneHosts(endpoints)
+
+	var containers []dockerData
+	var failures int
+	for _, endpoint := range endpoints {
+		hostContainers, err := p.listHostContainers(ctx, endpoint)
+		if err != nil {
+			log.Warnf("Failed to list the containers of the docker host %s, ignoring it: %v", endpoint, err)
+			failures++
+			continue
+		}
+		containers = append(containers, hostContainers...)
+	}
+
+	if failures > 0 && failures == len(endpoints) {
+		return nil, fmt.Errorf("failed to list the containers of all the docker hosts of %s", p.HostsFile)
+	}
+	return containers, nil
+}
+
+func (p *Provider) listHostContainers(ctx context.Context, endpoint string) ([]dockerData, error) {
+	dockerClient, err := p.getHostClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := p.listCachedContainers(ctx, dockerClient, p.getHostInspectCache(endpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range containers {
+		containers[i].Host = endpoint
+	}
+	return containers, nil
+}
+
+// provideHosts provides the configuration built from the containers of the hosts of the hosts file.
+// In watch mode, the containers events of all the hosts and the changes of the file trigger a refresh.
+func (p *Provider) provideHosts(configurationChan chan<- types.ConfigMessage, pool *safe.Pool) {
+	operation := func() error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		eventsQueue := newEventQueue(p.EventsBufferSize)
+		hostsEvents := newHostsEvents(ctx, eventsQueue, p.listenHostEvents)
+
+		refresh := func() error {
+			endpoints, err := readHostsFile(p.HostsFile)
+			if err != nil {
+				return err
+			}
+
+			containers, err := p.listHosts(ctx, endpoints)
+			if err != nil {
+				return err
+			}
+
+			p.pushConfiguration(configurationChan, p.buildConfiguration(containers))
+			p.setRefreshed()
+
+			if p.Watch {
+				hostsEvents.update(endpoints)
+			}
+			return nil
+		}
+
+		if err := refresh(); err != nil {
+			log.Errorf("Failed to list the containers of the docker hosts, error %s", err)
+			return err
+		}
+
+		if !p.Watch {
+			return nil
+		}
+
+		pool.Go(func(stop chan bool) {
+			<-stop
+			cancel()
+		})
+
+		p.setResync(func() {
+			eventsQueue.push(eventtypes.Message{Action: "resume"})
+		})
+		defer p.setResync(nil)
+
+		if err := p.watchHostsFile(ctx, pool, func() {
+			eventsQueue.push(eventtypes.Message{Action: "reload"})
+		}); err != nil {
+			log.Warnf("Changes in %s will only be applied on the next docker event: %v", p.HostsFile, err)
+		}
+
+		eventsQueue.run(ctx, func() {
+			if err := refresh(); err != nil {
+				p.setDisconnected(err)
+				log.Errorf("Failed to list the containers of the docker hosts, error %s", err)
+			}
+		})
+		return nil
+	}
+
+	notify := func(err error, time time.Duration) {
+		p.setDisconnected(err)
+		log.Errorf("Provider connection error %+v, retrying in %s", err, time)
+	}
+	err := backoff.RetryNotify(safe.OperationWithRecover(operation), job.NewBackOff(backoff.NewExponentialBackOff()), notify)
+	if err != nil {
+		p.setDisconnected(err)
+		log.Errorf("Cannot list the containers of the docker hosts %+v", err)
+	}
+}
+
+// listenHostEvents queues the containers events of a host of the hosts file, until its events stream is closed.
+func (p *Provider) listenHostEvents(ctx context.Context, endpoint string, eventsQueue *eventQueue) error {
+	dockerClient, err := p.getHostClient(endpoint)
+	if err != nil {
+		return err
+	}
+	return p.listenContainersEvents(ctx, dockerClient, eventsQueue)
+}
+
+// watchHostsFile calls refresh on each change of the hosts file.
+// Its directory is watched, as the editors often replace the file instead of writing it.
+func (p *Provider) watchHostsFile(ctx context.Context, pool *safe.Pool, refresh func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating the watcher of %s: %v", p.HostsFile, err)
+	}
+
+	if err = watcher.Add(filepath.Dir(p.HostsFile)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("error watching %s: %v", p.HostsFile, err)
+	}
+
+	pool.Go(func(stop chan bool) {
+		defer watcher.Close()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case event := <-watcher.Events:
+				if filepath.Clean(event.Name) == filepath.Clean(p.HostsFile) {
+					log.Debugf("Hosts file event received %s", event)
+					refresh()
+				}
+			case err := <-watcher.Errors:
+				log.Errorf("Hosts file watcher error: %s", err)
+			}
+		}
+	})
+	return nil
+}
+
+// hostsEvents listens to the events of the hosts of the hosts file, queued for a refresh of all the hosts.
+// It is not safe for concurrent use: it is owned by the refreshes.
+type hostsEvents struct {
+	ctx     context.Context
+	queue   *eventQueue
+	listen  func(ctx context.Context, endpoint string, eventsQueue *eventQueue) error
+	cancels map[string]context.CancelFunc // Stop listening to the events of a host, by endpoint
+}
+
+func newHostsEvents(ctx context.Context, queue *eventQueue, listen func(context.Context, string, *eventQueue) error) *hostsEvents {
+	return &hostsEvents{
+		ctx:     ctx,
+		queue:   queue,
+		listen:  listen,
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// update starts listening to the events of the new hosts, and stops for the removed ones.
+func (h *hostsEvents) update(endpoints []string) {
+	current := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		current[endpoint] = true
+		if _, ok := h.cancels[endpoint]; ok {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(h.ctx)
+		h.cancels[endpoint] = cancel
+
+		endpoint := endpoint
+		safe.Go(func() {
+			h.run(ctx, endpoint)
+		})
+	}
+
+	for endpoint, cancel := range h.cancels {
+		if !current[endpoint] {
+			cancel()
+			delete(h.cancels, endpoint)
+		}
+	}
+}
+
+// run listens to the events of a host again each time its stream is closed, until the context is done.
+func (h *hostsEvents) run(ctx context.Context, endpoint string) {
+	for {
+		err := h.listen(ctx, endpoint, h.queue)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warnf("Events stream of the docker host %s closed, error %v: listening again in %s", endpoint, err, hostEventsRetryDelay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(hostEventsRetryDelay):
+		}
+
+		// The events missed meanwhile are covered by a refresh.
+		h.queue.push(eventtypes.Message{Action: "reconnect"})
+	}
+}
@@ -0,0 +1,404 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/provider/label"
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	dockertypes "github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runningContainer(ops ...func(*dockertypes.ContainerJSON)) dockertypes.ContainerJSON {
+	return containerJSON(append(ops, func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	})...)
+}
+
+func fakeHostClients() map[string]dockerclient.APIClient {
+	return map[string]dockerclient.APIClient{
+		"tcp://10.0.0.1:2375": &fakeContainersClient{
+			containers: map[string]dockertypes.ContainerJSON{
+				"web1": runningContainer(name("web"),
+					ports(nat.PortMap{"80/tcp": {{HostIP: "0.0.0.0", HostPort: "8080"}}}),
+					withNetwork("bridge", ipv4("172.17.0.2"))),
+			},
+		},
+		"tcp://10.0.0.2:2375": &fakeContainersClient{
+			containers: map[string]dockertypes.ContainerJSON{
+				"web2": runningContainer(name("web"),
+					ports(nat.PortMap{"80/tcp": {{HostIP: "0.0.0.0", HostPort: "8081"}}}),
+					withNetwork("bridge", ipv4("172.17.0.2"))),
+			},
+		},
+		"tcp://10.0.0.3:2375": &fakeContainersClient{
+			err: errors.New("connection refused"),
+		},
+	}
+}
+
+func writeHostsFile(t *testing.T, content string) (string, func()) {
+	directory, err := ioutil.TempDir("", "traefik-docker")
+	require.NoError(t, err)
+
+	hostsFile := filepath.Join(directory, "hosts")
+	err = ioutil.WriteFile(hostsFile, []byte(content), 0644)
+	require.NoError(t, err)
+
+	return hostsFile, func() { os.RemoveAll(directory) }
+}
+
+func TestReadHostsFile(t *testing.T) {
+	hostsFile, remove := writeHostsFile(t, `
+# Fleet of the web servers
+tcp://10.0.0.1:2375
+  tcp://10.0.0.2:2375
+
+tcp://10.0.0.1:2375
+not an endpoint
+unix:///var/run/docker.sock
+`)
+	defer remove()
+
+	endpoints, err := readHostsFile(hostsFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tcp://10.0.0.1:2375", "tcp://10.0.0.2:2375", "unix:///var/run/docker.sock"}, endpoints)
+
+	_, err = readHostsFile(hostsFile + ".missing")
+	assert.Error(t, err)
+}
+
+func TestDockerBuildConfigurationHosts(t *testing.T) {
+	clients := fakeHostClients()
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		hostClientFactory: func(endpoint string) (dockerclient.APIClient, error) {
+			return clients[endpoint], nil
+		},
+	}
+
+	// The failing host is ignored, without dropping the other ones.
+	containers, err := provider.listHosts(context.Background(), []string{"tcp://10.0.0.1:2375", "tcp://10.0.0.2:2375", "tcp://10.0.0.3:2375"})
+	require.NoError(t, err)
+	require.Len(t, containers, 2)
+
+	var hosts []string
+	for _, container := range containers {
+		hosts = append(hosts, container.Host)
+	}
+	sort.Strings(hosts)
+	assert.Equal(t, []string{"tcp://10.0.0.1:2375", "tcp://10.0.0.2:2375"}, hosts)
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	// The containers of the remote hosts are reached through their published ports.
+	require.Contains(t, config.Backends, "backend-web")
+	var urls []string
+	for _, server := range config.Backends["backend-web"].Servers {
+		urls = append(urls, server.URL)
+	}
+	sort.Strings(urls)
+	assert.Equal(t, []string{"http://10.0.0.1:8080", "http://10.0.0.2:8081"}, urls)
+}
+
+func TestListHostsInspectCache(t *testing.T) {
+	clients := fakeHostClients()
+
+	provider := &Provider{
+		InspectCache: true,
+		hostClientFactory: func(endpoint string) (dockerclient.APIClient, error) {
+			return clients[endpoint], nil
+		},
+	}
+
+	endpoints := []string{"tcp://10.0.0.1:2375", "tcp://10.0.0.2:2375"}
+	for i := 0; i < 3; i++ {
+		containers, err := provider.listHosts(context.Background(), endpoints)
+		require.NoError(t, err)
+		require.Len(t, containers, 2)
+	}
+
+	// Each host keeps its own cache: its containers are only inspected on the first refresh.
+	for _, endpoint := range endpoints {
+		assert.Len(t, clients[endpoint].(*fakeContainersClient).inspected, 1, endpoint)
+	}
+
+	// The cache and the client of a host removed from the file are dropped.
+	_, err := provider.listHosts(context.Background(), endpoints[:1])
+	require.NoError(t, err)
+	assert.Len(t, provider.hostInspected, 1)
+	assert.Len(t, provider.hostClients, 1)
+	assert.True(t, clients[endpoints[1]].(*fakeContainersClient).closed)
+	assert.False(t, clients[endpoints[0]].(*fakeContainersClient).closed)
+}
+
+type fakeUnreachableClient struct {
+	*fakeContainersClient
+	negotiating chan struct{}
+	release     chan struct{}
+}
+
+// NegotiateAPIVersion waits for the release, as a ping to an unreachable host waits for its timeout.
+func (c *fakeUnreachableClient) NegotiateAPIVersion(ctx context.Context) {
+	close(c.negotiating)
+	<-c.release
+}
+
+func TestGetHostClientUnreachable(t *testing.T) {
+	negotiating := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	provider := &Provider{
+		hostClientFactory: func(endpoint string) (dockerclient.APIClient, error) {
+			if endpoint == "tcp://10.0.0.1:2375" {
+				return &fakeUnreachableClient{fakeContainersClient: &fakeContainersClient{}, negotiating: negotiating, release: release}, nil
+			}
+			return &fakeContainersClient{}, nil
+		},
+	}
+
+	go provider.getHostClient("tcp://10.0.0.1:2375")
+	<-negotiating
+
+	// The client of another host is not held by the negotiation with the unreachable one.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := provider.getHostClient("tcp://10.0.0.2:2375")
+		assert.NoError(t, err)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the client of the other host was held by the unreachable one")
+	}
+}
+
+func TestDockerGetIPAddressHosts(t *testing.T) {
+	hostClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"vpn": runningContainer(name("vpn"), withNetwork("bridge", ipv4("172.17.0.5"))),
+		},
+	}
+
+	provider := &Provider{
+		Endpoint: "tcp://10.0.0.9:2375",
+		hostClientFactory: func(endpoint string) (dockerclient.APIClient, error) {
+			if endpoint != "unix:///var/run/docker.sock" {
+				return nil, errors.New("unknown host " + endpoint)
+			}
+			return hostClient, nil
+		},
+	}
+
+	testCases := []struct {
+		desc      string
+		host      string
+		container dockertypes.ContainerJSON
+		expected  string
+	}{
+		{
+			desc:      "host network of a remote host",
+			host:      "tcp://10.0.0.1:2375",
+			container: runningContainer(name("web"), networkMode("host")),
+			expected:  "10.0.0.1",
+		},
+		{
+			desc:      "host network of a local host",
+			host:      "unix:///var/run/docker.sock",
+			container: runningContainer(name("web"), networkMode("host")),
+			expected:  "127.0.0.1",
+		},
+		{
+			desc:      "container network inspected on the host of the container",
+			host:      "unix:///var/run/docker.sock",
+			container: runningContainer(name("web"), networkMode("container:vpn")),
+			expected:  "172.17.0.5",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dData := parseContainer(test.container)
+			dData.Host = test.host
+
+			assert.Equal(t, test.expected, provider.getIPAddress(dData))
+		})
+	}
+}
+
+func TestDockerGetIPPortHosts(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		container    dockertypes.ContainerJSON
+		expectedIP   string
+		expectedPort string
+		expectedErr  bool
+	}{
+		{
+			desc: "published port",
+			container: runningContainer(name("web"),
+				ports(nat.PortMap{"80/tcp": {{HostIP: "0.0.0.0", HostPort: "8080"}}}),
+				withNetwork("bridge", ipv4("172.17.0.2"))),
+			expectedIP:   "10.0.0.1",
+			expectedPort: "8080",
+		},
+		{
+			desc: "host network with the port label",
+			container: runningContainer(name("web"),
+				labels(map[string]string{label.TraefikPort: "9000"}),
+				networkMode("host")),
+			expectedIP:   "10.0.0.1",
+			expectedPort: "9000",
+		},
+		{
+			desc: "host network with an exposed port",
+			container: runningContainer(name("web"),
+				ports(nat.PortMap{"80/tcp": {}}),
+				networkMode("host")),
+			expectedIP:   "10.0.0.1",
+			expectedPort: "80",
+		},
+		{
+			desc: "bridge network without a published port",
+			container: runningContainer(name("web"),
+				ports(nat.PortMap{"80/tcp": {}}),
+				withNetwork("bridge", ipv4("172.17.0.2"))),
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dData := parseContainer(test.container)
+			segmentProperties := label.ExtractTraefikLabels(dData.Labels)
+			dData.SegmentLabels = segmentProperties[""]
+			dData.Host = "tcp://10.0.0.1:2375"
+
+			provider := &Provider{}
+			ip, port, err := provider.getIPPort(dData)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedIP, ip)
+			assert.Equal(t, test.expectedPort, port)
+		})
+	}
+}
+
+func TestListHostsFailing(t *testing.T) {
+	clients := fakeHostClients()
+
+	provider := &Provider{
+		HostsFile: "hosts",
+		hostClientFactory: func(endpoint string) (dockerclient.APIClient, error) {
+			return clients[endpoint], nil
+		},
+	}
+
+	_, err := provider.listHosts(context.Background(), []string{"tcp://10.0.0.3:2375"})
+	assert.EqualError(t, err, "failed to list the containers of all the docker hosts of hosts")
+
+	containers, err := provider.listHosts(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, containers)
+}
+
+func TestProvideHostsFile(t *testing.T) {
+	hostsFile, remove := writeHostsFile(t, "tcp://10.0.0.1:2375\ntcp://10.0.0.3:2375\n")
+	defer remove()
+
+	clients := fakeHostClients()
+
+	provider := &Provider{
+		Endpoint:         "tcp://unreachable.localhost:2375",
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		HostsFile:        hostsFile,
+		hostClientFactory: func(endpoint string) (dockerclient.APIClient, error) {
+			return clients[endpoint], nil
+		},
+	}
+
+	configurationChan := make(chan types.ConfigMessage, 1)
+	err := provider.Provide(configurationChan, safe.NewPool(context.Background()))
+	require.NoError(t, err)
+
+	select {
+	case message := <-configurationChan:
+		require.NotNil(t, message.Configuration)
+		require.Contains(t, message.Configuration.Backends, "backend-web")
+		require.Len(t, message.Configuration.Backends["backend-web"].Servers, 1)
+		for _, server := range message.Configuration.Backends["backend-web"].Servers {
+			assert.Equal(t, "http://10.0.0.1:8080", server.URL)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no configuration received from the provider")
+	}
+}
+
+func TestProvideHostsFileChange(t *testing.T) {
+	hostsFile, remove := writeHostsFile(t, "tcp://10.0.0.1:2375\n")
+	defer remove()
+
+	clients := fakeHostClients()
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		HostsFile:        hostsFile,
+		hostClientFactory: func(endpoint string) (dockerclient.APIClient, error) {
+			return &fakeWatchClient{fakeContainersClient: clients[endpoint].(*fakeContainersClient)}, nil
+		},
+	}
+	provider.Watch = true
+
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+
+	configurationChan := make(chan types.ConfigMessage, 10)
+	err := provider.Provide(configurationChan, pool)
+	require.NoError(t, err)
+
+	getServersCount := func() int {
+		select {
+		case message := <-configurationChan:
+			require.NotNil(t, message.Configuration)
+			require.Contains(t, message.Configuration.Backends, "backend-web")
+			return len(message.Configuration.Backends["backend-web"].Servers)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no configuration received from the provider")
+		}
+		return 0
+	}
+	assert.Equal(t, 1, getServersCount())
+
+	// The hosts added to the file are discovered.
+	err = ioutil.WriteFile(hostsFile, []byte("tcp://10.0.0.1:2375\ntcp://10.0.0.2:2375\n"), 0644)
+	require.NoError(t, err)
+	assert.Equal(t, 2, getServersCount())
+}
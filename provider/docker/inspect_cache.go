@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	dockertypes "github.com/docker/docker/api/types"
+)
+
+// inspectCache keeps the inspected data of the containers between the refreshes, by container ID.
+type inspectCache struct {
+	mu      sync.Mutex
+	entries map[string]inspectCacheEntry
+}
+
+type inspectCacheEntry struct {
+	key  string
+	data dockerData
+}
+
+// inspectCacheKey gives the fields of the list response telling that a container changed since its inspection:
+// its state, its creation date, its status (which holds the health status) and its networks.
+func inspectCacheKey(container dockertypes.Container) string {
+	parts := []string{container.State, strconv.FormatInt(container.Created, 10), container.Status}
+
+	if container.NetworkSettings != nil {
+		var networks []string
+		for name, settings := range container.NetworkSettings.Networks {
+			if settings != nil {
+				name += "=" + settings.IPAddress
+			}
+			networks = append(networks, name)
+		}
+		sort.Strings(networks)
+		parts = append(parts, networks...)
+	}
+
+	return strings.Join(parts, "|")
+}
+
+func (c *inspectCache) get(container dockertypes.Container) (dockerData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[container.ID]
+	if !ok || entry.key != inspectCacheKey(container) {
+		return dockerData{}, false
+	}
+	return entry.data, true
+}
+
+func (c *inspectCache) set(container dockertypes.Container, dData dockerData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]inspectCacheEntry)
+	}
+	c.entries[container.ID] = inspectCacheEntry{key: inspectCacheKey(container), data: dData}
+}
+
+// prune drops the entries of the containers which are not listed anymore.
+func (c *inspectCache) prune(containers []dockertypes.Container) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	listed := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		listed[container.ID] = true
+	}
+
+	for id := range c.entries {
+		if !listed[id] {
+			delete(c.entries, id)
+		}
+	}
+}
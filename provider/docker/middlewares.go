@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containous/traefik/provider/label"
+)
+
+// Middleware holds the options of a middleware, named as in the typed labels (e.g. headers.customresponseheaders.X-Frame-Options).
+type Middleware map[string]string
+
+// Middlewares holds the middlewares by name
+type Middlewares map[string]Middleware
+
+// MiddlewareNames holds middlewares names
+type MiddlewareNames []string
+
+// Set adds strings elem into the the parser
+// it splits str on , and ;
+func (m *MiddlewareNames) Set(str string) error {
+	fargs := func(c rune) bool {
+		return c == ',' || c == ';'
+	}
+	// get function
+	slice := strings.FieldsFunc(str, fargs)
+	*m = append(*m, slice...)
+	return nil
+}
+
+// Get MiddlewareNames
+func (m *MiddlewareNames) Get() interface{} { return *m }
+
+// String return slice in a string
+func (m *MiddlewareNames) String() string { return fmt.Sprintf("%v", *m) }
+
+// SetValue sets MiddlewareNames into the parser
+func (m *MiddlewareNames) SetValue(val interface{}) {
+	*m = val.(MiddlewareNames)
+}
+
+// checkDefaultMiddlewares checks that the default middlewares are defined, with supported options only.
+func (p *Provider) checkDefaultMiddlewares() error {
+	for _, name := range p.DefaultMiddlewares {
+		middleware, ok := p.Middlewares[name]
+		if !ok {
+			return fmt.Errorf("unknown default middleware %q", name)
+		}
+
+		for option := range middleware {
+			if _, err := getMiddlewareProperty(strings.ToLower(option)); err != nil {
+				return fmt.Errorf("invalid default middleware %q: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// addDefaultMiddlewares adds the options of the default middlewares to the labels of a frontend,
+// unless the container opts out with the traefik.frontend.defaultMiddlewares label.
+// The labels of the container take precedence.
+func (p *Provider) addDefaultMiddlewares(labels map[string]string) map[string]string {
+	if len(p.DefaultMiddlewares) == 0 || !label.GetBoolValue(labels, labelDefaultMiddlewares, true) {
+		return labels
+	}
+
+	result := make(map[string]string, len(labels))
+	// The header names are case insensitive, as the options.
+	keys := make(map[string]bool, len(labels))
+	for key, value := range labels {
+		result[key] = value
+		keys[strings.ToLower(key)] = true
+	}
+
+	for _, name := range p.DefaultMiddlewares {
+		for option, value := range p.Middlewares[name] {
+			property, err := getMiddlewareProperty(strings.ToLower(option))
+			if err != nil {
+				continue
+			}
+
+			key := label.Prefix + property
+			if !keys[strings.ToLower(key)] {
+				result[key] = value
+				keys[strings.ToLower(key)] = true
+			}
+		}
+	}
+	return result
+}
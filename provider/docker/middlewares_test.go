@@ -0,0 +1,147 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/containous/traefik/provider/label"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerBuildConfigurationDefaultMiddlewares(t *testing.T) {
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		Middlewares: Middlewares{
+			"secure": {
+				"headers.customResponseHeaders.X-Frame-Options": "DENY",
+			},
+			"internal": {
+				"ipwhitelist.sourcerange": "10.0.0.0/8",
+			},
+		},
+		DefaultMiddlewares: MiddlewareNames{"secure", "internal"},
+	}
+	require.NoError(t, provider.checkDefaultMiddlewares())
+
+	containers := []dockerData{
+		parseContainer(containerJSON(name("foo"),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.10")))),
+		parseContainer(containerJSON(name("bar"),
+			labels(map[string]string{
+				"traefik.frontend.headers.customResponseHeaders.X-Frame-Options": "SAMEORIGIN",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.11")))),
+		parseContainer(containerJSON(name("public"),
+			labels(map[string]string{
+				labelDefaultMiddlewares: "false",
+			}),
+			ports(nat.PortMap{"80/tcp": {}}),
+			withNetwork("testnet", ipv4("10.10.10.12")))),
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+	require.Len(t, config.Frontends, 3)
+
+	foo := config.Frontends["frontend-Host-foo-docker-localhost-1"]
+	require.NotNil(t, foo)
+	require.NotNil(t, foo.Headers)
+	assert.Equal(t, map[string]string{"X-Frame-Options": "DENY"}, foo.Headers.CustomResponseHeaders)
+	require.NotNil(t, foo.WhiteList)
+	assert.Equal(t, []string{"10.0.0.0/8"}, foo.WhiteList.SourceRange)
+
+	bar := config.Frontends["frontend-Host-bar-docker-localhost-0"]
+	require.NotNil(t, bar)
+	require.NotNil(t, bar.Headers)
+	assert.Equal(t, map[string]string{"X-Frame-Options": "SAMEORIGIN"}, bar.Headers.CustomResponseHeaders)
+	require.NotNil(t, bar.WhiteList)
+	assert.Equal(t, []string{"10.0.0.0/8"}, bar.WhiteList.SourceRange)
+
+	public := config.Frontends["frontend-Host-public-docker-localhost-2"]
+	require.NotNil(t, public)
+	assert.Nil(t, public.Headers)
+	assert.Nil(t, public.WhiteList)
+}
+
+func TestProviderCheckDefaultMiddlewares(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		middlewares        Middlewares
+		defaultMiddlewares MiddlewareNames
+		expectedError      string
+	}{
+		{
+			desc: "without default middlewares",
+			middlewares: Middlewares{
+				"strip": {"stripprefix.prefixes": "/foo"},
+			},
+		},
+		{
+			desc: "defined middlewares",
+			middlewares: Middlewares{
+				"auth":   {"basicauth.users": "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+				"secure": {"Headers.CustomResponseHeaders.X-Frame-Options": "DENY"},
+			},
+			defaultMiddlewares: MiddlewareNames{"auth", "secure"},
+		},
+		{
+			desc: "unknown middleware",
+			middlewares: Middlewares{
+				"auth": {"basicauth.users": "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+			},
+			defaultMiddlewares: MiddlewareNames{"auth", "secure"},
+			expectedError:      `unknown default middleware "secure"`,
+		},
+		{
+			desc: "unsupported option",
+			middlewares: Middlewares{
+				"strip": {"stripprefix.prefixes": "/foo"},
+			},
+			defaultMiddlewares: MiddlewareNames{"strip"},
+			expectedError:      `invalid default middleware "strip": unsupported option stripprefix.prefixes`,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				Middlewares:        test.middlewares,
+				DefaultMiddlewares: test.defaultMiddlewares,
+			}
+
+			err := provider.checkDefaultMiddlewares()
+			if len(test.expectedError) > 0 {
+				assert.EqualError(t, err, test.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestProviderAddDefaultMiddlewares(t *testing.T) {
+	provider := &Provider{
+		Middlewares: Middlewares{
+			"auth": {"basicauth.users": "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+		},
+		DefaultMiddlewares: MiddlewareNames{"auth"},
+	}
+
+	labels := map[string]string{label.TraefikPort: "80"}
+
+	actual := provider.addDefaultMiddlewares(labels)
+
+	expected := map[string]string{
+		label.TraefikPort:                   "80",
+		label.TraefikFrontendAuthBasicUsers: "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+	}
+	assert.Equal(t, expected, actual)
+	assert.Len(t, labels, 1)
+}
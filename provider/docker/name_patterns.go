@@ -0,0 +1,46 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ryanuber/go-glob"
+)
+
+// NamePatterns holds glob patterns matching containers names or images
+type NamePatterns []string
+
+// Set adds strings elem into the the parser
+// it splits str on , and ;
+func (n *NamePatterns) Set(str string) error {
+	fargs := func(c rune) bool {
+		return c == ',' || c == ';'
+	}
+	// get function
+	slice := strings.FieldsFunc(str, fargs)
+	*n = append(*n, slice...)
+	return nil
+}
+
+// Get NamePatterns
+func (n *NamePatterns) Get() interface{} { return *n }
+
+// String return slice in a string
+func (n *NamePatterns) String() string { return fmt.Sprintf("%v", *n) }
+
+// SetValue sets NamePatterns into the parser
+func (n *NamePatterns) SetValue(val interface{}) {
+	*n = val.(NamePatterns)
+}
+
+// Match returns the first pattern matching one of the values
+func (n NamePatterns) Match(values ...string) (string, bool) {
+	for _, pattern := range n {
+		for _, value := range values {
+			if len(value) > 0 && glob.Glob(pattern, value) {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
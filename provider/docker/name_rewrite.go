@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/label"
+)
+
+// NameRewrite rewrites the service names of the containers used in the backend names, e.g. to drop a compose prefix.
+type NameRewrite struct {
+	Regex       string `description:"Regular expression matching the service names to rewrite" export:"true"`
+	Replacement string `description:"Replacement of the matches, which can reference the groups of the regex (e.g. $1)" export:"true"`
+	regexp      *regexp.Regexp
+}
+
+func (n *NameRewrite) compile() error {
+	if n == nil || len(n.Regex) == 0 {
+		return nil
+	}
+
+	exp, err := regexp.Compile(n.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid name rewrite regex %q: %v", n.Regex, err)
+	}
+	n.regexp = exp
+	return nil
+}
+
+func (n *NameRewrite) rewrite(name string) string {
+	if n == nil || n.regexp == nil {
+		return name
+	}
+	return n.regexp.ReplaceAllString(name, n.Replacement)
+}
+
+// rewriteServiceNames sets the rewritten service name of the containers, given by the compose service name
+// then by the name rewrite.
+// It warns when different services get the same name, as they then share their backend.
+func (p *Provider) rewriteServiceNames(containers []dockerData) []dockerData {
+	if !p.ComposeServiceNames && (p.NameRewrite == nil || p.NameRewrite.regexp == nil) {
+		return containers
+	}
+
+	originalNames := make(map[string]string)
+	warned := make(map[string]bool)
+
+	var result []dockerData
+	for _, container := range containers {
+		serviceName := getServiceName(container)
+		rewritten := p.NameRewrite.rewrite(p.getComposeServiceName(container, serviceName))
+		if len(rewritten) == 0 {
+			log.Warnf("The name rewrite of the service %s gives an empty name, keeping it", serviceName)
+			rewritten = serviceName
+		}
+
+		if original, exists := originalNames[rewritten]; !exists {
+			originalNames[rewritten] = serviceName
+		} else if original != serviceName && !warned[rewritten] {
+			log.Warnf("The services %s and %s are both rewritten to %s: they share the same backend", original, serviceName, rewritten)
+			warned[rewritten] = true
+		}
+
+		container.RewrittenName = rewritten
+		result = append(result, container)
+	}
+	return result
+}
+
+// getComposeServiceName returns the compose service name of the container when the ComposeServiceNames option is set,
+// else the given name: the containers not managed by compose keep it.
+func (p *Provider) getComposeServiceName(container dockerData, name string) string {
+	if !p.ComposeServiceNames {
+		return name
+	}
+
+	if service := label.GetStringValue(container.Labels, labelDockerComposeService, ""); len(service) > 0 {
+		return service
+	}
+	return name
+}
@@ -0,0 +1,32 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NetworkDrivers holds docker network drivers names
+type NetworkDrivers []string
+
+// Set adds strings elem into the the parser
+// it splits str on , and ;
+func (n *NetworkDrivers) Set(str string) error {
+	fargs := func(c rune) bool {
+		return c == ',' || c == ';'
+	}
+	// get function
+	slice := strings.FieldsFunc(str, fargs)
+	*n = append(*n, slice...)
+	return nil
+}
+
+// Get NetworkDrivers
+func (n *NetworkDrivers) Get() interface{} { return *n }
+
+// String return slice in a string
+func (n *NetworkDrivers) String() string { return fmt.Sprintf("%v", *n) }
+
+// SetValue sets NetworkDrivers into the parser
+func (n *NetworkDrivers) SetValue(val interface{}) {
+	*n = val.(NetworkDrivers)
+}
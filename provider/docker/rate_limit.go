@@ -0,0 +1,99 @@
+package docker
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+const (
+	rateLimitMaxRetries = 3
+	rateLimitBaseDelay  = 500 * time.Millisecond
+	rateLimitMaxDelay   = 10 * time.Second
+)
+
+// rateLimitTransport retries the requests rejected by a rate limit (429 Too Many Requests) of the daemon,
+// or of an API gateway in front of it, instead of failing the whole refresh.
+// It waits for the delay given by the Retry-After header, or else for an exponential backoff.
+type rateLimitTransport struct {
+	transport  http.RoundTripper
+	maxRetries int
+	baseDelay  time.Duration
+}
+
+func newRateLimitTransport(transport http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		transport:  transport,
+		maxRetries: rateLimitMaxRetries,
+		baseDelay:  rateLimitBaseDelay,
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.transport.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= t.maxRetries {
+			return resp, err
+		}
+
+		// The requests with a body can only be sent again when the body can be read again.
+		retryReq := req
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			retryReq = req.WithContext(req.Context())
+			retryReq.Body = body
+		}
+
+		delay := getRetryAfter(resp.Header.Get("Retry-After"), t.baseDelay<<uint(attempt))
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		log.Debugf("Docker API rate limit reached for %s %s, retrying in %s", req.Method, req.URL.Path, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		req = retryReq
+	}
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport, as the docker library only closes
+// the ones of an *http.Transport.
+func (t *rateLimitTransport) CloseIdleConnections() {
+	if transport, ok := t.transport.(interface{ CloseIdleConnections() }); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// getRetryAfter returns the delay given by the value of a Retry-After header, in seconds or as an HTTP date,
+// bounded by rateLimitMaxDelay, or the default delay when the value is missing or invalid.
+func getRetryAfter(value string, defaultDelay time.Duration) time.Duration {
+	delay := defaultDelay
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+	}
+
+	if delay > rateLimitMaxDelay {
+		return rateLimitMaxDelay
+	}
+	return delay
+}
@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListContainersRateLimit(t *testing.T) {
+	inspected := containerJSON(
+		name("web"),
+		func(c *dockertypes.ContainerJSON) {
+			c.ID = "web-id"
+			c.State = &dockertypes.ContainerState{Running: true}
+		},
+		ports(nat.PortMap{"80/tcp": {}}),
+		withNetwork("testnet", ipv4("10.10.10.10")))
+
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		calls[req.URL.Path]++
+		first := calls[req.URL.Path] == 1
+		mu.Unlock()
+
+		// The first call of each route is rejected by the rate limit.
+		if first {
+			rw.Header().Set("Retry-After", "0")
+			rw.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		var body interface{}
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/containers/json"):
+			body = []dockertypes.Container{{ID: "web-id", Names: []string{"/web"}, State: "running"}}
+		case strings.HasSuffix(req.URL.Path, "/containers/web-id/json"):
+			body = inspected
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(rw).Encode(body))
+	}))
+	defer server.Close()
+
+	provider := &Provider{
+		Endpoint: "tcp://" + server.Listener.Addr().String(),
+	}
+
+	dockerClient, err := provider.createClient()
+	require.NoError(t, err)
+
+	containers, err := provider.listContainers(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Equal(t, "web", containers[0].Name)
+
+	for path, count := range calls {
+		assert.Equal(t, 2, count, path)
+	}
+}
+
+func TestRateLimitTransportMaxRetries(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	transport := &rateLimitTransport{
+		transport:  http.DefaultTransport,
+		maxRetries: 2,
+		baseDelay:  time.Millisecond,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, server.URL, nil)
+	req.RequestURI = ""
+
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, 3, calls)
+}
+
+type fakeIdleTransport struct {
+	http.RoundTripper
+	closed bool
+}
+
+func (t *fakeIdleTransport) CloseIdleConnections() {
+	t.closed = true
+}
+
+func TestRateLimitTransportCloseIdleConnections(t *testing.T) {
+	transport := &fakeIdleTransport{}
+
+	dockerClient := &endpointClient{transport: newRateLimitTransport(transport)}
+	closeHostClient("tcp://10.0.0.1:2375", dockerClient)
+
+	assert.True(t, transport.closed)
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		value    string
+		expected time.Duration
+	}{
+		{
+			desc:     "missing",
+			expected: time.Second,
+		},
+		{
+			desc:     "seconds",
+			value:    "2",
+			expected: 2 * time.Second,
+		},
+		{
+			desc:     "bounded",
+			value:    "3600",
+			expected: rateLimitMaxDelay,
+		},
+		{
+			desc:     "past date",
+			value:    "Wed, 21 Oct 2015 07:28:00 GMT",
+			expected: 0,
+		},
+		{
+			desc:     "invalid",
+			value:    "soon",
+			expected: time.Second,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, getRetryAfter(test.value, time.Second))
+		})
+	}
+}
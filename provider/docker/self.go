@@ -0,0 +1,55 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// cgroupFile gives the cgroups of the process, whose paths hold the ID of the container it runs in.
+var cgroupFile = "/proc/self/cgroup"
+
+var (
+	containerIDRegexp      = regexp.MustCompile(`[0-9a-f]{64}`)
+	shortContainerIDRegexp = regexp.MustCompile(`^[0-9a-f]{12}$`)
+)
+
+// getSelfContainerID returns the ID of the container running Traefik, read from its cgroups,
+// or else its short ID given by the default hostname of the containers. It is empty if none is found.
+func getSelfContainerID() string {
+	if data, err := ioutil.ReadFile(cgroupFile); err == nil {
+		if id := containerIDRegexp.FindString(string(data)); len(id) > 0 {
+			return id
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err == nil && shortContainerIDRegexp.MatchString(hostname) {
+		return hostname
+	}
+	return ""
+}
+
+// isSelf tells if the container is the one running Traefik.
+// Without the ID of its own container, any container running a Traefik image is considered to be it.
+func (p *Provider) isSelf(container dockerData) bool {
+	if len(p.selfContainerID) > 0 {
+		return len(container.ID) > 0 && strings.HasPrefix(container.ID, p.selfContainerID)
+	}
+	return isTraefikImage(container.Image)
+}
+
+// isTraefikImage tells if the image is a Traefik one, e.g. traefik:v1.6 or containous/traefik@sha256:...
+func isTraefikImage(image string) bool {
+	if index := strings.Index(image, "@"); index >= 0 {
+		image = image[:index]
+	}
+
+	repository := path.Base(image)
+	if index := strings.Index(repository, ":"); index >= 0 {
+		repository = repository[:index]
+	}
+	return repository == "traefik"
+}
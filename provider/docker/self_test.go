@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containous/traefik/provider/label"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerSelfExclude(t *testing.T) {
+	const selfID = "0123456789ab0123456789ab0123456789ab0123456789ab0123456789abcdef"
+
+	testCases := []struct {
+		desc        string
+		selfExclude bool
+		selfID      string
+		container   dockerData
+		expected    bool
+	}{
+		{
+			desc:        "traefik image without the own container ID",
+			selfExclude: true,
+			container:   dockerData{Image: "traefik:v1.6"},
+			expected:    false,
+		},
+		{
+			desc:        "traefik image from another repository without the own container ID",
+			selfExclude: true,
+			container:   dockerData{Image: "registry.localhost:5000/containous/traefik@sha256:abcd"},
+			expected:    false,
+		},
+		{
+			desc:        "own container",
+			selfExclude: true,
+			selfID:      selfID,
+			container:   dockerData{ID: selfID, Image: "custom-proxy"},
+			expected:    false,
+		},
+		{
+			desc:        "other traefik instance with the own container ID",
+			selfExclude: true,
+			selfID:      selfID,
+			container:   dockerData{ID: "fedcba", Image: "traefik:v1.6"},
+			expected:    true,
+		},
+		{
+			desc:        "traefik image explicitly enabled",
+			selfExclude: true,
+			container: dockerData{
+				Image:  "traefik:v1.6",
+				Labels: map[string]string{label.TraefikEnable: "true"},
+			},
+			expected: true,
+		},
+		{
+			desc:        "other image",
+			selfExclude: true,
+			container:   dockerData{ID: "fedcba", Image: "traefik-dashboard-exporter"},
+			expected:    true,
+		},
+		{
+			desc:      "traefik image without the option",
+			container: dockerData{Image: "traefik:v1.6"},
+			expected:  true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{
+				Domain:           "docker.localhost",
+				ExposedByDefault: true,
+				SelfExclude:      test.selfExclude,
+				selfContainerID:  test.selfID,
+			}
+
+			container := test.container
+			container.Name = "test"
+			if container.Labels == nil {
+				container.Labels = map[string]string{}
+			}
+			container.Labels[label.TraefikPort] = "80"
+
+			actual := provider.containerFilter(container)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestGetSelfContainerID(t *testing.T) {
+	directory, err := ioutil.TempDir("", "traefik-docker")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	content := "12:pids:/docker/0123456789ab0123456789ab0123456789ab0123456789ab0123456789abcdef\n1:name=systemd:/docker/0123456789ab0123456789ab0123456789ab0123456789ab0123456789abcdef\n"
+	path := filepath.Join(directory, "cgroup")
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	require.NoError(t, err)
+
+	defaultCgroupFile := cgroupFile
+	cgroupFile = path
+	defer func() { cgroupFile = defaultCgroupFile }()
+
+	assert.Equal(t, "0123456789ab0123456789ab0123456789ab0123456789ab0123456789abcdef", getSelfContainerID())
+}
@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"context"
+	"sort"
+)
+
+// ContainerSnapshot is a read-only view of a container (or a swarm service) discovered by the provider.
+type ContainerSnapshot struct {
+	Name             string                     `json:"name"`
+	ServiceName      string                     `json:"serviceName,omitempty"`
+	Labels           map[string]string          `json:"labels,omitempty"`
+	NetworkMode      string                     `json:"networkMode,omitempty"`
+	Ports            []string                   `json:"ports,omitempty"`
+	Networks         map[string]NetworkSnapshot `json:"networks,omitempty"`
+	Health           string                     `json:"health,omitempty"`
+	RestartCount     int                        `json:"restartCount,omitempty"`
+	NodeRole         string                     `json:"nodeRole,omitempty"`
+	NodeAvailability string                     `json:"nodeAvailability,omitempty"`
+	Exposed          bool                       `json:"exposed"`
+	FilterReason     string                     `json:"filterReason,omitempty"`
+}
+
+// NetworkSnapshot is a read-only view of a network of a discovered container.
+type NetworkSnapshot struct {
+	ID   string `json:"id,omitempty"`
+	Addr string `json:"addr,omitempty"`
+}
+
+// nodesDataKey is the key of the context value asking for the data of the nodes of the swarm tasks, even when no option needs it.
+type nodesDataKey struct{}
+
+// Snapshot returns what the provider currently discovers, without building nor pushing any configuration.
+// It uses its own client and can be called while the provider is watching.
+func (p *Provider) Snapshot(ctx context.Context) ([]ContainerSnapshot, error) {
+	dockerClient, err := p.getClient()
+	if err != nil {
+		return nil, err
+	}
+	p.negotiateAPIVersion(ctx, dockerClient)
+
+	var dockerDataList []dockerData
+	if p.SwarmMode {
+		dockerDataList, err = p.listServices(context.WithValue(ctx, nodesDataKey{}, true), dockerClient)
+	} else {
+		dockerDataList, err = p.listContainers(ctx, dockerClient)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.snapshot(dockerDataList), nil
+}
+
+func (p *Provider) snapshot(dockerDataList []dockerData) []ContainerSnapshot {
+	var snapshots []ContainerSnapshot
+	for _, container := range dockerDataList {
+		snapshot := ContainerSnapshot{
+			Name:             container.Name,
+			ServiceName:      container.ServiceName,
+			Labels:           container.Labels,
+			NetworkMode:      string(container.NetworkSettings.NetworkMode),
+			Health:           container.Health,
+			RestartCount:     container.RestartCount,
+			NodeRole:         container.NodeRole,
+			NodeAvailability: container.NodeAvailability,
+		}
+		snapshot.FilterReason, _ = p.getFilterReason(container)
+		snapshot.Exposed = len(snapshot.FilterReason) == 0
+
+		for port := range container.NetworkSettings.Ports {
+			snapshot.Ports = append(snapshot.Ports, string(port))
+		}
+		sort.Strings(snapshot.Ports)
+
+		for name, network := range container.NetworkSettings.Networks {
+			if snapshot.Networks == nil {
+				snapshot.Networks = make(map[string]NetworkSnapshot)
+			}
+			snapshot.Networks[name] = NetworkSnapshot{
+				ID:   network.ID,
+				Addr: network.Addr,
+			}
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots
+}
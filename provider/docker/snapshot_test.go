@@ -0,0 +1,72 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/containous/traefik/provider/label"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+	stopped := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: false}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"exposed": containerJSON(name("exposed"), running,
+				ports(nat.PortMap{"80/tcp": {}}),
+				withNetwork("testnet", ipv4("10.10.10.10"))),
+			"disabled": containerJSON(name("disabled"), running,
+				labels(map[string]string{label.TraefikEnable: "false"}),
+				ports(nat.PortMap{"80/tcp": {}})),
+			"stopped": containerJSON(name("stopped"), stopped),
+		},
+	}
+
+	provider := &Provider{
+		ExposedByDefault: true,
+		Domain:           "docker.localhost",
+	}
+
+	dockerDataList, err := provider.listContainers(context.Background(), dockerClient)
+	require.NoError(t, err)
+
+	snapshots := provider.snapshot(dockerDataList)
+	require.Len(t, snapshots, len(dockerDataList))
+
+	actual := make(map[string]ContainerSnapshot)
+	for _, snapshot := range snapshots {
+		actual[snapshot.Name] = snapshot
+	}
+
+	expected := map[string]ContainerSnapshot{
+		"exposed": {
+			Name:        "exposed",
+			ServiceName: "exposed",
+			Ports:       []string{"80/tcp"},
+			Networks:    map[string]NetworkSnapshot{"testnet": {Addr: "10.10.10.10"}},
+			Exposed:     true,
+		},
+		"disabled": {
+			Name:         "disabled",
+			ServiceName:  "disabled",
+			Labels:       map[string]string{label.TraefikEnable: "false"},
+			Ports:        []string{"80/tcp"},
+			FilterReason: "disabled container",
+		},
+	}
+	assert.Equal(t, expected, actual)
+
+	content, err := json.Marshal(snapshots)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"filterReason":"disabled container"`)
+}
@@ -0,0 +1,71 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ConnectionState holds the connectivity of the provider to the Docker daemon.
+type ConnectionState struct {
+	Connected   bool
+	LastError   error
+	LastRefresh time.Time
+}
+
+// ConnectionState returns the current connectivity of the provider.
+// It can be called at any time, e.g. by a health endpoint, without blocking the provider.
+func (p *Provider) ConnectionState() ConnectionState {
+	if state, ok := p.state.Load().(ConnectionState); ok {
+		return state
+	}
+	return ConnectionState{}
+}
+
+func (p *Provider) setRefreshed() {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	state := p.ConnectionState()
+	state.Connected = true
+	state.LastRefresh = time.Now()
+	p.state.Store(state)
+}
+
+func (p *Provider) setDisconnected(err error) {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	state := p.ConnectionState()
+	state.Connected = false
+	state.LastError = err
+	p.state.Store(state)
+}
+
+// Ping checks that the Docker daemon can be reached, e.g. for a readiness probe.
+// It uses its own client, kept between the calls and recreated after a failure, so that it never disturbs the watch.
+func (p *Provider) Ping(ctx context.Context) error {
+	p.pingMu.Lock()
+	defer p.pingMu.Unlock()
+
+	if p.pingClient == nil {
+		dockerClient, err := p.getClient()
+		if err != nil {
+			return err
+		}
+		p.pingClient = dockerClient
+	}
+
+	pingCtx, cancel := p.apiContext(ctx)
+	defer cancel()
+
+	if _, err := p.pingClient.Ping(pingCtx); err != nil {
+		// The client may hold a broken connection, e.g. when the daemon restarted.
+		if closer, ok := p.pingClient.(io.Closer); ok {
+			closer.Close()
+		}
+		p.pingClient = nil
+		return err
+	}
+	return nil
+}
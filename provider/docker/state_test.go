@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/containous/traefik/safe"
+	"github.com/containous/traefik/types"
+	dockertypes "github.com/docker/docker/api/types"
+	eventtypes "github.com/docker/docker/api/types/events"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWatchClient struct {
+	*fakeContainersClient
+	errc chan error
+}
+
+func (c *fakeWatchClient) Events(ctx context.Context, options dockertypes.EventsOptions) (<-chan eventtypes.Message, <-chan error) {
+	errc := make(chan error, 1)
+	go func() {
+		select {
+		case err := <-c.errc:
+			errc <- err
+		case <-ctx.Done():
+			errc <- ctx.Err()
+		}
+	}()
+	return make(chan eventtypes.Message), errc
+}
+
+func TestConnectionState(t *testing.T) {
+	dockerClient := &fakeWatchClient{
+		fakeContainersClient: &fakeContainersClient{
+			containers: map[string]dockertypes.ContainerJSON{
+				"test": containerJSON(
+					name("test"),
+					func(c *dockertypes.ContainerJSON) {
+						c.State = &dockertypes.ContainerState{Running: true}
+					},
+					ports(nat.PortMap{"80/tcp": {}}),
+					withNetwork("testnet", ipv4("10.10.10.10"))),
+			},
+		},
+		errc: make(chan error),
+	}
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+		ClientFactory: func() (dockerclient.APIClient, error) {
+			return dockerClient, nil
+		},
+	}
+	provider.Watch = true
+
+	assert.Equal(t, ConnectionState{}, provider.ConnectionState())
+
+	configurationChan := make(chan types.ConfigMessage)
+	go func() {
+		for range configurationChan {
+		}
+	}()
+
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+
+	err := provider.Provide(configurationChan, pool)
+	require.NoError(t, err)
+
+	waitState := func(connected bool) ConnectionState {
+		timeout := time.After(5 * time.Second)
+		for {
+			state := provider.ConnectionState()
+			if state.Connected == connected {
+				return state
+			}
+
+			select {
+			case <-timeout:
+				t.Fatalf("the provider connected state never became %v", connected)
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}
+
+	state := waitState(true)
+	assert.NoError(t, state.LastError)
+	assert.False(t, state.LastRefresh.IsZero())
+
+	streamErr := errors.New("events stream closed")
+	dockerClient.errc <- streamErr
+
+	state = waitState(false)
+	assert.Equal(t, streamErr, state.LastError)
+
+	state = waitState(true)
+	assert.Equal(t, streamErr, state.LastError, "the last error is kept after the reconnection")
+}
+
+type fakePingClient struct {
+	dockerclient.APIClient
+	err error
+}
+
+func (c *fakePingClient) Ping(ctx context.Context) (dockertypes.Ping, error) {
+	return dockertypes.Ping{}, c.err
+}
+
+func TestPing(t *testing.T) {
+	pingClient := &fakePingClient{}
+
+	var created int
+	provider := &Provider{
+		ClientFactory: func() (dockerclient.APIClient, error) {
+			created++
+			return pingClient, nil
+		},
+	}
+
+	require.NoError(t, provider.Ping(context.Background()))
+	require.NoError(t, provider.Ping(context.Background()))
+	assert.Equal(t, 1, created, "the client must be kept between the pings")
+
+	pingClient.err = errors.New("daemon unreachable")
+	assert.EqualError(t, provider.Ping(context.Background()), "daemon unreachable")
+
+	pingClient.err = nil
+	require.NoError(t, provider.Ping(context.Background()))
+	assert.Equal(t, 2, created, "the client must be recreated after a failure")
+}
+
+func TestPingClientError(t *testing.T) {
+	provider := &Provider{
+		ClientFactory: func() (dockerclient.APIClient, error) {
+			return nil, errors.New("invalid endpoint")
+		},
+	}
+
+	assert.EqualError(t, provider.Ping(context.Background()), "invalid endpoint")
+}
@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containous/traefik/log"
+)
+
+// RefreshStats holds the duration of a listing of the containers or services, and the number of API calls it made.
+type RefreshStats struct {
+	Mode     string         // containers or services
+	Duration time.Duration  // Wall time of the listing
+	APICalls map[string]int // Number of calls by API endpoint, e.g. ContainerInspect
+}
+
+// String gives the number of calls by endpoint, sorted by endpoint name.
+func (s RefreshStats) String() string {
+	var endpoints []string
+	var total int
+	for endpoint, count := range s.APICalls {
+		endpoints = append(endpoints, fmt.Sprintf("%s=%d", endpoint, count))
+		total += count
+	}
+	sort.Strings(endpoints)
+
+	return fmt.Sprintf("%d API calls (%s)", total, strings.Join(endpoints, ", "))
+}
+
+// refreshRecorder counts the API calls of a listing.
+type refreshRecorder struct {
+	mu    sync.Mutex
+	start time.Time
+	stats RefreshStats
+}
+
+type refreshRecorderKey struct{}
+
+// withRefreshRecorder returns a context recording the API calls made with it, until reportRefreshStats is called.
+func withRefreshRecorder(ctx context.Context, mode string) (context.Context, *refreshRecorder) {
+	recorder := &refreshRecorder{
+		start: time.Now(),
+		stats: RefreshStats{Mode: mode, APICalls: make(map[string]int)},
+	}
+	return context.WithValue(ctx, refreshRecorderKey{}, recorder), recorder
+}
+
+// countAPICall records an API call made with the context, if it records them.
+func countAPICall(ctx context.Context, endpoint string) {
+	recorder, ok := ctx.Value(refreshRecorderKey{}).(*refreshRecorder)
+	if !ok {
+		return
+	}
+
+	recorder.mu.Lock()
+	recorder.stats.APICalls[endpoint]++
+	recorder.mu.Unlock()
+}
+
+// reportRefreshStats logs the stats of the listing, and gives them to the refresh hook when set.
+func (p *Provider) reportRefreshStats(recorder *refreshRecorder) {
+	recorder.mu.Lock()
+	stats := RefreshStats{
+		Mode:     recorder.stats.Mode,
+		Duration: time.Since(recorder.start),
+		APICalls: make(map[string]int, len(recorder.stats.APICalls)),
+	}
+	for endpoint, count := range recorder.stats.APICalls {
+		stats.APICalls[endpoint] = count
+	}
+	recorder.mu.Unlock()
+
+	log.Debugf("Listed the %s in %s: %s", stats.Mode, stats.Duration, stats)
+
+	if p.RefreshHook != nil {
+		p.RefreshHook(stats)
+	}
+}
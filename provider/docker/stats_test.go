@@ -0,0 +1,146 @@
+package docker
+
+import (
+	"context"
+	"testing"
+
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListContainersRefreshStats(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+
+	dockerClient := &fakeContainersClient{
+		containers: map[string]dockertypes.ContainerJSON{
+			"first":  containerJSON(name("first"), running),
+			"second": containerJSON(name("second"), running),
+		},
+	}
+
+	var stats []RefreshStats
+	provider := &Provider{
+		RefreshHook: func(refreshStats RefreshStats) {
+			stats = append(stats, refreshStats)
+		},
+	}
+
+	_, err := provider.listContainers(context.Background(), dockerClient)
+	require.NoError(t, err)
+
+	require.Len(t, stats, 1)
+	assert.Equal(t, "containers", stats[0].Mode)
+	assert.Equal(t, map[string]int{
+		"ContainerList":    1,
+		"ContainerInspect": len(dockerClient.inspected),
+	}, stats[0].APICalls)
+	assert.Equal(t, 2, stats[0].APICalls["ContainerInspect"])
+	assert.True(t, stats[0].Duration > 0)
+}
+
+func TestListContainersSkipInspectAPICalls(t *testing.T) {
+	running := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true}
+	}
+	healthy := func(c *dockertypes.ContainerJSON) {
+		c.State = &dockertypes.ContainerState{Running: true, Health: &dockertypes.Health{Status: "healthy"}}
+	}
+	httpPorts := ports(nat.PortMap{"80/tcp": {}})
+
+	containers := map[string]dockertypes.ContainerJSON{
+		"web1":   containerJSON(name("web1"), running, httpPorts, withNetwork("testnet", ipv4("10.0.0.1"))),
+		"web2":   containerJSON(name("web2"), running, httpPorts, withNetwork("testnet", ipv4("10.0.0.2"))),
+		"web3":   containerJSON(name("web3"), running, httpPorts, withNetwork("testnet", ipv4("10.0.0.3"))),
+		"health": containerJSON(name("health"), healthy, httpPorts, withNetwork("testnet", ipv4("10.0.0.4"))),
+	}
+
+	testCases := []struct {
+		desc             string
+		skipInspect      bool
+		expectedInspects int
+	}{
+		{
+			desc:             "all the containers inspected",
+			expectedInspects: 4,
+		},
+		{
+			desc:             "only the container with a health check inspected",
+			skipInspect:      true,
+			expectedInspects: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var stats []RefreshStats
+			provider := &Provider{
+				SkipInspect: test.skipInspect,
+				RefreshHook: func(refreshStats RefreshStats) {
+					stats = append(stats, refreshStats)
+				},
+			}
+
+			dockerClient := &fakeContainersClient{containers: containers}
+			dockerDataList, err := provider.listContainers(context.Background(), dockerClient)
+			require.NoError(t, err)
+			assert.Len(t, dockerDataList, 4)
+
+			require.Len(t, stats, 1)
+			assert.Equal(t, map[string]int{
+				"ContainerList":    1,
+				"ContainerInspect": test.expectedInspects,
+			}, stats[0].APICalls)
+		})
+	}
+}
+
+func TestListServicesRefreshStats(t *testing.T) {
+	dockerClient := &fakeServicesClient{
+		dockerVersion: "1.30",
+		services: []swarm.Service{
+			swarmService(serviceName("service1"), withEndpointSpec(modeDNSSR)),
+			swarmService(serviceName("service2"), withEndpointSpec(modeDNSSR)),
+		},
+		tasks: []swarm.Task{
+			swarmTask("task1",
+				taskSlot(1),
+				taskStatus(taskState(swarm.TaskStateRunning)),
+				taskNetworkAttachment("1", "network_name", "overlay", []string{"10.0.0.1/24"})),
+		},
+		networks: []dockertypes.NetworkResource{
+			{Name: "network_name", ID: "1", Scope: "swarm", Driver: "overlay"},
+		},
+	}
+
+	var stats []RefreshStats
+	provider := &Provider{
+		SwarmMode:           true,
+		ExcludeDrainedNodes: true,
+		RefreshHook: func(refreshStats RefreshStats) {
+			stats = append(stats, refreshStats)
+		},
+	}
+
+	_, err := provider.listServices(context.Background(), dockerClient)
+	require.NoError(t, err)
+
+	require.Len(t, stats, 1)
+	assert.Equal(t, "services", stats[0].Mode)
+	// The networks are listed by scope and by driver, the tasks by service and the nodes once.
+	assert.Equal(t, map[string]int{
+		"ServerVersion": 1,
+		"ServiceList":   1,
+		"NetworkList":   2,
+		"TaskList":      2,
+		"NodeList":      1,
+	}, stats[0].APICalls)
+	assert.Equal(t, "7 API calls (NetworkList=2, NodeList=1, ServerVersion=1, ServiceList=1, TaskList=2)", stats[0].String())
+}
@@ -2,16 +2,21 @@ package docker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/containous/traefik/provider/label"
+	"github.com/containous/traefik/types"
 	"github.com/davecgh/go-spew/spew"
 	docker "github.com/docker/docker/api/types"
 	dockertypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type fakeTasksClient struct {
@@ -76,15 +81,209 @@ func TestListTasks(t *testing.T) {
 				},
 			},
 		},
+		{
+			service: swarmService(
+				serviceName("container"),
+				serviceImage("foo:v1"),
+				serviceUpdateState(swarm.UpdateStateRollbackStarted),
+			),
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskSlot(1),
+					taskImage("foo:v1"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+				swarmTask("id2",
+					taskSlot(2),
+					taskImage("foo:v2"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.2"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+				swarmTask("id3",
+					taskSlot(3),
+					taskImage("foo:v1"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.3"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			isGlobalSVC: false,
+			expectedTasks: []string{
+				"container.1",
+				"container.3",
+			},
+			networks: map[string]*docker.NetworkResource{
+				"1": {
+					Name: "foo",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				serviceName("container"),
+				serviceImage("foo:v1"),
+				serviceUpdateState(swarm.UpdateStateRollbackStarted),
+			),
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskSlot(1),
+					taskImage("foo:v2"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			isGlobalSVC: false,
+			expectedTasks: []string{
+				"container.1",
+			},
+			networks: map[string]*docker.NetworkResource{
+				"1": {
+					Name: "foo",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				serviceName("container"),
+				serviceImage("foo:v1"),
+				serviceUpdateState(swarm.UpdateStateCompleted),
+			),
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskSlot(1),
+					taskImage("foo:v1"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+				swarmTask("id2",
+					taskSlot(2),
+					taskImage("foo:v2"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.2"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			isGlobalSVC: false,
+			expectedTasks: []string{
+				"container.1",
+				"container.2",
+			},
+			networks: map[string]*docker.NetworkResource{
+				"1": {
+					Name: "foo",
+				},
+			},
+		},
+		{
+			service: swarmService(serviceName("container")),
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskSlot(1),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+				swarmTask("id2",
+					taskSlot(2),
+					taskDesiredState(swarm.TaskStateShutdown),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.2"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			isGlobalSVC: false,
+			expectedTasks: []string{
+				"container.1",
+			},
+			networks: map[string]*docker.NetworkResource{
+				"1": {
+					Name: "foo",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				serviceName("container"),
+				serviceImage("foo:v2"),
+				serviceUpdateState(swarm.UpdateStateUpdating),
+				serviceUpdateOrder(swarm.UpdateOrderStopFirst),
+			),
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskSlot(1),
+					taskImage("foo:v1"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+				swarmTask("id2",
+					taskSlot(2),
+					taskImage("foo:v1"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.2"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+				swarmTask("id3",
+					taskSlot(2),
+					taskImage("foo:v2"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.3"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			isGlobalSVC: false,
+			expectedTasks: []string{
+				"container.2",
+			},
+			networks: map[string]*docker.NetworkResource{
+				"1": {
+					Name: "foo",
+				},
+			},
+		},
+		{
+			service: swarmService(
+				serviceName("container"),
+				serviceImage("foo:v2"),
+				serviceUpdateState(swarm.UpdateStateUpdating),
+				serviceUpdateOrder(swarm.UpdateOrderStartFirst),
+			),
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskSlot(1),
+					taskImage("foo:v1"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+				swarmTask("id2",
+					taskSlot(2),
+					taskImage("foo:v1"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.2"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+				swarmTask("id3",
+					taskSlot(2),
+					taskImage("foo:v2"),
+					taskNetworkAttachment("1", "network1", "overlay", []string{"127.0.0.3"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			isGlobalSVC: false,
+			expectedTasks: []string{
+				"container.1",
+				"container.2",
+				"container.2",
+			},
+			networks: map[string]*docker.NetworkResource{
+				"1": {
+					Name: "foo",
+				},
+			},
+		},
 	}
 
 	for caseID, test := range testCases {
 		test := test
 		t.Run(strconv.Itoa(caseID), func(t *testing.T) {
 			t.Parallel()
-			dockerData := parseService(test.service, test.networks)
+			provider := &Provider{}
+			dockerData := provider.parseService(test.service, test.networks)
 			dockerClient := &fakeTasksClient{tasks: test.tasks}
-			taskDockerData, _ := listTasks(context.Background(), dockerClient, test.service.ID, dockerData, test.networks, test.isGlobalSVC)
+			taskDockerData, _ := provider.listTasks(context.Background(), dockerClient, test.service.ID, dockerData, test.networks, test.isGlobalSVC)
 
 			if len(test.expectedTasks) != len(taskDockerData) {
 				t.Errorf("expected tasks %v, got %v", spew.Sdump(test.expectedTasks), spew.Sdump(taskDockerData))
@@ -99,12 +298,86 @@ func TestListTasks(t *testing.T) {
 	}
 }
 
+func TestListTasksLabels(t *testing.T) {
+	networks := map[string]*docker.NetworkResource{
+		"1": {Name: "foo"},
+	}
+
+	testCases := []struct {
+		desc                  string
+		serviceLabelsOverride bool
+		expected              map[string]string
+	}{
+		{
+			desc: "task labels first",
+			expected: map[string]string{
+				label.TraefikPort:   "8080",
+				label.TraefikWeight: "10",
+				label.TraefikTags:   "canary",
+			},
+		},
+		{
+			desc:                  "service labels first",
+			serviceLabelsOverride: true,
+			expected: map[string]string{
+				label.TraefikPort:   "80",
+				label.TraefikWeight: "10",
+				label.TraefikTags:   "canary",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{ServiceLabelsOverride: test.serviceLabelsOverride}
+
+			service := swarmService(serviceName("app"), serviceLabels(map[string]string{
+				label.TraefikPort:   "80",
+				label.TraefikWeight: "10",
+			}))
+			dockerData := provider.parseService(service, networks)
+
+			dockerClient := &fakeTasksClient{
+				tasks: []swarm.Task{
+					swarmTask("id1",
+						taskSlot(1),
+						taskLabels(map[string]string{
+							label.TraefikPort: "8080",
+							label.TraefikTags: "canary",
+						}),
+						taskNetworkAttachment("1", "foo", "overlay", []string{"127.0.0.1"}),
+						taskStatus(taskState(swarm.TaskStateRunning)),
+					),
+					swarmTask("id2",
+						taskSlot(2),
+						taskNetworkAttachment("1", "foo", "overlay", []string{"127.0.0.2"}),
+						taskStatus(taskState(swarm.TaskStateRunning)),
+					),
+				},
+			}
+
+			tasks, err := provider.listTasks(context.Background(), dockerClient, service.ID, dockerData, networks, false)
+			require.NoError(t, err)
+			require.Len(t, tasks, 2)
+
+			assert.Equal(t, test.expected, tasks[0].Labels)
+			// The tasks without labels keep the ones of the service.
+			assert.Equal(t, dockerData.Labels, tasks[1].Labels)
+		})
+	}
+}
+
 type fakeServicesClient struct {
 	dockerclient.APIClient
 	dockerVersion string
 	networks      []dockertypes.NetworkResource
 	services      []swarm.Service
 	tasks         []swarm.Task
+	nodes         []swarm.Node
+	nodesErr      error
 	err           error
 }
 
@@ -117,21 +390,36 @@ func (c *fakeServicesClient) ServerVersion(ctx context.Context) (dockertypes.Ver
 }
 
 func (c *fakeServicesClient) NetworkList(ctx context.Context, options dockertypes.NetworkListOptions) ([]dockertypes.NetworkResource, error) {
-	return c.networks, c.err
+	var networks []dockertypes.NetworkResource
+	for _, network := range c.networks {
+		if options.Filters.ExactMatch("driver", network.Driver) && options.Filters.ExactMatch("scope", network.Scope) {
+			networks = append(networks, network)
+		}
+	}
+	return networks, c.err
 }
 
 func (c *fakeServicesClient) TaskList(ctx context.Context, options dockertypes.TaskListOptions) ([]swarm.Task, error) {
 	return c.tasks, c.err
 }
 
+func (c *fakeServicesClient) NodeList(ctx context.Context, options dockertypes.NodeListOptions) ([]swarm.Node, error) {
+	if c.nodesErr != nil {
+		return nil, c.nodesErr
+	}
+	return c.nodes, c.err
+}
+
 func TestListServices(t *testing.T) {
 	testCases := []struct {
-		desc             string
-		services         []swarm.Service
-		tasks            []swarm.Task
-		dockerVersion    string
-		networks         []dockertypes.NetworkResource
-		expectedServices []string
+		desc              string
+		services          []swarm.Service
+		tasks             []swarm.Task
+		dockerVersion     string
+		networks          []dockertypes.NetworkResource
+		networkDrivers    NetworkDrivers
+		swarmLoadBalancer bool
+		expectedServices  []string
 	}{
 		{
 			desc: "Should return no service due to no networks defined",
@@ -264,63 +552,773 @@ func TestListServices(t *testing.T) {
 				"service2.0",
 			},
 		},
-	}
-
-	for caseID, test := range testCases {
-		test := test
-		t.Run(strconv.Itoa(caseID), func(t *testing.T) {
-			t.Parallel()
-			dockerClient := &fakeServicesClient{services: test.services, tasks: test.tasks, dockerVersion: test.dockerVersion, networks: test.networks}
-
-			serviceDockerData, err := listServices(context.Background(), dockerClient)
-			assert.NoError(t, err)
-
-			assert.Equal(t, len(test.expectedServices), len(serviceDockerData))
-			for i, serviceName := range test.expectedServices {
-				assert.Equal(t, serviceName, serviceDockerData[i].Name)
-			}
-		})
-	}
-}
-
-func TestSwarmTaskParsing(t *testing.T) {
-	testCases := []struct {
-		service     swarm.Service
-		tasks       []swarm.Task
-		isGlobalSVC bool
-		expected    map[string]dockerData
-		networks    map[string]*docker.NetworkResource
-	}{
 		{
-			service: swarmService(serviceName("container")),
-			tasks: []swarm.Task{
-				swarmTask("id1", taskSlot(1)),
-				swarmTask("id2", taskSlot(2)),
-				swarmTask("id3", taskSlot(3)),
-			},
-			isGlobalSVC: false,
-			expected: map[string]dockerData{
-				"id1": {
-					Name: "container.1",
-				},
-				"id2": {
-					Name: "container.2",
-				},
-				"id3": {
-					Name: "container.3",
-				},
+			desc: "Should ignore the service on a plugin network by default",
+			services: []swarm.Service{
+				swarmService(
+					serviceName("service1"),
+					serviceLabels(map[string]string{
+						labelBackendLoadBalancerSwarm: "true",
+					}),
+					withEndpointSpec(modeVIP),
+					withEndpoint(
+						virtualIP("pluginnet", "10.11.12.13/24"),
+					)),
 			},
-			networks: map[string]*docker.NetworkResource{
-				"1": {
-					Name: "foo",
+			dockerVersion: "1.24",
+			networks: []dockertypes.NetworkResource{
+				{
+					Name:   "plugin_network",
+					ID:     "pluginnet",
+					Scope:  "swarm",
+					Driver: "weave",
 				},
 			},
+			expectedServices: []string{},
 		},
 		{
-			service: swarmService(serviceName("container")),
-			tasks: []swarm.Task{
-				swarmTask("id1"),
-				swarmTask("id2"),
+			desc: "Should return the service on a plugin network with an accepted driver",
+			services: []swarm.Service{
+				swarmService(
+					serviceName("service1"),
+					serviceLabels(map[string]string{
+						labelBackendLoadBalancerSwarm: "true",
+					}),
+					withEndpointSpec(modeVIP),
+					withEndpoint(
+						virtualIP("pluginnet", "10.11.12.13/24"),
+					)),
+			},
+			dockerVersion: "1.24",
+			networks: []dockertypes.NetworkResource{
+				{
+					Name:   "plugin_network",
+					ID:     "pluginnet",
+					Scope:  "swarm",
+					Driver: "weave",
+				},
+			},
+			networkDrivers: NetworkDrivers{"weave"},
+			expectedServices: []string{
+				"service1",
+			},
+		},
+		{
+			desc: "Should use the swarm load balancer by default",
+			services: []swarm.Service{
+				swarmService(
+					serviceName("service1"),
+					withEndpointSpec(modeVIP),
+					withEndpoint(
+						virtualIP("yk6l57rfwizjzxxzftn4amaot", "10.11.12.13/24"),
+					)),
+			},
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskNetworkAttachment("yk6l57rfwizjzxxzftn4amaot", "network_name", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			dockerVersion: "1.30",
+			networks: []dockertypes.NetworkResource{
+				{
+					Name:   "network_name",
+					ID:     "yk6l57rfwizjzxxzftn4amaot",
+					Scope:  "swarm",
+					Driver: "overlay",
+				},
+			},
+			swarmLoadBalancer: true,
+			expectedServices: []string{
+				"service1",
+			},
+		},
+		{
+			desc: "Should use the tasks when the label disables the default swarm load balancer",
+			services: []swarm.Service{
+				swarmService(
+					serviceName("service1"),
+					serviceLabels(map[string]string{
+						labelBackendLoadBalancerSwarm: "false",
+					}),
+					withEndpointSpec(modeVIP),
+					withEndpoint(
+						virtualIP("yk6l57rfwizjzxxzftn4amaot", "10.11.12.13/24"),
+					)),
+			},
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskSlot(1),
+					taskNetworkAttachment("yk6l57rfwizjzxxzftn4amaot", "network_name", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			dockerVersion: "1.30",
+			networks: []dockertypes.NetworkResource{
+				{
+					Name:   "network_name",
+					ID:     "yk6l57rfwizjzxxzftn4amaot",
+					Scope:  "swarm",
+					Driver: "overlay",
+				},
+			},
+			swarmLoadBalancer: true,
+			expectedServices: []string{
+				"service1.1",
+			},
+		},
+		{
+			desc: "Should fallback to the tasks for a DNSRR service using the swarm load balancer",
+			services: []swarm.Service{
+				swarmService(
+					serviceName("service1"),
+					serviceLabels(map[string]string{
+						labelBackendLoadBalancerSwarm: "true",
+					}),
+					withEndpointSpec(modeDNSSR)),
+			},
+			tasks: []swarm.Task{
+				swarmTask("id1",
+					taskSlot(1),
+					taskNetworkAttachment("yk6l57rfwizjzxxzftn4amaot", "network_name", "overlay", []string{"127.0.0.1"}),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+				),
+			},
+			dockerVersion: "1.30",
+			networks: []dockertypes.NetworkResource{
+				{
+					Name:   "network_name",
+					ID:     "yk6l57rfwizjzxxzftn4amaot",
+					Scope:  "swarm",
+					Driver: "overlay",
+				},
+			},
+			expectedServices: []string{
+				"service1.1",
+			},
+		},
+	}
+
+	for caseID, test := range testCases {
+		test := test
+		t.Run(strconv.Itoa(caseID), func(t *testing.T) {
+			t.Parallel()
+			dockerClient := &fakeServicesClient{services: test.services, tasks: test.tasks, dockerVersion: test.dockerVersion, networks: test.networks}
+
+			provider := &Provider{SwarmNetworkDrivers: test.networkDrivers, SwarmLoadBalancer: test.swarmLoadBalancer}
+			serviceDockerData, err := provider.listServices(context.Background(), dockerClient)
+			assert.NoError(t, err)
+
+			assert.Equal(t, len(test.expectedServices), len(serviceDockerData))
+			for i, serviceName := range test.expectedServices {
+				assert.Equal(t, serviceName, serviceDockerData[i].Name)
+			}
+		})
+	}
+}
+
+func TestListServicesMacvlan(t *testing.T) {
+	dockerClient := &fakeServicesClient{
+		dockerVersion: "1.24",
+		services: []swarm.Service{
+			swarmService(serviceName("service1"), withEndpointSpec(modeDNSSR)),
+		},
+		tasks: []swarm.Task{
+			swarmTask("id1",
+				taskSlot(1),
+				taskStatus(taskState(swarm.TaskStateRunning)),
+				taskNetworkAttachment("macvlannet", "macvlan_network", "macvlan", []string{"192.168.1.50/24"})),
+		},
+		networks: []dockertypes.NetworkResource{
+			{
+				Name:   "macvlan_network",
+				ID:     "macvlannet",
+				Scope:  "local",
+				Driver: "macvlan",
+			},
+		},
+	}
+
+	provider := &Provider{}
+
+	dockerDataList, err := provider.listServices(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, dockerDataList, 1)
+
+	assert.Equal(t, "192.168.1.50", provider.getIPAddress(dockerDataList[0]))
+}
+
+func TestListServicesGlobalServiceUseNodeIP(t *testing.T) {
+	node := func(id, addr string) swarm.Node {
+		return swarm.Node{ID: id, Status: swarm.NodeStatus{Addr: addr}}
+	}
+	manager := func(id, addr, managerAddr string) swarm.Node {
+		return swarm.Node{ID: id, Status: swarm.NodeStatus{Addr: addr}, ManagerStatus: &swarm.ManagerStatus{Addr: managerAddr}}
+	}
+	task := func(id, nodeID string) swarm.Task {
+		return swarmTask(id,
+			taskStatus(taskState(swarm.TaskStateRunning)),
+			func(task *swarm.Task) {
+				task.ServiceID = "serviceID"
+				task.NodeID = nodeID
+			})
+	}
+
+	dockerClient := &fakeServicesClient{
+		dockerVersion: "1.30",
+		services: []swarm.Service{
+			swarmService(
+				serviceName("global"),
+				serviceLabels(map[string]string{
+					label.TraefikPort: "80",
+				}),
+				withEndpointSpec(modeVIP),
+				func(service *swarm.Service) {
+					service.Spec.Mode = swarm.ServiceMode{Global: &swarm.GlobalService{}}
+					service.Endpoint.Ports = []swarm.PortConfig{
+						{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 80, PublishedPort: 8080, PublishMode: swarm.PortConfigPublishModeHost},
+						{Protocol: swarm.PortConfigProtocolTCP, TargetPort: 443, PublishedPort: 8443, PublishMode: swarm.PortConfigPublishModeHost},
+					}
+				}),
+		},
+		tasks: []swarm.Task{
+			task("task1", "node1"),
+			task("task2", "node2"),
+			task("task3", "node3"),
+		},
+		nodes: []swarm.Node{
+			node("node1", "192.168.0.1"),
+			node("node2", "192.168.0.2"),
+			manager("node3", "0.0.0.0", "192.168.0.3:2377"),
+		},
+	}
+
+	provider := &Provider{
+		Domain:                 "docker.localhost",
+		ExposedByDefault:       true,
+		SwarmMode:              true,
+		GlobalServiceUseNodeIP: true,
+	}
+
+	dockerDataList, err := provider.listServices(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, dockerDataList, 3)
+
+	config := provider.buildConfiguration(dockerDataList)
+	require.NotNil(t, config)
+	require.Contains(t, config.Backends, "backend-global")
+
+	var urls []string
+	for _, server := range config.Backends["backend-global"].Servers {
+		urls = append(urls, server.URL)
+	}
+	assert.ElementsMatch(t, []string{
+		"http://192.168.0.1:8080",
+		"http://192.168.0.2:8080",
+		"http://192.168.0.3:8080",
+	}, urls)
+}
+
+func TestGetNodeAddress(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		node     swarm.Node
+		expected string
+	}{
+		{
+			desc:     "node address",
+			node:     swarm.Node{Status: swarm.NodeStatus{Addr: "192.168.0.1"}},
+			expected: "192.168.0.1",
+		},
+		{
+			desc: "unspecified address of a manager",
+			node: swarm.Node{
+				Status:        swarm.NodeStatus{Addr: "0.0.0.0"},
+				ManagerStatus: &swarm.ManagerStatus{Addr: "192.168.0.2:2377"},
+			},
+			expected: "192.168.0.2",
+		},
+		{
+			desc: "unspecified addresses of a manager",
+			node: swarm.Node{
+				Status:        swarm.NodeStatus{Addr: "0.0.0.0"},
+				ManagerStatus: &swarm.ManagerStatus{Addr: "0.0.0.0:2377"},
+			},
+		},
+		{
+			desc: "unspecified address of a worker",
+			node: swarm.Node{Status: swarm.NodeStatus{Addr: "0.0.0.0"}},
+		},
+		{
+			desc: "unknown node",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, getNodeAddress(test.node))
+		})
+	}
+}
+
+func TestListServicesExcludeDrainedNodes(t *testing.T) {
+	node := func(id string, role swarm.NodeRole, availability swarm.NodeAvailability) swarm.Node {
+		return swarm.Node{ID: id, Spec: swarm.NodeSpec{Role: role, Availability: availability}}
+	}
+	task := func(id string, slot int, nodeID string) swarm.Task {
+		return swarmTask(id,
+			taskSlot(slot),
+			taskStatus(taskState(swarm.TaskStateRunning)),
+			taskNetworkAttachment("yk6l57rfwizjzxxzftn4amaot", "network_name", "overlay", []string{"127.0.0.1/24"}),
+			func(task *swarm.Task) {
+				task.NodeID = nodeID
+			})
+	}
+
+	testCases := []struct {
+		desc                string
+		excludeDrainedNodes bool
+		nodesData           bool
+		expected            map[string]string
+	}{
+		{
+			desc: "drained nodes kept, without listing the nodes",
+			expected: map[string]string{
+				"service1.1": "",
+				"service1.2": "",
+			},
+		},
+		{
+			desc:      "drained nodes kept, with the nodes data",
+			nodesData: true,
+			expected: map[string]string{
+				"service1.1": "active",
+				"service1.2": "drain",
+			},
+		},
+		{
+			desc:                "drained nodes excluded",
+			excludeDrainedNodes: true,
+			expected: map[string]string{
+				"service1.1": "active",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dockerClient := &fakeServicesClient{
+				dockerVersion: "1.30",
+				services: []swarm.Service{
+					swarmService(serviceName("service1"), withEndpointSpec(modeDNSSR)),
+				},
+				tasks: []swarm.Task{
+					task("id1", 1, "node1"),
+					task("id2", 2, "node2"),
+				},
+				nodes: []swarm.Node{
+					node("node1", swarm.NodeRoleManager, swarm.NodeAvailabilityActive),
+					node("node2", swarm.NodeRoleWorker, swarm.NodeAvailabilityDrain),
+				},
+				networks: []dockertypes.NetworkResource{
+					{
+						Name:   "network_name",
+						ID:     "yk6l57rfwizjzxxzftn4amaot",
+						Scope:  "swarm",
+						Driver: "overlay",
+					},
+				},
+			}
+
+			provider := &Provider{ExcludeDrainedNodes: test.excludeDrainedNodes}
+
+			ctx := context.Background()
+			if test.nodesData {
+				ctx = context.WithValue(ctx, nodesDataKey{}, true)
+			}
+
+			dockerDataList, err := provider.listServices(ctx, dockerClient)
+			require.NoError(t, err)
+
+			actual := make(map[string]string)
+			for _, dData := range dockerDataList {
+				actual[dData.Name] = dData.NodeAvailability
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestListServicesNodeListFailure(t *testing.T) {
+	dockerClient := &fakeServicesClient{
+		dockerVersion: "1.30",
+		services: []swarm.Service{
+			swarmService(serviceName("service1"), withEndpointSpec(modeDNSSR)),
+		},
+		tasks: []swarm.Task{
+			swarmTask("id1",
+				taskSlot(1),
+				taskStatus(taskState(swarm.TaskStateRunning)),
+				taskNetworkAttachment("yk6l57rfwizjzxxzftn4amaot", "network_name", "overlay", []string{"127.0.0.1/24"})),
+		},
+		nodesErr: errors.New("this node is not a swarm manager"),
+		networks: []dockertypes.NetworkResource{
+			{
+				Name:   "network_name",
+				ID:     "yk6l57rfwizjzxxzftn4amaot",
+				Scope:  "swarm",
+				Driver: "overlay",
+			},
+		},
+	}
+
+	provider := &Provider{ExcludeDrainedNodes: true}
+
+	dockerDataList, err := provider.listServices(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, dockerDataList, 1)
+	assert.Equal(t, "service1.1", dockerDataList[0].Name)
+	assert.Empty(t, dockerDataList[0].NodeAvailability)
+}
+
+func TestListServicesJobs(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		includeJobs bool
+		expected    []string
+	}{
+		{
+			desc: "job services ignored by default",
+		},
+		{
+			desc:        "job services included",
+			includeJobs: true,
+			expected:    []string{"job.1"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			dockerClient := &fakeServicesClient{
+				dockerVersion: "1.30",
+				services: []swarm.Service{
+					swarmService(serviceName("job"), withEndpointSpec(modeDNSSR),
+						func(service *swarm.Service) {
+							service.Spec.Mode = swarm.ServiceMode{}
+						}),
+				},
+				tasks: []swarm.Task{
+					swarmTask("id1",
+						taskSlot(1),
+						taskStatus(taskState(swarm.TaskStateRunning)),
+						taskNetworkAttachment("yk6l57rfwizjzxxzftn4amaot", "network_name", "overlay", []string{"127.0.0.1/24"})),
+				},
+				networks: []dockertypes.NetworkResource{
+					{
+						Name:   "network_name",
+						ID:     "yk6l57rfwizjzxxzftn4amaot",
+						Scope:  "swarm",
+						Driver: "overlay",
+					},
+				},
+			}
+
+			provider := &Provider{IncludeJobs: test.includeJobs}
+
+			dockerDataList, err := provider.listServices(context.Background(), dockerClient)
+			require.NoError(t, err)
+
+			var actual []string
+			for _, dData := range dockerDataList {
+				actual = append(actual, dData.Name)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+type notFoundError struct {
+	error
+}
+
+func (e notFoundError) NotFound() bool {
+	return true
+}
+
+// fakeRemovedServiceClient behaves as if a service was removed between the list of the services and the list of its tasks.
+type fakeRemovedServiceClient struct {
+	*fakeServicesClient
+	removedServiceID string
+}
+
+func (c *fakeRemovedServiceClient) TaskList(ctx context.Context, options dockertypes.TaskListOptions) ([]swarm.Task, error) {
+	if options.Filters.ExactMatch("service", c.removedServiceID) {
+		return nil, notFoundError{fmt.Errorf("service %s not found", c.removedServiceID)}
+	}
+	return c.fakeServicesClient.TaskList(ctx, options)
+}
+
+func TestListServicesMinReplicas(t *testing.T) {
+	task := func(id string, slot int, state swarm.TaskState) swarm.Task {
+		return swarmTask(id,
+			taskSlot(slot),
+			taskStatus(taskState(state)),
+			taskNetworkAttachment("yk6l57rfwizjzxxzftn4amaot", "network_name", "overlay", []string{"127.0.0.1/24"}))
+	}
+
+	testCases := []struct {
+		desc          string
+		minReplicas   string
+		endpointSpec  func(*swarm.Service)
+		expectedNames []string
+	}{
+		{
+			desc:          "without label",
+			endpointSpec:  withEndpointSpec(modeDNSSR),
+			expectedNames: []string{"service1.1"},
+		},
+		{
+			desc:         "below the threshold",
+			minReplicas:  "2",
+			endpointSpec: withEndpointSpec(modeDNSSR),
+		},
+		{
+			desc:          "threshold reached",
+			minReplicas:   "1",
+			endpointSpec:  withEndpointSpec(modeDNSSR),
+			expectedNames: []string{"service1.1"},
+		},
+		{
+			desc:          "invalid label",
+			minReplicas:   "two",
+			endpointSpec:  withEndpointSpec(modeDNSSR),
+			expectedNames: []string{"service1.1"},
+		},
+		{
+			desc:        "swarm load balancer below the threshold",
+			minReplicas: "2",
+			endpointSpec: func(service *swarm.Service) {
+				withEndpointSpec(modeVIP)(service)
+				withEndpoint(virtualIP("yk6l57rfwizjzxxzftn4amaot", "10.11.12.13/24"))(service)
+				service.Spec.Annotations.Labels[labelBackendLoadBalancerSwarm] = "true"
+			},
+		},
+		{
+			desc:        "swarm load balancer threshold reached",
+			minReplicas: "1",
+			endpointSpec: func(service *swarm.Service) {
+				withEndpointSpec(modeVIP)(service)
+				withEndpoint(virtualIP("yk6l57rfwizjzxxzftn4amaot", "10.11.12.13/24"))(service)
+				service.Spec.Annotations.Labels[labelBackendLoadBalancerSwarm] = "true"
+			},
+			expectedNames: []string{"service1"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			labels := map[string]string{}
+			if len(test.minReplicas) > 0 {
+				labels[labelMinReplicas] = test.minReplicas
+			}
+
+			dockerClient := &fakeServicesClient{
+				dockerVersion: "1.30",
+				services: []swarm.Service{
+					swarmService(serviceName("service1"), serviceLabels(labels), test.endpointSpec),
+				},
+				// 1 of the 3 replicas is running.
+				tasks: []swarm.Task{
+					task("id1", 1, swarm.TaskStateRunning),
+					task("id2", 2, swarm.TaskStateStarting),
+					task("id3", 3, swarm.TaskStatePreparing),
+				},
+				networks: []dockertypes.NetworkResource{
+					{
+						Name:   "network_name",
+						ID:     "yk6l57rfwizjzxxzftn4amaot",
+						Scope:  "swarm",
+						Driver: "overlay",
+					},
+				},
+			}
+
+			provider := &Provider{}
+
+			dockerDataList, err := provider.listServices(context.Background(), dockerClient)
+			require.NoError(t, err)
+
+			var names []string
+			for _, dData := range dockerDataList {
+				names = append(names, dData.Name)
+			}
+			assert.Equal(t, test.expectedNames, names)
+		})
+	}
+}
+
+func TestListServicesRemovedService(t *testing.T) {
+	serviceID := func(id string) func(*swarm.Service) {
+		return func(service *swarm.Service) {
+			service.ID = id
+		}
+	}
+
+	dockerClient := &fakeRemovedServiceClient{
+		fakeServicesClient: &fakeServicesClient{
+			dockerVersion: "1.30",
+			services: []swarm.Service{
+				swarmService(serviceName("service1"), serviceID("id1"), withEndpointSpec(modeDNSSR)),
+				swarmService(serviceName("removed"), serviceID("id2"), withEndpointSpec(modeDNSSR)),
+			},
+			tasks: []swarm.Task{
+				swarmTask("task1",
+					taskSlot(1),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+					taskNetworkAttachment("yk6l57rfwizjzxxzftn4amaot", "network_name", "overlay", []string{"127.0.0.1/24"})),
+			},
+			networks: []dockertypes.NetworkResource{
+				{
+					Name:   "network_name",
+					ID:     "yk6l57rfwizjzxxzftn4amaot",
+					Scope:  "swarm",
+					Driver: "overlay",
+				},
+			},
+		},
+		removedServiceID: "id2",
+	}
+
+	provider := &Provider{
+		SwarmMode:        true,
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	dockerDataList, err := provider.listServices(context.Background(), dockerClient)
+	require.NoError(t, err)
+
+	require.Len(t, dockerDataList, 1)
+	assert.Equal(t, "service1.1", dockerDataList[0].Name)
+
+	dockerDataList[0].Labels = map[string]string{label.TraefikPort: "80"}
+	config := provider.buildConfiguration(dockerDataList)
+	require.NotNil(t, config)
+	assert.Contains(t, config.Backends, "backend-service1")
+	assert.NotContains(t, config.Backends, "backend-removed")
+}
+
+type fakeNetworkErrorClient struct {
+	*fakeServicesClient
+	networkErr error
+}
+
+func (c *fakeNetworkErrorClient) NetworkList(ctx context.Context, options dockertypes.NetworkListOptions) ([]dockertypes.NetworkResource, error) {
+	if c.networkErr != nil {
+		return nil, c.networkErr
+	}
+	return c.fakeServicesClient.NetworkList(ctx, options)
+}
+
+func TestListServicesNetworkListError(t *testing.T) {
+	dockerClient := &fakeNetworkErrorClient{
+		fakeServicesClient: &fakeServicesClient{
+			dockerVersion: "1.30",
+			services: []swarm.Service{
+				swarmService(serviceName("service1"), withEndpointSpec(modeDNSSR)),
+			},
+			tasks: []swarm.Task{
+				swarmTask("task1",
+					taskSlot(1),
+					taskStatus(taskState(swarm.TaskStateRunning)),
+					taskNetworkAttachment("1", "network_name", "overlay", []string{"10.0.0.1/24"}),
+					taskNetworkAttachment("2", "bridge_name", "bridge", []string{"172.17.0.2/16"})),
+			},
+			networks: []dockertypes.NetworkResource{
+				{Name: "network_name", ID: "1", Scope: "swarm", Driver: "overlay"},
+			},
+		},
+		networkErr: errors.New("network list failed"),
+	}
+
+	provider := &Provider{
+		SwarmMode:        true,
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	// Without any previous listing, the networks attached to the tasks are used.
+	dockerDataList, err := provider.listServices(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, dockerDataList, 1)
+	assert.Equal(t, "service1.1", dockerDataList[0].Name)
+	assert.Len(t, dockerDataList[0].NetworkSettings.Networks, 2)
+
+	dockerClient.networkErr = nil
+	dockerDataList, err = provider.listServices(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, dockerDataList, 1)
+	assert.Len(t, dockerDataList[0].NetworkSettings.Networks, 1)
+
+	// After a successful listing, the previous networks are used.
+	dockerClient.networkErr = errors.New("network list failed")
+	dockerDataList, err = provider.listServices(context.Background(), dockerClient)
+	require.NoError(t, err)
+	require.Len(t, dockerDataList, 1)
+	require.Len(t, dockerDataList[0].NetworkSettings.Networks, 1)
+	assert.Contains(t, dockerDataList[0].NetworkSettings.Networks, "network_name")
+}
+
+func TestSwarmTaskParsing(t *testing.T) {
+	testCases := []struct {
+		service     swarm.Service
+		tasks       []swarm.Task
+		isGlobalSVC bool
+		expected    map[string]dockerData
+		networks    map[string]*docker.NetworkResource
+	}{
+		{
+			service: swarmService(serviceName("container")),
+			tasks: []swarm.Task{
+				swarmTask("id1", taskSlot(1)),
+				swarmTask("id2", taskSlot(2)),
+				swarmTask("id3", taskSlot(3)),
+			},
+			isGlobalSVC: false,
+			expected: map[string]dockerData{
+				"id1": {
+					Name: "container.1",
+				},
+				"id2": {
+					Name: "container.2",
+				},
+				"id3": {
+					Name: "container.3",
+				},
+			},
+			networks: map[string]*docker.NetworkResource{
+				"1": {
+					Name: "foo",
+				},
+			},
+		},
+		{
+			service: swarmService(serviceName("container")),
+			tasks: []swarm.Task{
+				swarmTask("id1"),
+				swarmTask("id2"),
 				swarmTask("id3"),
 			},
 			isGlobalSVC: true,
@@ -385,7 +1383,8 @@ func TestSwarmTaskParsing(t *testing.T) {
 		test := test
 		t.Run(strconv.Itoa(caseID), func(t *testing.T) {
 			t.Parallel()
-			dData := parseService(test.service, test.networks)
+			provider := &Provider{}
+			dData := provider.parseService(test.service, test.networks)
 
 			for _, task := range test.tasks {
 				taskDockerData := parseTasks(task, dData, test.networks, test.isGlobalSVC)
@@ -395,3 +1394,59 @@ func TestSwarmTaskParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestSwarmEnvConstraints(t *testing.T) {
+	portLabel := serviceLabels(map[string]string{label.TraefikPort: "80"})
+	services := []swarm.Service{
+		swarmService(serviceName("prod"), portLabel, serviceEnv("ENV=prod", "PASSWORD=s3cr3t")),
+		swarmService(serviceName("staging"), portLabel, serviceEnv("ENV=staging", "PASSWORD=s3cr3t")),
+		swarmService(serviceName("none"), portLabel, serviceEnv("PASSWORD=s3cr3t")),
+	}
+
+	testCases := []struct {
+		desc       string
+		constraint string
+		expected   []string
+	}{
+		{
+			desc:       "matching env constraint",
+			constraint: "env.ENV==prod",
+			expected:   []string{"prod"},
+		},
+		{
+			desc:       "non matching env constraint",
+			constraint: "env.ENV!=prod",
+			expected:   []string{"staging", "none"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			constraint, err := types.NewConstraint(test.constraint)
+			require.NoError(t, err)
+
+			provider := &Provider{
+				ExposedByDefault: true,
+				Domain:           "docker.localhost",
+				SwarmMode:        true,
+				ParseEnv:         true,
+			}
+			err = provider.Init(types.Constraints{constraint})
+			require.NoError(t, err)
+
+			var actual []string
+			for _, service := range services {
+				dData := provider.parseService(service, map[string]*dockertypes.NetworkResource{})
+				task := parseTasks(swarmTask("id1", taskSlot(1)), dData, map[string]*dockertypes.NetworkResource{}, false)
+				if provider.containerFilter(task) {
+					actual = append(actual, dData.Name)
+				}
+			}
+
+			assert.ElementsMatch(t, test.expected, actual)
+		})
+	}
+}
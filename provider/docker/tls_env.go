@@ -0,0 +1,53 @@
+package docker
+
+import (
+	"net/url"
+	"path/filepath"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/types"
+)
+
+// getTLS returns the TLS option, or else the TLS configuration given by the environment as for the docker CLI.
+// The environment only applies to the tcp endpoints: the unix sockets and named pipes are local.
+func (p *Provider) getTLS(hostURL *url.URL, getenv func(string) string) *types.ClientTLS {
+	if p.TLS != nil {
+		return p.TLS
+	}
+
+	if hostURL.Scheme != "tcp" {
+		return nil
+	}
+
+	clientTLS := getEnvTLS(getenv)
+	if clientTLS != nil && clientTLS.InsecureSkipVerify {
+		log.Warnf("DOCKER_CERT_PATH is set without DOCKER_TLS_VERIFY: the certificate of the docker daemon %s is not verified", hostURL.Host)
+	}
+	return clientTLS
+}
+
+// getEnvTLS reads the TLS configuration from the DOCKER_CERT_PATH and DOCKER_TLS_VERIFY environment variables,
+// with the ca.pem, cert.pem and key.pem files of the docker CLI. It returns nil when none of them is set.
+// As for the docker CLI, DOCKER_TLS_VERIFY alone uses the certificates of the docker config directory.
+func getEnvTLS(getenv func(string) string) *types.ClientTLS {
+	certPath := getenv("DOCKER_CERT_PATH")
+	verify := len(getenv("DOCKER_TLS_VERIFY")) > 0
+
+	if len(certPath) == 0 {
+		if !verify {
+			return nil
+		}
+
+		certPath = getenv("DOCKER_CONFIG")
+		if len(certPath) == 0 {
+			certPath = filepath.Join(getenv("HOME"), ".docker")
+		}
+	}
+
+	return &types.ClientTLS{
+		CA:                 filepath.Join(certPath, "ca.pem"),
+		Cert:               filepath.Join(certPath, "cert.pem"),
+		Key:                filepath.Join(certPath, "key.pem"),
+		InsecureSkipVerify: !verify,
+	}
+}
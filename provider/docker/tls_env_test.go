@@ -0,0 +1,125 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/containous/traefik/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderGetTLS(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		endpoint string
+		tls      *types.ClientTLS
+		env      map[string]string
+		expected *types.ClientTLS
+	}{
+		{
+			desc: "no TLS",
+			env:  map[string]string{"HOME": "/root"},
+		},
+		{
+			desc: "cert path with verification",
+			env: map[string]string{
+				"DOCKER_CERT_PATH":  "/certs",
+				"DOCKER_TLS_VERIFY": "1",
+			},
+			expected: &types.ClientTLS{
+				CA:   "/certs/ca.pem",
+				Cert: "/certs/cert.pem",
+				Key:  "/certs/key.pem",
+			},
+		},
+		{
+			desc: "cert path without verification",
+			env: map[string]string{
+				"DOCKER_CERT_PATH": "/certs",
+			},
+			expected: &types.ClientTLS{
+				CA:                 "/certs/ca.pem",
+				Cert:               "/certs/cert.pem",
+				Key:                "/certs/key.pem",
+				InsecureSkipVerify: true,
+			},
+		},
+		{
+			desc: "verification with the certificates of the home directory",
+			env: map[string]string{
+				"DOCKER_TLS_VERIFY": "1",
+				"HOME":              "/root",
+			},
+			expected: &types.ClientTLS{
+				CA:   "/root/.docker/ca.pem",
+				Cert: "/root/.docker/cert.pem",
+				Key:  "/root/.docker/key.pem",
+			},
+		},
+		{
+			desc: "verification with the certificates of the docker config directory",
+			env: map[string]string{
+				"DOCKER_TLS_VERIFY": "1",
+				"DOCKER_CONFIG":     "/etc/docker",
+				"HOME":              "/root",
+			},
+			expected: &types.ClientTLS{
+				CA:   "/etc/docker/ca.pem",
+				Cert: "/etc/docker/cert.pem",
+				Key:  "/etc/docker/key.pem",
+			},
+		},
+		{
+			desc:     "environment ignored for a unix socket",
+			endpoint: "unix:///var/run/docker.sock",
+			env: map[string]string{
+				"DOCKER_CERT_PATH":  "/certs",
+				"DOCKER_TLS_VERIFY": "1",
+			},
+		},
+		{
+			desc:     "environment ignored for a named pipe",
+			endpoint: "npipe:////./pipe/docker_engine",
+			env: map[string]string{
+				"DOCKER_CERT_PATH": "/certs",
+			},
+		},
+		{
+			desc:     "TLS option for a unix socket",
+			endpoint: "unix:///var/run/docker.sock",
+			tls:      &types.ClientTLS{CA: "/tls/ca.pem"},
+			expected: &types.ClientTLS{CA: "/tls/ca.pem"},
+		},
+		{
+			desc: "TLS option taking precedence",
+			tls:  &types.ClientTLS{CA: "/tls/ca.pem"},
+			env: map[string]string{
+				"DOCKER_CERT_PATH":  "/certs",
+				"DOCKER_TLS_VERIFY": "1",
+			},
+			expected: &types.ClientTLS{CA: "/tls/ca.pem"},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			endpoint := test.endpoint
+			if len(endpoint) == 0 {
+				endpoint = "tcp://10.0.0.1:2376"
+			}
+			hostURL, err := dockerclient.ParseHostURL(endpoint)
+			require.NoError(t, err)
+
+			provider := &Provider{TLS: test.tls}
+
+			actual := provider.getTLS(hostURL, func(key string) string {
+				return test.env[key]
+			})
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/containous/traefik/log"
+	"github.com/containous/traefik/provider/label"
+)
+
+const (
+	typedRoutersPrefix     = label.Prefix + "http.routers."
+	typedServicesPrefix    = label.Prefix + "http.services."
+	typedMiddlewaresPrefix = label.Prefix + "http.middlewares."
+	// typedTCPPrefix is the prefix of the typed labels of the TCP routers and services, e.g. for the TLS passthrough:
+	// only the HTTP frontends are supported.
+	typedTCPPrefix = label.Prefix + "tcp."
+)
+
+// typedRuleMatcherRegexp matches the first matcher of a typed router rule, e.g. Host(`a.foo`, `b.foo`) &&,
+// and typedRuleArgRegexp its arguments.
+var (
+	typedRuleMatcherRegexp = regexp.MustCompile("^\\s*([A-Za-z]+)\\(\\s*(`[^`]*`(?:\\s*,\\s*`[^`]*`)*)\\s*\\)\\s*(?:&&|$)")
+	typedRuleArgRegexp     = regexp.MustCompile("`([^`]*)`")
+)
+
+// typedRuleMatchers lists the matchers of the typed router rules having an equivalent in the frontend rules.
+var typedRuleMatchers = map[string]bool{
+	"Host":          true,
+	"HostRegexp":    true,
+	"Path":          true,
+	"PathPrefix":    true,
+	"Method":        true,
+	"Headers":       true,
+	"HeadersRegexp": true,
+	"Query":         true,
+}
+
+// typedServiceOptions maps the options of the typed services to the segment properties.
+var typedServiceOptions = map[string]string{
+	"loadbalancer.server.port":    label.SuffixPort,
+	"loadbalancer.server.scheme":  label.SuffixProtocol,
+	"loadbalancer.passhostheader": label.SuffixFrontendPassHostHeader,
+}
+
+// typedMiddlewareOptions maps the options of the typed middlewares to the segment properties.
+var typedMiddlewareOptions = map[string]string{
+	"basicauth.users":         label.SuffixFrontendAuthBasicUsers,
+	"basicauth.usersfile":     label.SuffixFrontendAuthBasicUsersFile,
+	"basicauth.removeheader":  label.SuffixFrontendAuthBasicRemoveHeader,
+	"ipwhitelist.sourcerange": label.SuffixFrontendWhiteListSourceRange,
+}
+
+// typedMiddlewareHeaders maps the prefixes of the typed middleware options setting a single header to the segment properties.
+var typedMiddlewareHeaders = map[string]string{
+	"headers.customrequestheaders.":  label.SuffixFrontendRequestHeaders + ".",
+	"headers.customresponseheaders.": label.SuffixFrontendResponseHeaders + ".",
+}
+
+// convertContainersTypedLabels converts the typed labels of the containers to segment labels.
+func convertContainersTypedLabels(containers []dockerData) []dockerData {
+	var result []dockerData
+	for _, container := range containers {
+		container.Labels = convertTypedLabels(container)
+		result = append(result, container)
+	}
+	return result
+}
+
+// convertTypedLabels converts the typed labels of the routers, services and middlewares of a container,
+// e.g. traefik.http.routers.<name>.rule, to segment labels: each router becomes a segment named after it,
+// so a container can define several routers.
+// The typed labels are dropped, and an invalid router is ignored without ignoring the other ones.
+// The labels of the TCP routers (traefik.tcp.) are dropped too, as there is no TCP frontend.
+func convertTypedLabels(container dockerData) map[string]string {
+	routers := make(map[string]map[string]string)
+	services := make(map[string]map[string]string)
+	middlewares := make(map[string]map[string]string)
+
+	var tcpLabels []string
+
+	labels := make(map[string]string, len(container.Labels))
+	for key, value := range container.Labels {
+		switch {
+		case strings.HasPrefix(strings.ToLower(key), typedTCPPrefix):
+			tcpLabels = append(tcpLabels, key)
+		case addTypedLabel(routers, typedRoutersPrefix, key, value):
+		case addTypedLabel(services, typedServicesPrefix, key, value):
+		case addTypedLabel(middlewares, typedMiddlewaresPrefix, key, value):
+		default:
+			labels[key] = value
+		}
+	}
+
+	// Otherwise read as the labels of a segment named tcp, they would create an HTTP frontend.
+	if len(tcpLabels) > 0 {
+		sort.Strings(tcpLabels)
+		log.Warnf("Ignoring the labels %s of container %s: the TCP routers are not supported", strings.Join(tcpLabels, ", "), container.Name)
+	}
+
+	if len(routers) == 0 && len(services) == 0 && len(middlewares) == 0 {
+		return labels
+	}
+
+	// As for the default rule of a container, a service without router gets the default frontend rule.
+	if len(routers) == 0 {
+		for name := range services {
+			routers[name] = map[string]string{"service": name}
+		}
+	}
+
+	// The segments need a port: without a port label, the router uses the port discovered for the container.
+	defaultPort := label.GetStringValue(labels, label.TraefikPort, "")
+	if len(defaultPort) == 0 {
+		defaultPort = getPort(dockerData{ExposedPorts: container.ExposedPorts, NetworkSettings: container.NetworkSettings, Labels: labels})
+	}
+
+	var names []string
+	for name := range routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		segmentLabels, err := getRouterSegmentLabels(routers[name], services, middlewares)
+		if err != nil {
+			log.Warnf("Ignoring the router %s of container %s: %v", name, container.Name, err)
+			continue
+		}
+
+		if _, ok := segmentLabels[label.SuffixPort]; !ok && len(defaultPort) > 0 {
+			segmentLabels[label.SuffixPort] = defaultPort
+		}
+
+		for property, value := range segmentLabels {
+			labels[label.Prefix+name+"."+property] = value
+		}
+	}
+
+	return labels
+}
+
+// addTypedLabel adds the label to the options of its router, service or middleware when it has the given prefix.
+// The prefix and the options are case insensitive.
+func addTypedLabel(objects map[string]map[string]string, prefix string, key string, value string) bool {
+	if !strings.HasPrefix(strings.ToLower(key), prefix) {
+		return false
+	}
+
+	parts := strings.SplitN(key[len(prefix):], ".", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		log.Warnf("Invalid label %s, ignoring it", key)
+		return true
+	}
+
+	if objects[parts[0]] == nil {
+		objects[parts[0]] = make(map[string]string)
+	}
+	objects[parts[0]][strings.ToLower(parts[1])] = value
+	return true
+}
+
+// getRouterSegmentLabels returns the segment properties of a router, given by its options, its service and its middlewares.
+func getRouterSegmentLabels(router map[string]string, services map[string]map[string]string, middlewares map[string]map[string]string) (map[string]string, error) {
+	segmentLabels := make(map[string]string)
+
+	for option, value := range router {
+		switch option {
+		case "rule":
+			rule, err := convertRouterRule(value)
+			if err != nil {
+				return nil, err
+			}
+			segmentLabels[label.SuffixFrontendRule] = rule
+		case "entrypoints":
+			segmentLabels[label.SuffixFrontendEntryPoints] = value
+		case "priority":
+			segmentLabels[label.SuffixFrontendPriority] = value
+		case "tls":
+			segmentLabels[strings.TrimPrefix(labelFrontendTLS, label.Prefix)] = value
+		case "service", "middlewares":
+		default:
+			log.Warnf("Unsupported router option %s, ignoring it", option)
+		}
+	}
+
+	serviceName := router["service"]
+	if len(serviceName) == 0 && len(services) == 1 {
+		for name := range services {
+			serviceName = name
+		}
+	}
+
+	if len(serviceName) > 0 {
+		service, ok := services[serviceName]
+		if !ok {
+			return nil, fmt.Errorf("unknown service %s", serviceName)
+		}
+
+		// The routers of the same service share its backend.
+		segmentLabels[label.SuffixBackend] = serviceName
+		for option, value := range service {
+			property, ok := typedServiceOptions[option]
+			if !ok {
+				log.Warnf("Unsupported option %s of service %s, ignoring it", option, serviceName)
+				continue
+			}
+			segmentLabels[property] = value
+		}
+	} else if len(services) > 1 {
+		return nil, errors.New("the service must be set as the container defines several services")
+	}
+
+	for _, name := range strings.Split(router["middlewares"], ",") {
+		name = strings.TrimSuffix(strings.TrimSpace(name), "@docker")
+		if len(name) == 0 {
+			continue
+		}
+
+		middleware, ok := middlewares[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware %s", name)
+		}
+
+		// A middleware is not ignored partially, as it may be needed to secure the router.
+		for option, value := range middleware {
+			property, err := getMiddlewareProperty(option)
+			if err != nil {
+				return nil, fmt.Errorf("middleware %s: %v", name, err)
+			}
+			segmentLabels[property] = value
+		}
+	}
+
+	return segmentLabels, nil
+}
+
+func getMiddlewareProperty(option string) (string, error) {
+	if property, ok := typedMiddlewareOptions[option]; ok {
+		return property, nil
+	}
+
+	for prefix, property := range typedMiddlewareHeaders {
+		if strings.HasPrefix(option, prefix) && len(option) > len(prefix) {
+			return property + option[len(prefix):], nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported option %s", option)
+}
+
+// convertRouterRule converts a typed router rule, e.g. Host(`a.foo`) && PathPrefix(`/bar`), to a frontend rule, e.g. Host:a.foo;PathPrefix:/bar.
+// Only the matchers combined with && have an equivalent.
+func convertRouterRule(rule string) (string, error) {
+	var matchers []string
+	for rest := strings.TrimSpace(rule); len(rest) > 0; {
+		match := typedRuleMatcherRegexp.FindStringSubmatch(rest)
+		if match == nil {
+			return "", fmt.Errorf("unsupported rule %q: only the matchers combined with && are supported", rule)
+		}
+
+		if !typedRuleMatchers[match[1]] {
+			return "", fmt.Errorf("unsupported matcher %s in rule %q", match[1], rule)
+		}
+
+		var args []string
+		for _, arg := range typedRuleArgRegexp.FindAllStringSubmatch(match[2], -1) {
+			if strings.ContainsAny(arg[1], ",;") {
+				return "", fmt.Errorf("invalid value %q in rule %q: commas and semicolons are not supported", arg[1], rule)
+			}
+			args = append(args, arg[1])
+		}
+
+		matchers = append(matchers, match[1]+":"+strings.Join(args, ","))
+		rest = rest[len(match[0]):]
+	}
+
+	if len(matchers) == 0 {
+		return "", errors.New("empty rule")
+	}
+	return strings.Join(matchers, ";"), nil
+}
@@ -0,0 +1,269 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/containous/traefik/provider/label"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerBuildConfigurationTypedLabels(t *testing.T) {
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	containers := []dockerData{
+		parseContainer(containerJSON(name("foo"),
+			labels(map[string]string{
+				"traefik.http.routers.api.rule":                                     "Host(`api.foo`) && PathPrefix(`/v1`)",
+				"traefik.http.routers.api.service":                                  "api",
+				"traefik.http.routers.api.middlewares":                              "auth@docker",
+				"traefik.http.routers.admin.rule":                                   "Host(`admin.foo`, `backoffice.foo`)",
+				"traefik.http.routers.admin.entrypoints":                            "https",
+				"traefik.http.routers.admin.service":                                "admin",
+				"traefik.http.services.api.loadbalancer.server.port":                "8080",
+				"traefik.http.services.admin.loadbalancer.server.port":              "9090",
+				"traefik.http.middlewares.auth.basicauth.users":                     "test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+				"traefik.http.middlewares.auth.headers.customrequestheaders.X-Role": "api",
+			}),
+			ports(nat.PortMap{
+				"8080/tcp": {},
+				"9090/tcp": {},
+			}),
+			withNetwork("testnet", ipv4("10.10.10.10")))),
+	}
+
+	config := provider.buildConfiguration(containers)
+	require.NotNil(t, config)
+
+	require.Len(t, config.Frontends, 2)
+
+	api := config.Frontends["frontend-api-foo-api"]
+	require.NotNil(t, api)
+	assert.Equal(t, "backend-foo-api", api.Backend)
+	assert.Equal(t, "Host:api.foo;PathPrefix:/v1", api.Routes["route-frontend-api-foo-api"].Rule)
+	require.NotNil(t, api.Auth)
+	require.NotNil(t, api.Auth.Basic)
+	assert.Equal(t, []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"}, []string(api.Auth.Basic.Users))
+	require.NotNil(t, api.Headers)
+	assert.Equal(t, map[string]string{"X-Role": "api"}, api.Headers.CustomRequestHeaders)
+
+	admin := config.Frontends["frontend-admin-foo-admin"]
+	require.NotNil(t, admin)
+	assert.Equal(t, "backend-foo-admin", admin.Backend)
+	assert.Equal(t, "Host:admin.foo,backoffice.foo", admin.Routes["route-frontend-admin-foo-admin"].Rule)
+	assert.Equal(t, []string{"https"}, admin.EntryPoints)
+	assert.Nil(t, admin.Auth)
+
+	for backendName, expectedURL := range map[string]string{
+		"backend-foo-api":   "http://10.10.10.10:8080",
+		"backend-foo-admin": "http://10.10.10.10:9090",
+	} {
+		require.Contains(t, config.Backends, backendName)
+		require.Len(t, config.Backends[backendName].Servers, 1)
+		for _, server := range config.Backends[backendName].Servers {
+			assert.Equal(t, expectedURL, server.URL)
+		}
+	}
+}
+
+func TestConvertTypedLabels(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		labels   map[string]string
+		ports    nat.PortMap
+		expected map[string]string
+	}{
+		{
+			desc: "without typed labels",
+			labels: map[string]string{
+				label.TraefikFrontendRule: "Host:foo",
+			},
+			expected: map[string]string{
+				label.TraefikFrontendRule: "Host:foo",
+			},
+		},
+		{
+			desc: "router with the single service",
+			labels: map[string]string{
+				"traefik.http.routers.web.rule":                        "Path(`/foo`)",
+				"traefik.http.routers.web.priority":                    "10",
+				"traefik.http.routers.web.tls":                         "true",
+				"traefik.http.services.svc.loadbalancer.server.port":   "80",
+				"traefik.http.services.svc.loadbalancer.server.scheme": "https",
+			},
+			expected: map[string]string{
+				"traefik.web.frontend.rule":     "Path:/foo",
+				"traefik.web.frontend.priority": "10",
+				"traefik.web.frontend.tls":      "true",
+				"traefik.web.backend":           "svc",
+				"traefik.web.port":              "80",
+				"traefik.web.protocol":          "https",
+			},
+		},
+		{
+			desc: "service without router",
+			labels: map[string]string{
+				"traefik.http.services.svc.loadbalancer.server.port": "80",
+				label.TraefikEnable: "true",
+			},
+			expected: map[string]string{
+				"traefik.svc.backend": "svc",
+				"traefik.svc.port":    "80",
+				label.TraefikEnable:   "true",
+			},
+		},
+		{
+			desc: "router using the discovered port",
+			labels: map[string]string{
+				"traefik.http.routers.web.rule": "Host(`foo`)",
+			},
+			ports: nat.PortMap{"8080/tcp": {}},
+			expected: map[string]string{
+				"traefik.web.frontend.rule": "Host:foo",
+				"traefik.web.port":          "8080",
+			},
+		},
+		{
+			desc: "router using the port label",
+			labels: map[string]string{
+				"Traefik.HTTP.Routers.web.Rule": "Host(`foo`)",
+				label.TraefikPort:               "81",
+			},
+			ports: nat.PortMap{"8080/tcp": {}},
+			expected: map[string]string{
+				"traefik.web.frontend.rule": "Host:foo",
+				"traefik.web.port":          "81",
+				label.TraefikPort:           "81",
+			},
+		},
+		{
+			desc: "invalid router ignored",
+			labels: map[string]string{
+				"traefik.http.routers.web.rule":        "Host(`foo`)",
+				"traefik.http.routers.api.rule":        "Host(`api`)",
+				"traefik.http.routers.api.middlewares": "missing",
+				label.TraefikPort:                      "80",
+			},
+			expected: map[string]string{
+				"traefik.web.frontend.rule": "Host:foo",
+				"traefik.web.port":          "80",
+				label.TraefikPort:           "80",
+			},
+		},
+		{
+			desc: "router with an unsupported middleware ignored",
+			labels: map[string]string{
+				"traefik.http.routers.web.rule":                       "Host(`foo`)",
+				"traefik.http.routers.web.middlewares":                "strip",
+				"traefik.http.middlewares.strip.stripprefix.prefixes": "/foo",
+				label.TraefikPort:                                     "80",
+			},
+			expected: map[string]string{
+				label.TraefikPort: "80",
+			},
+		},
+		{
+			desc: "TLS passthrough router ignored",
+			labels: map[string]string{
+				"traefik.tcp.routers.db.rule":                      "HostSNI(`db.foo`)",
+				"traefik.tcp.routers.db.entrypoints":               "tls",
+				"traefik.tcp.routers.db.tls.passthrough":           "true",
+				"traefik.tcp.services.db.loadbalancer.server.port": "443",
+				label.TraefikFrontendRule:                          "Host:foo",
+			},
+			ports: nat.PortMap{"443/tcp": {}},
+			expected: map[string]string{
+				label.TraefikFrontendRule: "Host:foo",
+			},
+		},
+		{
+			desc: "router without service among several services ignored",
+			labels: map[string]string{
+				"traefik.http.routers.web.rule":                    "Host(`foo`)",
+				"traefik.http.services.a.loadbalancer.server.port": "80",
+				"traefik.http.services.b.loadbalancer.server.port": "81",
+			},
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			container := parseContainer(containerJSON(name("foo"), labels(test.labels), ports(test.ports)))
+
+			assert.Equal(t, test.expected, convertTypedLabels(container))
+		})
+	}
+}
+
+func TestConvertRouterRule(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		rule     string
+		expected string
+		hasError bool
+	}{
+		{
+			desc:     "single matcher",
+			rule:     "Host(`foo.bar`)",
+			expected: "Host:foo.bar",
+		},
+		{
+			desc:     "several values",
+			rule:     "Host(`foo.bar`, `bar.foo`)",
+			expected: "Host:foo.bar,bar.foo",
+		},
+		{
+			desc:     "combined matchers",
+			rule:     "Host(`foo.bar`) && PathPrefix(`/api`) && Headers(`X-Foo`, `bar`)",
+			expected: "Host:foo.bar;PathPrefix:/api;Headers:X-Foo,bar",
+		},
+		{
+			desc:     "or",
+			rule:     "Host(`foo.bar`) || Host(`bar.foo`)",
+			hasError: true,
+		},
+		{
+			desc:     "negation",
+			rule:     "!Host(`foo.bar`)",
+			hasError: true,
+		},
+		{
+			desc:     "unknown matcher",
+			rule:     "ClientIP(`10.0.0.1`)",
+			hasError: true,
+		},
+		{
+			desc:     "value with a comma",
+			rule:     "HostRegexp(`{sub:[a-z]{1,3}}.foo`)",
+			hasError: true,
+		},
+		{
+			desc:     "empty",
+			rule:     " ",
+			hasError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			rule, err := convertRouterRule(test.rule)
+			if test.hasError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, rule)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package docker
+
+import (
+	dockertypes "github.com/docker/docker/api/types"
+)
+
+const (
+	// windowsPlatform is the platform reported by the daemon when inspecting a Windows container.
+	windowsPlatform = "windows"
+	// windowsNATNetwork is the network of the Windows containers in the default network mode, created with the nat driver.
+	windowsNATNetwork = "nat"
+)
+
+// isWindowsContainer tells whether the container runs on Windows, whatever the platform of Traefik:
+// the daemon may be a remote one.
+func isWindowsContainer(container dockerData) bool {
+	return container.Platform == windowsPlatform
+}
+
+// parseWindowsNetworks completes the networks of a Windows container, whose settings differ from the Linux ones:
+// the address of an endpoint may only be in its IPAM settings, e.g. with a static IP on a transparent or l2bridge network,
+// and some daemons only report the address of the nat network in the default network settings.
+func parseWindowsNetworks(container dockertypes.ContainerJSON, networks map[string]*networkData) map[string]*networkData {
+	for name, endpoint := range container.NetworkSettings.Networks {
+		network, ok := networks[name]
+		if !ok || len(network.Addr) > 0 || endpoint == nil || endpoint.IPAMConfig == nil {
+			continue
+		}
+
+		if len(endpoint.IPAMConfig.IPv4Address) > 0 {
+			network.Addr = endpoint.IPAMConfig.IPv4Address
+		} else {
+			network.Addr = endpoint.IPAMConfig.IPv6Address
+		}
+	}
+
+	if len(networks) > 0 || len(container.NetworkSettings.IPAddress) == 0 {
+		return networks
+	}
+
+	name := windowsNATNetwork
+	if container.HostConfig != nil {
+		if mode := container.HostConfig.NetworkMode; len(mode) > 0 && !mode.IsDefault() && !mode.IsContainer() && !mode.IsNone() {
+			name = string(mode)
+		}
+	}
+
+	return map[string]*networkData{
+		name: {
+			Name: name,
+			Addr: container.NetworkSettings.IPAddress,
+		},
+	}
+}
@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"testing"
+
+	docker "github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerGetIPAddressWindows(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		container docker.ContainerJSON
+		expected  string
+	}{
+		{
+			desc: "nat network",
+			container: containerJSON(
+				platform("windows"),
+				networkMode("nat"),
+				withNetwork("nat", ipv4("172.25.64.10")),
+			),
+			expected: "172.25.64.10",
+		},
+		{
+			desc: "static address of a transparent network",
+			container: containerJSON(
+				platform("windows"),
+				networkMode("transparent"),
+				withNetwork("transparent", ipamIPv4("10.0.0.20")),
+			),
+			expected: "10.0.0.20",
+		},
+		{
+			desc: "default network settings only",
+			container: containerJSON(
+				platform("windows"),
+				networkMode("default"),
+				defaultIPv4("172.25.64.11"),
+			),
+			expected: "172.25.64.11",
+		},
+		{
+			desc: "default network settings only with a user defined network",
+			container: containerJSON(
+				platform("windows"),
+				networkMode("l2bridge"),
+				defaultIPv4("10.0.0.21"),
+			),
+			expected: "10.0.0.21",
+		},
+		{
+			desc: "linux container",
+			container: containerJSON(
+				platform("linux"),
+				networkMode("transparent"),
+				withNetwork("transparent", ipamIPv4("10.0.0.20")),
+			),
+			expected: "",
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			provider := &Provider{}
+
+			dData := parseContainer(test.container)
+			assert.Equal(t, test.expected, provider.getIPAddress(dData))
+		})
+	}
+}
+
+func TestDockerBuildConfigurationWindows(t *testing.T) {
+	container := containerJSON(name("iis"),
+		platform("windows"),
+		networkMode("default"),
+		ports(nat.PortMap{"80/tcp": {}}),
+		withNetwork("nat", ipamIPv4("172.25.64.10")))
+
+	provider := &Provider{
+		Domain:           "docker.localhost",
+		ExposedByDefault: true,
+	}
+
+	config := provider.buildConfiguration([]dockerData{parseContainer(container)})
+
+	require.Contains(t, config.Backends, "backend-iis")
+	require.Len(t, config.Backends["backend-iis"].Servers, 1)
+	for _, server := range config.Backends["backend-iis"].Servers {
+		assert.Equal(t, "http://172.25.64.10:80", server.URL)
+	}
+}
@@ -33,6 +33,7 @@ const (
 	SuffixBackendBufferingMaxResponseBodyBytes      = SuffixBackendBuffering + ".maxResponseBodyBytes"
 	SuffixBackendBufferingMemResponseBodyBytes      = SuffixBackendBuffering + ".memResponseBodyBytes"
 	SuffixBackendBufferingRetryExpression           = SuffixBackendBuffering + ".retryExpression"
+	SuffixBackendResponseForwardingFlushInterval    = "backend.responseForwarding.flushInterval"
 	SuffixFrontend                                  = "frontend"
 	SuffixFrontendAuth                              = SuffixFrontend + ".auth"
 	SuffixFrontendAuthBasic                         = SuffixFrontendAuth + ".basic"
@@ -120,6 +121,7 @@ const (
 	TraefikBackendBufferingMaxResponseBodyBytes     = Prefix + SuffixBackendBufferingMaxResponseBodyBytes
 	TraefikBackendBufferingMemResponseBodyBytes     = Prefix + SuffixBackendBufferingMemResponseBodyBytes
 	TraefikBackendBufferingRetryExpression          = Prefix + SuffixBackendBufferingRetryExpression
+	TraefikBackendResponseForwardingFlushInterval   = Prefix + SuffixBackendResponseForwardingFlushInterval
 	TraefikFrontend                                 = Prefix + SuffixFrontend
 	TraefikFrontendAuth                             = Prefix + SuffixFrontendAuth
 	TraefikFrontendAuthBasic                        = Prefix + SuffixFrontendAuthBasic
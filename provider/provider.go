@@ -47,6 +47,11 @@ func (p *BaseProvider) MatchConstraints(tags []string) (bool, *types.Constraint)
 	}
 
 	for _, constraint := range p.Constraints {
+		// Environment constraints are only supported by some providers
+		if constraint.IsEnv() {
+			continue
+		}
+
 		// xor: if ok and constraint.MustMatch are equal, then no tag is currently matching with the constraint
 		if ok := constraint.MatchConstraintWithAtLeastOneTag(tags); ok != constraint.MustMatch {
 			return false, constraint
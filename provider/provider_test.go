@@ -296,6 +296,21 @@ func TestMatchingConstraints(t *testing.T) {
 			},
 			expected: false,
 		},
+		// environment constraints are ignored
+		{
+			desc: "env.ENV==prod with us-east-1",
+			constraints: types.Constraints{
+				{
+					Key:       "env.ENV",
+					MustMatch: true,
+					Regex:     "prod",
+				},
+			},
+			tags: []string{
+				"us-east-1",
+			},
+			expected: true,
+		},
 	}
 
 	for _, test := range testCases {
@@ -107,6 +107,8 @@ type Server struct {
 	routinesPool                  *safe.Pool
 	leadership                    *cluster.Leadership
 	defaultForwardingRoundTripper http.RoundTripper
+	backendTransportsLock         sync.Mutex
+	backendTransports             map[types.BackendTLS]*http.Transport // Transports of the backends with a TLS configuration, shared across the reloads
 	metricsRegistry               metrics.Registry
 	provider                      provider.Provider
 	configurationListeners        []func(types.Configuration)
@@ -174,7 +174,7 @@ func (s *Server) loadFrontendConfig(
 				postConfigs = append(postConfigs, postConfig)
 			}
 
-			fwd, err := s.buildForwarder(entryPointName, entryPoint, frontendName, frontend, responseModifier)
+			fwd, err := s.buildForwarder(entryPointName, entryPoint, frontendName, frontend, backend, responseModifier)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create the forwarder for frontend %s: %v", frontendName, err)
 			}
@@ -225,10 +225,10 @@ func (s *Server) loadFrontendConfig(
 }
 
 func (s *Server) buildForwarder(entryPointName string, entryPoint *configuration.EntryPoint,
-	frontendName string, frontend *types.Frontend,
+	frontendName string, frontend *types.Frontend, backend *types.Backend,
 	responseModifier modifyResponse) (http.Handler, error) {
 
-	roundTripper, err := s.getRoundTripper(entryPointName, frontend.PassTLSCert, entryPoint.TLS)
+	roundTripper, err := s.getRoundTripper(entryPointName, frontend.PassTLSCert, entryPoint.TLS, backend.TLS)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create RoundTripper for frontend %s: %v", frontendName, err)
 	}
@@ -238,9 +238,18 @@ func (s *Server) buildForwarder(entryPointName string, entryPoint *configuration
 		return nil, fmt.Errorf("error creating rewriter for frontend %s: %v", frontendName, err)
 	}
 
+	var flushInterval time.Duration
+	if backend.ResponseForwarding != nil {
+		flushInterval, err = time.ParseDuration(backend.ResponseForwarding.FlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid response forwarding flush interval for frontend %s: %v", frontendName, err)
+		}
+	}
+
 	var fwd http.Handler
 	fwd, err = forward.New(
 		forward.Stream(true),
+		forward.StreamingFlushInterval(flushInterval),
 		forward.PassHostHeader(frontend.PassHostHeader),
 		forward.RoundTripper(roundTripper),
 		forward.Rewriter(rewriter),
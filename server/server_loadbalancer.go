@@ -5,10 +5,12 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"time"
 
 	"github.com/containous/traefik/configuration"
@@ -47,8 +49,15 @@ func (s *Server) buildBalancerMiddlewares(frontendName string, frontend *types.F
 	if hcOpts := buildHealthCheckOptions(balancer, frontend.Backend, backend.HealthCheck, s.globalConfiguration.HealthCheck); hcOpts != nil {
 		log.Debugf("Setting up backend health check %s", *hcOpts)
 
-		hcOpts.Transport = s.defaultForwardingRoundTripper
+		hcOpts.Transport, err = s.getBackendRoundTripper(backend.TLS)
+		if err != nil {
+			return nil, nil, err
+		}
+		hcOpts.Standby = getStandbyURLs(backend)
+		hcOpts.StandbyWeights = getStandbyWeights(backend)
 		backendHealthCheck = healthcheck.NewBackendConfig(*hcOpts, frontend.Backend)
+	} else if len(getStandbyURLs(backend)) > 0 {
+		log.Warnf("The standby servers of the backend %s are never used: it has no health check", frontend.Backend)
 	}
 
 	// Empty (backend with no servers)
@@ -79,8 +88,8 @@ func (s *Server) buildBalancerMiddlewares(frontendName string, frontend *types.F
 	}
 
 	// Retry
-	if s.globalConfiguration.Retry != nil {
-		handler := s.buildRetryMiddleware(lb, s.globalConfiguration.Retry, len(backend.Servers), frontend.Backend)
+	if retry := getBackendRetry(s.globalConfiguration.Retry, backend.Retry); retry != nil {
+		handler := s.buildRetryMiddleware(lb, retry, len(backend.Servers), frontend.Backend)
 		lb = s.tracingMiddleware.NewHTTPHandlerWrapper("Retry", handler, false)
 	}
 
@@ -125,7 +134,7 @@ func (s *Server) buildLoadBalancer(frontendName string, backendName string, back
 
 	var stickySession *roundrobin.StickySession
 	var cookieName string
-	if stickiness := backend.LoadBalancer.Stickiness; stickiness != nil {
+	if stickiness := backend.LoadBalancer.Stickiness; stickiness != nil && !stickiness.SourceIP {
 		cookieName = cookie.GetName(stickiness.CookieName, backendName)
 		stickySession = roundrobin.NewStickySession(cookieName)
 	}
@@ -178,6 +187,20 @@ func (s *Server) buildLoadBalancer(frontendName string, backendName string, back
 		return nil, fmt.Errorf("invalid load-balancing method %q", lbMethod)
 	}
 
+	if stickiness := backend.LoadBalancer.Stickiness; stickiness != nil && stickiness.SourceIP {
+		log.Debug("Sticky session by source IP")
+
+		next := fwd
+		if s.accessLoggerMiddleware != nil {
+			next = saveFrontend
+		}
+		if lbMethod == types.Drr {
+			log.Warnf("The dynamic weights of the drr load balancer of the backend %s are not used with the source IP stickiness", backendName)
+		}
+		// The configured balancer keeps managing the servers, and serves the requests without server.
+		lb = &sourceIPBalancer{BalancerHandler: lb, next: next}
+	}
+
 	if err := s.configureLBServers(lb, backend, backendName); err != nil {
 		return nil, fmt.Errorf("error configuring load balancer for frontend %s: %v", frontendName, err)
 	}
@@ -192,6 +215,19 @@ func (s *Server) configureLBServers(lb healthcheck.BalancerHandler, backend *typ
 			return fmt.Errorf("error parsing server URL %s: %v", srv.URL, err)
 		}
 
+		if srv.Maintenance {
+			log.Debugf("Skipping server %s at %s: it is in maintenance", name, u)
+			s.metricsRegistry.BackendServerUpGauge().With("backend", backendName, "url", srv.URL).Set(0)
+			continue
+		}
+
+		if srv.Standby {
+			// The health check adds the standby servers when needed.
+			log.Debugf("Skipping server %s at %s: it is a standby server", name, u)
+			s.metricsRegistry.BackendServerUpGauge().With("backend", backendName, "url", srv.URL).Set(0)
+			continue
+		}
+
 		log.Debugf("Creating server %s at %s with weight %d", name, u, srv.Weight)
 
 		if err := lb.UpsertServer(u, roundrobin.Weight(srv.Weight)); err != nil {
@@ -204,8 +240,9 @@ func (s *Server) configureLBServers(lb healthcheck.BalancerHandler, backend *typ
 }
 
 // getRoundTripper will either use server.defaultForwardingRoundTripper or create a new one
-// given a custom TLS configuration is passed and the passTLSCert option is set to true.
-func (s *Server) getRoundTripper(entryPointName string, passTLSCert bool, tls *traefiktls.TLS) (http.RoundTripper, error) {
+// given a custom TLS configuration is passed and the passTLSCert option is set to true,
+// or the backend has a TLS configuration.
+func (s *Server) getRoundTripper(entryPointName string, passTLSCert bool, tls *traefiktls.TLS, backendTLS *types.BackendTLS) (http.RoundTripper, error) {
 	if passTLSCert {
 		tlsConfig, err := createClientTLSConfig(entryPointName, tls)
 		if err != nil {
@@ -218,10 +255,50 @@ func (s *Server) getRoundTripper(entryPointName string, passTLSCert bool, tls *t
 		}
 
 		transport.TLSClientConfig = tlsConfig
+		applyBackendTLS(transport.TLSClientConfig, backendTLS)
+		return transport, nil
+	}
+
+	return s.getBackendRoundTripper(backendTLS)
+}
+
+// getBackendRoundTripper will either use server.defaultForwardingRoundTripper or the one of the TLS configuration
+// of the backend, created on the first use and kept across the reloads so that its connections are reused.
+func (s *Server) getBackendRoundTripper(backendTLS *types.BackendTLS) (http.RoundTripper, error) {
+	if backendTLS == nil {
+		return s.defaultForwardingRoundTripper, nil
+	}
+
+	s.backendTransportsLock.Lock()
+	defer s.backendTransportsLock.Unlock()
+
+	if transport, ok := s.backendTransports[*backendTLS]; ok {
 		return transport, nil
 	}
 
-	return s.defaultForwardingRoundTripper, nil
+	transport, err := newHTTPTransport(s.globalConfiguration, backendTLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP transport: %v", err)
+	}
+
+	if s.backendTransports == nil {
+		s.backendTransports = make(map[types.BackendTLS]*http.Transport)
+	}
+	s.backendTransports[*backendTLS] = transport
+	return transport, nil
+}
+
+// applyBackendTLS applies the TLS configuration of a backend on top of the global one:
+// the certificates of the servers can only be skipped, never checked again.
+func applyBackendTLS(tlsConfig *tls.Config, backendTLS *types.BackendTLS) {
+	if backendTLS == nil {
+		return
+	}
+
+	tlsConfig.InsecureSkipVerify = tlsConfig.InsecureSkipVerify || backendTLS.InsecureSkipVerify
+	if len(backendTLS.ServerName) > 0 {
+		tlsConfig.ServerName = backendTLS.ServerName
+	}
 }
 
 // createHTTPTransport creates an http.Transport configured with the GlobalConfiguration settings.
@@ -230,6 +307,11 @@ func (s *Server) getRoundTripper(entryPointName string, passTLSCert bool, tls *t
 // in Traefik at this point in time. Setting this value to the default of 100 could lead to confusing
 // behavior and backwards compatibility issues.
 func createHTTPTransport(globalConfiguration configuration.GlobalConfiguration) (*http.Transport, error) {
+	return newHTTPTransport(globalConfiguration, nil)
+}
+
+// newHTTPTransport creates an http.Transport as createHTTPTransport, with the TLS configuration of a backend if any.
+func newHTTPTransport(globalConfiguration configuration.GlobalConfiguration, backendTLS *types.BackendTLS) (*http.Transport, error) {
 	dialer := &net.Dialer{
 		Timeout:   configuration.DefaultDialTimeout,
 		KeepAlive: 30 * time.Second,
@@ -272,6 +354,13 @@ func createHTTPTransport(globalConfiguration configuration.GlobalConfiguration)
 		}
 	}
 
+	if backendTLS != nil {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		applyBackendTLS(transport.TLSClientConfig, backendTLS)
+	}
+
 	err := http2.ConfigureTransport(transport)
 	if err != nil {
 		return nil, err
@@ -325,6 +414,15 @@ func createClientTLSConfig(entryPointName string, tlsOption *traefiktls.TLS) (*t
 	return config, nil
 }
 
+// getBackendRetry returns the retry configuration of a backend: its own attempts take precedence over the global ones,
+// and enable the retries even when they are not enabled globally.
+func getBackendRetry(globalRetry *configuration.Retry, backendRetry *types.Retry) *configuration.Retry {
+	if backendRetry != nil && backendRetry.Attempts > 0 {
+		return &configuration.Retry{Attempts: backendRetry.Attempts}
+	}
+	return globalRetry
+}
+
 func (s *Server) buildRetryMiddleware(handler http.Handler, retry *configuration.Retry, countServers int, backendName string) http.Handler {
 	retryListeners := middlewares.RetryListeners{}
 	if s.metricsRegistry.IsEnabled() {
@@ -393,6 +491,41 @@ func buildMaxConn(lb http.Handler, maxConns *types.MaxConn) (http.Handler, error
 	return handler, nil
 }
 
+// getStandbyURLs returns the URLs of the standby servers of the backend, sorted to keep the health checks stable.
+func getStandbyURLs(backend *types.Backend) []*url.URL {
+	var rawURLs []string
+	for _, srv := range backend.Servers {
+		if srv.Standby && !srv.Maintenance {
+			rawURLs = append(rawURLs, srv.URL)
+		}
+	}
+	sort.Strings(rawURLs)
+
+	var urls []*url.URL
+	for _, rawURL := range rawURLs {
+		// The invalid URLs are already reported by the load balancer configuration.
+		if u, err := url.Parse(rawURL); err == nil {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// getStandbyWeights returns the weights of the standby servers of the backend, by URL.
+func getStandbyWeights(backend *types.Backend) map[string]int {
+	weights := make(map[string]int)
+	for _, srv := range backend.Servers {
+		if !srv.Standby || srv.Maintenance {
+			continue
+		}
+		// Keyed as the parsed URLs given to the health check.
+		if u, err := url.Parse(srv.URL); err == nil {
+			weights[u.String()] = srv.Weight
+		}
+	}
+	return weights
+}
+
 func buildHealthCheckOptions(lb healthcheck.BalancerHandler, backend string, hc *types.HealthCheck, hcConfig *configuration.HealthCheckConfig) *healthcheck.Options {
 	if hc == nil || hc.Path == "" || hcConfig == nil {
 		return nil
@@ -420,3 +553,50 @@ func buildHealthCheckOptions(lb healthcheck.BalancerHandler, backend string, hc
 		Headers:  hc.Headers,
 	}
 }
+
+// sourceIPBalancer sends the requests of a client IP to the same server, as long as this server is in the load balancer.
+// The server is chosen by rendezvous hashing, so that only the clients of a removed server move to other ones.
+// The weights of the servers are not used.
+type sourceIPBalancer struct {
+	healthcheck.BalancerHandler
+	next http.Handler
+}
+
+func (b *sourceIPBalancer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	server := getSourceIPServer(getSourceIP(req), b.Servers())
+	if server == nil {
+		// The round robin handles the errors, e.g. when there is no server.
+		b.BalancerHandler.ServeHTTP(w, req)
+		return
+	}
+
+	// Shallow copy of the request, so as to not change the original one.
+	newReq := *req
+	newReq.URL = server
+	b.next.ServeHTTP(w, &newReq)
+}
+
+func getSourceIP(req *http.Request) string {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return ip
+}
+
+// getSourceIPServer returns the server with the highest hash of the source IP and the server URL.
+func getSourceIPServer(sourceIP string, servers []*url.URL) *url.URL {
+	var server *url.URL
+	var maxHash uint64
+	for _, u := range servers {
+		hash := fnv.New64a()
+		hash.Write([]byte(sourceIP))
+		hash.Write([]byte(u.String()))
+
+		if sum := hash.Sum64(); server == nil || sum > maxHash {
+			server = u
+			maxHash = sum
+		}
+	}
+	return server
+}
@@ -1,10 +1,18 @@
 package server
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"testing"
 
+	"github.com/containous/traefik/configuration"
+	"github.com/containous/traefik/metrics"
 	"github.com/containous/traefik/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vulcand/oxy/roundrobin"
 )
 
 func TestConfigureBackends(t *testing.T) {
@@ -79,3 +87,218 @@ func TestConfigureBackends(t *testing.T) {
 		})
 	}
 }
+
+func TestSourceIPBalancer(t *testing.T) {
+	var forwarded []string
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		forwarded = append(forwarded, req.URL.String())
+	})
+
+	rr, err := roundrobin.New(next)
+	require.NoError(t, err)
+	lb := &sourceIPBalancer{BalancerHandler: rr, next: next}
+
+	for _, server := range []string{"http://10.0.0.1:80", "http://10.0.0.2:80", "http://10.0.0.3:80"} {
+		u, err := url.Parse(server)
+		require.NoError(t, err)
+		require.NoError(t, lb.UpsertServer(u))
+	}
+
+	serve := func(remoteAddr string) string {
+		req := httptest.NewRequest(http.MethodGet, "http://foo.localhost/", nil)
+		req.RemoteAddr = remoteAddr
+		lb.ServeHTTP(httptest.NewRecorder(), req)
+		return forwarded[len(forwarded)-1]
+	}
+
+	// The requests of a client IP always go to the same server, whatever its port.
+	server := serve("192.168.0.1:1234")
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, server, serve("192.168.0.1:5678"))
+	}
+
+	// The clients of a removed server move to another one.
+	u, err := url.Parse(server)
+	require.NoError(t, err)
+	require.NoError(t, lb.RemoveServer(u))
+	assert.NotEqual(t, server, serve("192.168.0.1:1234"))
+
+	// The clients spread over the servers.
+	used := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		used[serve("192.168.1."+strconv.Itoa(i)+":1234")] = true
+	}
+	assert.Len(t, used, 2)
+}
+
+func TestBuildLoadBalancerSourceIP(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		method   string
+		expected interface{}
+	}{
+		{
+			desc:     "wrr",
+			method:   "wrr",
+			expected: &roundrobin.RoundRobin{},
+		},
+		{
+			desc:     "drr",
+			method:   "drr",
+			expected: &roundrobin.Rebalancer{},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			s := &Server{metricsRegistry: metrics.NewVoidRegistry()}
+			backend := &types.Backend{
+				Servers: map[string]types.Server{
+					"server": {URL: "http://10.0.0.1:80", Weight: 1},
+				},
+				LoadBalancer: &types.LoadBalancer{
+					Method:     test.method,
+					Stickiness: &types.Stickiness{SourceIP: true},
+				},
+			}
+
+			lb, err := s.buildLoadBalancer("frontend", "backend", backend, http.NotFoundHandler())
+			require.NoError(t, err)
+
+			sourceIP, ok := lb.(*sourceIPBalancer)
+			require.True(t, ok)
+			// The configured balancer is kept, with the servers.
+			assert.IsType(t, test.expected, sourceIP.BalancerHandler)
+			assert.Len(t, sourceIP.Servers(), 1)
+		})
+	}
+}
+
+func TestGetBackendRoundTripper(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	testCases := []struct {
+		desc       string
+		backendTLS *types.BackendTLS
+		expectErr  bool
+	}{
+		{
+			desc:      "without backend TLS",
+			expectErr: true,
+		},
+		{
+			desc:       "verified server name",
+			backendTLS: &types.BackendTLS{ServerName: "foo.internal"},
+			expectErr:  true,
+		},
+		{
+			desc:       "insecure skip verify",
+			backendTLS: &types.BackendTLS{InsecureSkipVerify: true},
+		},
+		{
+			desc: "insecure skip verify with a server name",
+			backendTLS: &types.BackendTLS{
+				InsecureSkipVerify: true,
+				ServerName:         "foo.internal",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			server := &Server{}
+			server.defaultForwardingRoundTripper = http.DefaultTransport
+
+			roundTripper, err := server.getBackendRoundTripper(test.backendTLS)
+			require.NoError(t, err)
+
+			if test.backendTLS == nil {
+				assert.Equal(t, http.DefaultTransport, roundTripper)
+			} else {
+				transport, ok := roundTripper.(*http.Transport)
+				require.True(t, ok)
+				assert.Equal(t, test.backendTLS.ServerName, transport.TLSClientConfig.ServerName)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, backend.URL, nil)
+			req.RequestURI = ""
+
+			resp, err := roundTripper.RoundTrip(req)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestGetBackendRoundTripperReused(t *testing.T) {
+	server := &Server{}
+
+	first, err := server.getBackendRoundTripper(&types.BackendTLS{ServerName: "foo.internal"})
+	require.NoError(t, err)
+
+	// The transport of the same TLS configuration is shared across the reloads, not created again.
+	again, err := server.getBackendRoundTripper(&types.BackendTLS{ServerName: "foo.internal"})
+	require.NoError(t, err)
+	assert.True(t, first == again)
+
+	other, err := server.getBackendRoundTripper(&types.BackendTLS{ServerName: "bar.internal"})
+	require.NoError(t, err)
+	assert.False(t, first == other)
+	assert.Len(t, server.backendTransports, 2)
+}
+
+func TestGetBackendRetry(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		globalRetry  *configuration.Retry
+		backendRetry *types.Retry
+		expected     *configuration.Retry
+	}{
+		{
+			desc: "no retry",
+		},
+		{
+			desc:        "global retry",
+			globalRetry: &configuration.Retry{Attempts: 2},
+			expected:    &configuration.Retry{Attempts: 2},
+		},
+		{
+			desc:         "backend retry taking precedence",
+			globalRetry:  &configuration.Retry{Attempts: 2},
+			backendRetry: &types.Retry{Attempts: 5},
+			expected:     &configuration.Retry{Attempts: 5},
+		},
+		{
+			desc:         "backend retry without global retry",
+			backendRetry: &types.Retry{Attempts: 3},
+			expected:     &configuration.Retry{Attempts: 3},
+		},
+		{
+			desc:         "backend retry without attempts",
+			globalRetry:  &configuration.Retry{},
+			backendRetry: &types.Retry{},
+			expected:     &configuration.Retry{},
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, getBackendRetry(test.globalRetry, test.backendRetry))
+		})
+	}
+}
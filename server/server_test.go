@@ -225,6 +225,22 @@ func TestServerResponseEmptyBackend(t *testing.T) {
 			},
 			expectedStatusCode: http.StatusOK,
 		},
+		{
+			desc: "Server in maintenance",
+			config: func(testServerURL string) *types.Configuration {
+				return th.BuildConfiguration(
+					th.WithFrontends(th.WithFrontend("backend",
+						th.WithEntryPoints("http"),
+						th.WithRoutes(th.WithRoute(requestPath, routeRule))),
+					),
+					th.WithBackends(th.WithBackendNew("backend",
+						th.WithLBMethod("wrr"),
+						th.WithServersNew(th.WithServerNew(testServerURL, th.WithMaintenance()))),
+					),
+				)
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
 		{
 			desc: "No Frontend",
 			config: func(testServerURL string) *types.Configuration {
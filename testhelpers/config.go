@@ -61,6 +61,13 @@ func WithServerNew(url string, opts ...func(*types.Server)) func(*types.Server)
 	}
 }
 
+// WithMaintenance is a helper to create a configuration
+func WithMaintenance() func(*types.Server) {
+	return func(s *types.Server) {
+		s.Maintenance = true
+	}
+}
+
 // WithLBMethod is a helper to create a configuration
 func WithLBMethod(method string) func(*types.Backend) {
 	return func(b *types.Backend) {
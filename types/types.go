@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -22,12 +23,15 @@ import (
 
 // Backend holds backend configuration.
 type Backend struct {
-	Servers        map[string]Server `json:"servers,omitempty"`
-	CircuitBreaker *CircuitBreaker   `json:"circuitBreaker,omitempty"`
-	LoadBalancer   *LoadBalancer     `json:"loadBalancer,omitempty"`
-	MaxConn        *MaxConn          `json:"maxConn,omitempty"`
-	HealthCheck    *HealthCheck      `json:"healthCheck,omitempty"`
-	Buffering      *Buffering        `json:"buffering,omitempty"`
+	Servers            map[string]Server   `json:"servers,omitempty"`
+	CircuitBreaker     *CircuitBreaker     `json:"circuitBreaker,omitempty"`
+	LoadBalancer       *LoadBalancer       `json:"loadBalancer,omitempty"`
+	MaxConn            *MaxConn            `json:"maxConn,omitempty"`
+	HealthCheck        *HealthCheck        `json:"healthCheck,omitempty"`
+	Buffering          *Buffering          `json:"buffering,omitempty"`
+	ResponseForwarding *ResponseForwarding `json:"responseForwarding,omitempty"`
+	TLS                *BackendTLS         `json:"tls,omitempty"`
+	Retry              *Retry              `json:"retry,omitempty"`
 }
 
 // MaxConn holds maximum connection configuration
@@ -45,6 +49,7 @@ type LoadBalancer struct {
 // Stickiness holds sticky session configuration.
 type Stickiness struct {
 	CookieName string `json:"cookieName,omitempty"`
+	SourceIP   bool   `json:"sourceIP,omitempty"` // Stick the clients to a server by their IP address, instead of a cookie
 }
 
 // CircuitBreaker holds circuit breaker configuration.
@@ -61,6 +66,22 @@ type Buffering struct {
 	RetryExpression      string `json:"retryExpression,omitempty"`
 }
 
+// ResponseForwarding holds configuration for the forward of the response
+type ResponseForwarding struct {
+	FlushInterval string `json:"flushInterval,omitempty"`
+}
+
+// BackendTLS holds the TLS configuration of the connections to the servers of a backend
+type BackendTLS struct {
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+}
+
+// Retry holds the retry configuration of a backend
+type Retry struct {
+	Attempts int `json:"attempts,omitempty"`
+}
+
 // WhiteList contains white list configuration.
 type WhiteList struct {
 	SourceRange      []string `json:"sourceRange,omitempty"`
@@ -79,8 +100,10 @@ type HealthCheck struct {
 
 // Server holds server configuration.
 type Server struct {
-	URL    string `json:"url,omitempty"`
-	Weight int    `json:"weight"`
+	URL         string `json:"url,omitempty"`
+	Weight      int    `json:"weight"`
+	Maintenance bool   `json:"maintenance,omitempty"` // Kept in the configuration, but not in the load balancer
+	Standby     bool   `json:"standby,omitempty"`     // Only in the load balancer while no other server is healthy, needs a health check
 }
 
 // Route holds route configuration.
@@ -261,6 +284,12 @@ type ConfigMessage struct {
 	Configuration *Configuration
 }
 
+// ConstraintKeyTag is the key of the constraints matching the provider tags
+const ConstraintKeyTag = "tag"
+
+// ConstraintKeyEnvPrefix is the key prefix of the constraints matching an environment variable (e.g. env.ENV==prod)
+const ConstraintKeyEnvPrefix = "env."
+
 // Constraint hold a parsed constraint expression
 type Constraint struct {
 	Key string `export:"true"`
@@ -287,9 +316,9 @@ func NewConstraint(exp string) (*Constraint, error) {
 
 	kv := strings.SplitN(exp, sep, 2)
 	if len(kv) == 2 {
-		// At the moment, it only supports tags
-		if kv[0] != "tag" {
-			return nil, errors.New("constraint must be tag-based. Syntax: tag==us-*")
+		// At the moment, it only supports tags and environment variables
+		if kv[0] != ConstraintKeyTag && (!strings.HasPrefix(kv[0], ConstraintKeyEnvPrefix) || len(kv[0]) == len(ConstraintKeyEnvPrefix)) {
+			return nil, errors.New("constraint must be tag-based or env-based. Syntax: tag==us-* or env.ENV==prod")
 		}
 
 		constraint.Key = kv[0]
@@ -328,6 +357,17 @@ func (c *Constraint) MarshalText() (text []byte, err error) {
 	return []byte(c.String()), nil
 }
 
+// IsEnv returns true if the constraint matches an environment variable
+func (c *Constraint) IsEnv() bool {
+	return strings.HasPrefix(c.Key, ConstraintKeyEnvPrefix)
+}
+
+// MatchConstraintWithEnv tests a constraint against the environment variables of one single service
+func (c *Constraint) MatchConstraintWithEnv(env map[string]string) bool {
+	value, ok := env[strings.TrimPrefix(c.Key, ConstraintKeyEnvPrefix)]
+	return ok && glob.Glob(c.Regex, value)
+}
+
 // MatchConstraintWithAtLeastOneTag tests a constraint for one single service
 func (c *Constraint) MatchConstraintWithAtLeastOneTag(tags []string) bool {
 	for _, tag := range tags {
@@ -506,8 +546,15 @@ type ClientTLS struct {
 	Cert               string `description:"TLS cert" json:"cert,omitempty"`
 	Key                string `description:"TLS key" json:"key,omitempty"`
 	InsecureSkipVerify bool   `description:"TLS insecure skip verify" json:"insecureSkipVerify,omitempty"`
+	ServerName         string `description:"TLS server name, used instead of the host to verify the certificate" json:"serverName,omitempty"`
+	CASecret           string `description:"Name of the swarm secret holding the TLS CA, instead of CA" json:"caSecret,omitempty"`
+	CertSecret         string `description:"Name of the swarm secret holding the TLS cert, instead of Cert" json:"certSecret,omitempty"`
+	KeySecret          string `description:"Name of the swarm secret holding the TLS key, instead of Key" json:"keySecret,omitempty"`
 }
 
+// secretsDirectory is where swarm mounts the secrets in the containers.
+var secretsDirectory = "/run/secrets"
+
 // CreateTLSConfig creates a TLS config from ClientTLS structures
 func (clientTLS *ClientTLS) CreateTLSConfig() (*tls.Config, error) {
 	var err error
@@ -515,6 +562,11 @@ func (clientTLS *ClientTLS) CreateTLSConfig() (*tls.Config, error) {
 		log.Warnf("clientTLS is nil")
 		return nil, nil
 	}
+
+	clientTLS, err = clientTLS.resolveSecrets()
+	if err != nil {
+		return nil, err
+	}
 	caPool := x509.NewCertPool()
 	clientAuth := tls.NoClientCert
 	if clientTLS.CA != "" {
@@ -570,10 +622,46 @@ func (clientTLS *ClientTLS) CreateTLSConfig() (*tls.Config, error) {
 		RootCAs:            caPool,
 		InsecureSkipVerify: clientTLS.InsecureSkipVerify,
 		ClientAuth:         clientAuth,
+		ServerName:         clientTLS.ServerName,
 	}
 	return TLSConfig, nil
 }
 
+// resolveSecrets returns a copy of the configuration with the paths of the secrets instead of their names.
+func (clientTLS *ClientTLS) resolveSecrets() (*ClientTLS, error) {
+	resolved := *clientTLS
+
+	for _, secret := range []struct {
+		field string
+		name  string
+		value *string
+	}{
+		{field: "CA", name: clientTLS.CASecret, value: &resolved.CA},
+		{field: "Cert", name: clientTLS.CertSecret, value: &resolved.Cert},
+		{field: "Key", name: clientTLS.KeySecret, value: &resolved.Key},
+	} {
+		if len(secret.name) == 0 {
+			continue
+		}
+
+		if len(*secret.value) > 0 {
+			return nil, fmt.Errorf("TLS %s and %sSecret cannot be both set", secret.field, secret.field)
+		}
+
+		if strings.ContainsAny(secret.name, `/\`) || secret.name == "." || secret.name == ".." {
+			return nil, fmt.Errorf("invalid TLS %sSecret %q: it must be the name of a secret", secret.field, secret.name)
+		}
+
+		path := filepath.Join(secretsDirectory, secret.name)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed to read the TLS %sSecret %q: %v", secret.field, secret.name, err)
+		}
+		*secret.value = path
+	}
+
+	return &resolved, nil
+}
+
 // HTTPCodeRanges holds HTTP code ranges
 type HTTPCodeRanges [][2]int
 
@@ -2,9 +2,13 @@ package types
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHeaders_ShouldReturnFalseWhenNotHasCustomHeadersDefined(t *testing.T) {
@@ -136,3 +140,130 @@ func TestHTTPCodeRanges_Contains(t *testing.T) {
 		})
 	}
 }
+
+func TestNewConstraint(t *testing.T) {
+	testCases := []struct {
+		expression  string
+		expected    *Constraint
+		expectedErr bool
+	}{
+		{
+			expression: "tag==us-east-1",
+			expected:   &Constraint{Key: "tag", MustMatch: true, Regex: "us-east-1"},
+		},
+		{
+			expression: "env.ENV!=prod",
+			expected:   &Constraint{Key: "env.ENV", MustMatch: false, Regex: "prod"},
+		},
+		{
+			expression:  "env.==prod",
+			expectedErr: true,
+		},
+		{
+			expression:  "label==prod",
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.expression, func(t *testing.T) {
+			t.Parallel()
+
+			constraint, err := NewConstraint(test.expression)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, constraint)
+		})
+	}
+}
+
+func TestClientTLSSecrets(t *testing.T) {
+	directory, err := ioutil.TempDir("", "traefik-secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	for _, name := range []string{"docker-ca", "docker-cert", "docker-key"} {
+		err = ioutil.WriteFile(filepath.Join(directory, name), []byte(name), 0600)
+		require.NoError(t, err)
+	}
+
+	defaultDirectory := secretsDirectory
+	secretsDirectory = directory
+	defer func() { secretsDirectory = defaultDirectory }()
+
+	testCases := []struct {
+		desc          string
+		clientTLS     ClientTLS
+		expected      *ClientTLS
+		expectedError bool
+	}{
+		{
+			desc: "secrets",
+			clientTLS: ClientTLS{
+				CASecret:   "docker-ca",
+				CertSecret: "docker-cert",
+				KeySecret:  "docker-key",
+			},
+			expected: &ClientTLS{
+				CA:         filepath.Join(directory, "docker-ca"),
+				Cert:       filepath.Join(directory, "docker-cert"),
+				Key:        filepath.Join(directory, "docker-key"),
+				CASecret:   "docker-ca",
+				CertSecret: "docker-cert",
+				KeySecret:  "docker-key",
+			},
+		},
+		{
+			desc: "no secret",
+			clientTLS: ClientTLS{
+				CA: "/etc/docker/ca.pem",
+			},
+			expected: &ClientTLS{
+				CA: "/etc/docker/ca.pem",
+			},
+		},
+		{
+			desc: "missing secret",
+			clientTLS: ClientTLS{
+				CASecret: "missing",
+			},
+			expectedError: true,
+		},
+		{
+			desc: "secret and path",
+			clientTLS: ClientTLS{
+				CA:       "/etc/docker/ca.pem",
+				CASecret: "docker-ca",
+			},
+			expectedError: true,
+		},
+		{
+			desc: "secret outside the secrets directory",
+			clientTLS: ClientTLS{
+				KeySecret: "../docker-key",
+			},
+			expectedError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		resolved, err := test.clientTLS.resolveSecrets()
+		if test.expectedError {
+			assert.Error(t, err, test.desc)
+			continue
+		}
+
+		require.NoError(t, err, test.desc)
+		assert.Equal(t, test.expected, resolved, test.desc)
+	}
+
+	clientTLS := &ClientTLS{CASecret: "docker-ca", InsecureSkipVerify: true}
+	_, err = clientTLS.CreateTLSConfig()
+	require.NoError(t, err)
+	assert.Empty(t, clientTLS.CA, "the configuration must not be modified")
+}